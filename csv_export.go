@@ -0,0 +1,341 @@
+/**
+ * Created by goland.
+ * User: adam_wang
+ * Date: 2023-09-26 21:18:57
+ */
+
+package tool
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	beegoContext "github.com/beego/beego/v2/server/web/context"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// CSV文件编码
+const (
+	CsvUTF8    = "utf-8"
+	CsvUTF8BOM = "utf-8-bom" // 带BOM的UTF-8，Excel可直接识别
+	CsvGBK     = "gbk"
+	CsvGB18030 = "gb18030"
+)
+
+// CsvOptions CSV写入配置
+type CsvOptions struct {
+	Encoding   string // 文件编码，默认为utf-8
+	Comma      rune   // 分隔符，默认为逗号
+	UseCRLF    bool   // 是否使用\r\n作为换行符
+	FlushEvery int    // 每写入多少行刷新一次输出，默认为100
+}
+
+// CsvWriter 流式CSV写入器
+type CsvWriter struct {
+	out      io.Writer
+	writer   *csv.Writer
+	encoder  *encoding.Encoder
+	opts     CsvOptions
+	rows     int
+	wroteBOM bool
+}
+
+// RowIterator 行迭代器，没有更多数据时返回io.EOF
+type RowIterator interface {
+	Next() ([]string, error)
+}
+
+// RowIteratorFunc 函数形式的行迭代器
+type RowIteratorFunc func() ([]string, error)
+
+// Next 获取下一行
+// @receiver f RowIteratorFunc
+// @return []string
+// @return error
+func (f RowIteratorFunc) Next() ([]string, error) {
+	return f()
+}
+
+// NewCsvWriter 创建流式CSV写入器
+// @param w io.Writer
+// @param opts CsvOptions
+// @return *CsvWriter
+// @return error
+func NewCsvWriter(w io.Writer, opts CsvOptions) (*CsvWriter, error) {
+	c := &CsvWriter{out: w, opts: opts}
+	switch strings.ToLower(opts.Encoding) {
+	case "", CsvUTF8, CsvUTF8BOM:
+	case CsvGBK:
+		c.encoder = simplifiedchinese.GBK.NewEncoder()
+	case CsvGB18030:
+		c.encoder = simplifiedchinese.GB18030.NewEncoder()
+	default:
+		return nil, fmt.Errorf("csv: unsupported encoding %q", opts.Encoding)
+	}
+	if c.opts.FlushEvery <= 0 {
+		c.opts.FlushEvery = 100
+	}
+
+	c.writer = csv.NewWriter(w)
+	if opts.Comma != 0 {
+		c.writer.Comma = opts.Comma
+	}
+	c.writer.UseCRLF = opts.UseCRLF
+	return c, nil
+}
+
+// Write 写入一行，按配置的编码转换，无法编码的字符返回错误（不会修改传入的切片）
+// @receiver c *CsvWriter
+// @param row []string
+// @return error
+func (c *CsvWriter) Write(row []string) error {
+	if strings.ToLower(c.opts.Encoding) == CsvUTF8BOM && !c.wroteBOM {
+		if _, err := c.out.Write([]byte("\xEF\xBB\xBF")); err != nil {
+			return err
+		}
+		c.wroteBOM = true
+	}
+
+	record := row
+	if c.encoder != nil {
+		record = make([]string, len(row))
+		for i, field := range row {
+			encoded, err := c.encoder.String(field)
+			if err != nil {
+				return fmt.Errorf("csv: row %d column %d: cannot encode %q as %s: %w", c.rows+1, i+1, field, c.opts.Encoding, err)
+			}
+			record[i] = encoded
+		}
+	} else {
+		for i, field := range row {
+			if !utf8.ValidString(field) {
+				return fmt.Errorf("csv: row %d column %d: invalid utf-8 string", c.rows+1, i+1)
+			}
+		}
+	}
+
+	if err := c.writer.Write(record); err != nil {
+		return err
+	}
+	c.rows++
+	if c.rows%c.opts.FlushEvery == 0 {
+		return c.Flush()
+	}
+	return nil
+}
+
+// WriteAll 从迭代器中读取并写入所有行
+// @receiver c *CsvWriter
+// @param rows RowIterator
+// @return error
+func (c *CsvWriter) WriteAll(rows RowIterator) error {
+	for {
+		row, err := rows.Next()
+		if err == io.EOF {
+			return c.Flush()
+		}
+		if err != nil {
+			return err
+		}
+		if err = c.Write(row); err != nil {
+			return err
+		}
+	}
+}
+
+// Flush 将缓冲中的数据写入输出，输出支持http.Flusher时同时推送到客户端
+// @receiver c *CsvWriter
+// @return error
+func (c *CsvWriter) Flush() error {
+	c.writer.Flush()
+	if err := c.writer.Error(); err != nil {
+		return err
+	}
+	if flusher, ok := c.out.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return nil
+}
+
+// RowsFromSlice 由二维切片创建行迭代器
+// @param dataList [][]string
+// @return RowIterator
+func RowsFromSlice(dataList [][]string) RowIterator {
+	i := 0
+	return RowIteratorFunc(func() ([]string, error) {
+		if i >= len(dataList) {
+			return nil, io.EOF
+		}
+		i++
+		return dataList[i-1], nil
+	})
+}
+
+// RowsFromChannel 由通道创建行迭代器，通道关闭时结束
+// @param ch <-chan []string
+// @return RowIterator
+func RowsFromChannel(ch <-chan []string) RowIterator {
+	return RowIteratorFunc(func() ([]string, error) {
+		row, ok := <-ch
+		if !ok {
+			return nil, io.EOF
+		}
+		return row, nil
+	})
+}
+
+// RowsFromSQL 由数据库游标创建行迭代器（可通过orm.GetDB获取*sql.DB后查询），结束时自动关闭游标；
+// 同时实现ValueRowIterator，整数及浮点数类型的列在excel、ods中写为数字单元格
+// @param rows *sql.Rows
+// @return RowIterator
+func RowsFromSQL(rows *sql.Rows) RowIterator {
+	return &sqlRows{rows: rows}
+}
+
+// sqlRows 数据库游标的行迭代器
+type sqlRows struct {
+	rows    *sql.Rows
+	values  []sql.RawBytes
+	dest    []interface{}
+	numeric []bool
+}
+
+// Next 获取下一行
+// @receiver r *sqlRows
+// @return []string
+// @return error
+func (r *sqlRows) Next() ([]string, error) {
+	if err := r.scan(); err != nil {
+		return nil, err
+	}
+	row := make([]string, len(r.values))
+	for i, value := range r.values {
+		row[i] = string(value)
+	}
+	return row, nil
+}
+
+// NextValues 获取下一行，数值类型的列转换为int64或float64，NULL为nil
+// @receiver r *sqlRows
+// @return []interface{}
+// @return error
+func (r *sqlRows) NextValues() ([]interface{}, error) {
+	if err := r.scan(); err != nil {
+		return nil, err
+	}
+	row := make([]interface{}, len(r.values))
+	for i, value := range r.values {
+		if value == nil {
+			continue
+		}
+		s := string(value)
+		row[i] = s
+		if !r.numeric[i] {
+			continue
+		}
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			row[i] = n
+		} else if f, err := strconv.ParseFloat(s, 64); err == nil {
+			row[i] = f
+		}
+	}
+	return row, nil
+}
+
+// scan 读取下一行到values，没有更多数据或出错时关闭游标
+// @receiver r *sqlRows
+// @return error
+func (r *sqlRows) scan() error {
+	if r.dest == nil {
+		columnTypes, err := r.rows.ColumnTypes()
+		if err != nil {
+			_ = r.rows.Close()
+			return err
+		}
+		r.values = make([]sql.RawBytes, len(columnTypes))
+		r.dest = make([]interface{}, len(columnTypes))
+		r.numeric = make([]bool, len(columnTypes))
+		for i, columnType := range columnTypes {
+			r.dest[i] = &r.values[i]
+			r.numeric[i] = _isNumericType(columnType.ScanType())
+		}
+	}
+	if !r.rows.Next() {
+		err := r.rows.Err()
+		_ = r.rows.Close()
+		if err != nil {
+			return err
+		}
+		return io.EOF
+	}
+	if err := r.rows.Scan(r.dest...); err != nil {
+		_ = r.rows.Close()
+		return err
+	}
+	return nil
+}
+
+// _isNumericType 是否为整数或浮点数类型（含sql.NullInt64等），DECIMAL等以字符串扫描的类型不视为数值
+// @param t reflect.Type
+// @return bool
+func _isNumericType(t reflect.Type) bool {
+	if t == nil {
+		return false
+	}
+	switch t {
+	case reflect.TypeOf(sql.NullInt64{}), reflect.TypeOf(sql.NullInt32{}), reflect.TypeOf(sql.NullInt16{}),
+		reflect.TypeOf(sql.NullByte{}), reflect.TypeOf(sql.NullFloat64{}):
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// ExportCsvStream 流式导出CSV，边读取边写入响应
+// @param ctx *beegoContext.Context
+// @param fileName string 不含扩展名的文件名
+// @param title []string 表头，为空时不写入
+// @param rows RowIterator
+// @param opts CsvOptions
+// @return error
+func ExportCsvStream(ctx *beegoContext.Context, fileName string, title []string, rows RowIterator, opts CsvOptions) error {
+	//编码错误时在写入响应头之前返回
+	if _, err := NewCsvWriter(io.Discard, opts); err != nil {
+		return err
+	}
+	return ExportWith(ctx, &CsvExporter{Options: opts}, fileName, title, rows)
+}
+
+// ContentDisposition 生成附件下载的Content-Disposition（RFC 6266），同时提供ASCII文件名和UTF-8编码的filename*
+// @param fileName string
+// @return string
+func ContentDisposition(fileName string) string {
+	fallback := strings.Map(func(r rune) rune {
+		if r > 0x7e || r < 0x20 || r == '"' || r == '\\' || r == '%' {
+			return '_'
+		}
+		return r
+	}, fileName)
+	var encoded strings.Builder
+	for _, b := range []byte(fileName) {
+		//RFC 5987 attr-char
+		if b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9' || strings.IndexByte("!#$&+-.^_`|~", b) >= 0 {
+			encoded.WriteByte(b)
+		} else {
+			fmt.Fprintf(&encoded, "%%%02X", b)
+		}
+	}
+	return fmt.Sprintf(`attachment; filename="%s"; filename*=UTF-8''%s`, fallback, encoded.String())
+}
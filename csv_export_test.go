@@ -0,0 +1,45 @@
+/**
+ * Created by goland.
+ * User: adam_wang
+ * Date: 2023-09-26 21:18:57
+ */
+
+package tool
+
+import (
+	"mime"
+	"testing"
+)
+
+func TestContentDisposition(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileName string
+		want     string
+	}{
+		{"ascii", "report.csv", `attachment; filename="report.csv"; filename*=UTF-8''report.csv`},
+		{"space", "my report.csv", `attachment; filename="my report.csv"; filename*=UTF-8''my%20report.csv`},
+		{"chinese", "用户.csv", `attachment; filename="__.csv"; filename*=UTF-8''%E7%94%A8%E6%88%B7.csv`},
+		{"quote and backslash", `a"b\c.csv`, `attachment; filename="a_b_c.csv"; filename*=UTF-8''a%22b%5Cc.csv`},
+		{"percent", "100%.csv", `attachment; filename="100_.csv"; filename*=UTF-8''100%25.csv`},
+		{"control character", "a\r\nb.csv", `attachment; filename="a__b.csv"; filename*=UTF-8''a%0D%0Ab.csv`},
+		{"attr chars", "a!#$&+-.^_`|~b", "attachment; filename=\"a!#$&+-.^_`|~b\"; filename*=UTF-8''a!#$&+-.^_`|~b"},
+		{"separators", "a;b=c(d).csv", `attachment; filename="a;b=c(d).csv"; filename*=UTF-8''a%3Bb%3Dc%28d%29.csv`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ContentDisposition(tt.fileName)
+			if got != tt.want {
+				t.Fatalf("ContentDisposition(%q) = %s\nwant %s", tt.fileName, got, tt.want)
+			}
+			//浏览器优先使用filename*，解析后应还原原始文件名
+			disposition, params, err := mime.ParseMediaType(got)
+			if err != nil {
+				t.Fatalf("ParseMediaType(%s) error = %v", got, err)
+			}
+			if disposition != "attachment" || params["filename"] != tt.fileName {
+				t.Errorf("ParseMediaType(%s) = %s, filename %q, want attachment, %q", got, disposition, params["filename"], tt.fileName)
+			}
+		})
+	}
+}
@@ -8,90 +8,57 @@ package tool
 
 import (
 	"bytes"
-	"encoding/csv"
 	"fmt"
 	beegoContext "github.com/beego/beego/v2/server/web/context"
 	"github.com/tealeg/xlsx/v3"
-	"golang.org/x/text/encoding/simplifiedchinese"
-	"golang.org/x/text/transform"
-	"io/ioutil"
 	"net/http"
 	"time"
 )
 
-// ExportCsv 数据导出CSV
+// ExportCsv 数据导出CSV（GBK编码），不会修改传入的数据，存在无法编码的字符时返回错误
 // @param ctx *beegoContext.Context
 // @param title []string
 // @param dataList [][]string
 // @param fileName string
 // @return error
 func ExportCsv(ctx *beegoContext.Context, title []string, dataList [][]string, fileName string) error {
-	//处理基础数据
-	fileNameGbk, _ := ioutil.ReadAll(transform.NewReader(bytes.NewReader([]byte(fileName)), simplifiedchinese.GBK.NewEncoder()))
-	fileName = string(fileNameGbk)
-	for k, col := range title {
-		t, _ := ioutil.ReadAll(transform.NewReader(bytes.NewReader([]byte(col)), simplifiedchinese.GBK.NewEncoder()))
-		title[k] = string(t)
-	}
-
-	//设置头信息
-	ctx.Output.Header("Content-Type", "application/csv")
-	ctx.Output.Header("Content-Disposition", "attachment; filename="+string(fileName)+".csv")
-
-	//写入数据流
-	writer := csv.NewWriter(ctx.ResponseWriter)
-	err := writer.Write(title)
-	if err != nil {
-		return err
-	}
-	for _, items := range dataList {
-		for key, item := range items {
-			itemArr, _ := ioutil.ReadAll(transform.NewReader(bytes.NewReader([]byte(item)), simplifiedchinese.GBK.NewEncoder()))
-			items[key] = string(itemArr)
-		}
-		err := writer.Write(items)
-		if err != nil {
-			return err
-		}
-		writer.Flush()
-	}
-	return nil
+	return ExportCsvStream(ctx, fileName, title, RowsFromSlice(dataList), CsvOptions{Encoding: CsvGBK})
 }
 
-// ExportExcel 数据导出excel
+// ExportExcel 数据导出excel（表头加粗并冻结，列宽按内容自动调整），需要多工作表、样式等设置时使用NewExcelBuilder
 // @param w http.ResponseWriter
 // @param r *http.Request
 // @param titleList []string
 // @param dataList [][]interface{}
 // @param fileName string
-func ExportExcel(w http.ResponseWriter, r *http.Request, titleList []string, dataList [][]interface{}, fileName string) {
-	// 生成一个新的文件
-	file := xlsx.NewFile()
-
-	// 添加sheet页
-	sheet, _ := file.AddSheet("Sheet1")
+// @return error
+func ExportExcel(w http.ResponseWriter, r *http.Request, titleList []string, dataList [][]interface{}, fileName string) error {
+	columns := make([]ExcelColumn, len(titleList))
+	for i, title := range titleList {
+		columns[i] = ExcelColumn{Title: title}
+	}
 
-	// 插入表头
-	titleRow := sheet.AddRow()
-	titleRow.WriteSlice(titleList, -1)
-	//单独单元格赋值同时设置单元格样式等
-	//for _, v := range titleList {
-	//	cell := titleRow.AddCell()
-	//	cell.Value = v
-	//	//cell.GetStyle().Font.Color = "00FF0000"
-	//}
+	builder := NewExcelBuilder()
+	builder.Sheet("Sheet1").Columns(columns...).AddRows(dataList)
+	return builder.Export(w, r, fileName)
+}
 
-	// 插入内容
-	for _, data := range dataList {
-		row := sheet.AddRow()
-		row.WriteSlice(data, -1)
-	}
+// _writeExcel 将excel文件写入响应
+// @param w http.ResponseWriter
+// @param r *http.Request
+// @param file *xlsx.File
+// @param fileName string 不含扩展名的文件名
+// @return error
+func _writeExcel(w http.ResponseWriter, r *http.Request, file *xlsx.File, fileName string) error {
 	fileName = fmt.Sprintf("%s.xlsx", fileName)
-	w.Header().Add("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, fileName))
-	w.Header().Add("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
-
 	var buffer bytes.Buffer
-	_ = file.Write(&buffer)
+	if err := file.Write(&buffer); err != nil {
+		return err
+	}
+
+	w.Header().Add("Content-Disposition", ContentDisposition(fileName))
+	w.Header().Add("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
 	content := bytes.NewReader(buffer.Bytes())
 	http.ServeContent(w, r, fileName, time.Now(), content)
+	return nil
 }
@@ -0,0 +1,482 @@
+/**
+ * Created by goland.
+ * User: adam_wang
+ * Date: 2023-09-19 21:27:40
+ */
+
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/beego/beego/v2/client/orm"
+	"github.com/beego/beego/v2/core/logs"
+	"github.com/redis/go-redis/v9"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 审计操作类型
+const (
+	AuditActionInsert      = "insert"
+	AuditActionUpdate      = "update"
+	AuditActionDelete      = "delete"
+	AuditActionForceDelete = "force_delete"
+	AuditActionRestore     = "restore"
+)
+
+// AuditRedactValue 脱敏字段记录的值
+var AuditRedactValue = "******"
+
+// AuditRedactFields 所有审计模型都需要脱敏的字段（字段名或列名）
+var AuditRedactFields = []string{"password"}
+
+// AuditOptions 模型审计配置
+type AuditOptions struct {
+	Redact []string // 需要脱敏的字段（字段名或列名）
+	Ignore []string // 不记录的字段（字段名或列名）
+}
+
+// AuditMeta 审计请求信息
+type AuditMeta struct {
+	UserID int64  `json:"user_id"`
+	IP     string `json:"ip"`
+	Method string `json:"method"`
+	Path   string `json:"path"`
+}
+
+// AuditChange 字段变更
+type AuditChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// AuditEntry 审计记录
+type AuditEntry struct {
+	Table     string                 `json:"table"`
+	PK        string                 `json:"pk"`
+	Action    string                 `json:"action"`
+	Changes   map[string]AuditChange `json:"changes"`
+	Meta      AuditMeta              `json:"meta"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+// AuditSource 审计请求信息的来源，beego的ctx.Input（*context.BeegoInput）实现了该接口
+type AuditSource interface {
+	IP() string
+	Method() string
+	URL() string
+}
+
+// AuditWriter 审计记录写入器
+type AuditWriter interface {
+	Write(entries []AuditEntry) error
+}
+
+// auditMetaContextKey 上下文中保存审计请求信息的key
+type auditMetaContextKey struct{}
+
+// auditor 异步审计记录器
+type auditor struct {
+	writer  AuditWriter
+	entries chan AuditEntry
+	done    chan struct{}
+}
+
+var (
+	auditModels   = make(map[reflect.Type]AuditOptions)
+	auditModelsMu sync.RWMutex
+	currentAudit  *auditor
+	auditMu       sync.RWMutex
+)
+
+// RegisterAuditModel 注册需要审计的模型，通过InsertModel、UpdateModel、DeleteModel、ForceDeleteModel、RestoreModel的写操作会被记录
+// @param md interface{}
+// @param opts AuditOptions
+func RegisterAuditModel(md interface{}, opts AuditOptions) {
+	typ := reflect.Indirect(reflect.ValueOf(md)).Type()
+	auditModelsMu.Lock()
+	auditModels[typ] = opts
+	auditModelsMu.Unlock()
+}
+
+// StartAudit 启动异步审计，bufferSize为缓冲的记录数（缓冲满时丢弃并记录日志）
+// @param writer AuditWriter
+// @param bufferSize int
+func StartAudit(writer AuditWriter, bufferSize int) {
+	if bufferSize <= 0 {
+		bufferSize = 1024
+	}
+	a := &auditor{writer: writer, entries: make(chan AuditEntry, bufferSize), done: make(chan struct{})}
+
+	auditMu.Lock()
+	previous := currentAudit
+	currentAudit = a
+	auditMu.Unlock()
+
+	if previous != nil {
+		previous.stop()
+	}
+	go a.run()
+}
+
+// StopAudit 停止异步审计并等待缓冲中的记录写入完成
+func StopAudit() {
+	auditMu.Lock()
+	a := currentAudit
+	currentAudit = nil
+	auditMu.Unlock()
+
+	if a != nil {
+		a.stop()
+	}
+}
+
+// WithAuditMeta 获取携带审计请求信息的上下文，同时设置操作人
+// @param ctx context.Context
+// @param meta AuditMeta
+// @return context.Context
+func WithAuditMeta(ctx context.Context, meta AuditMeta) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx = context.WithValue(ctx, auditMetaContextKey{}, meta)
+	if meta.UserID != 0 {
+		ctx = WithActor(ctx, meta.UserID)
+	}
+	return ctx
+}
+
+// AuditContext 获取携带审计请求信息（用户、IP、请求方法、路径）的上下文
+// @param ctx context.Context 如c.Ctx.Request.Context()
+// @param source AuditSource 如c.Ctx.Input
+// @param userID int64
+// @return context.Context
+func AuditContext(ctx context.Context, source AuditSource, userID int64) context.Context {
+	return WithAuditMeta(ctx, AuditMeta{UserID: userID, IP: source.IP(), Method: source.Method(), Path: source.URL()})
+}
+
+// AuditMetaFromContext 获取上下文中的审计请求信息
+// @param ctx context.Context
+// @return AuditMeta
+func AuditMetaFromContext(ctx context.Context) AuditMeta {
+	if ctx != nil {
+		if meta, ok := ctx.Value(auditMetaContextKey{}).(AuditMeta); ok {
+			return meta
+		}
+	}
+	meta := AuditMeta{}
+	meta.UserID, _ = ActorFromContext(ctx)
+	return meta
+}
+
+// run 批量写入审计记录
+// @receiver a *auditor
+func (a *auditor) run() {
+	defer close(a.done)
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	var batch []AuditEntry
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := a.writer.Write(batch); err != nil {
+			logs.Error("failed to write %d audit entries: %v", len(batch), err)
+		}
+		batch = nil
+	}
+
+	for {
+		select {
+		case entry, ok := <-a.entries:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, entry)
+			if len(batch) >= 100 {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// stop 关闭并等待写入完成
+// @receiver a *auditor
+func (a *auditor) stop() {
+	close(a.entries)
+	<-a.done
+}
+
+// _auditEnabled 模型是否需要审计
+// @param md interface{}
+// @return AuditOptions
+// @return bool
+func _auditEnabled(md interface{}) (AuditOptions, bool) {
+	auditMu.RLock()
+	running := currentAudit != nil
+	auditMu.RUnlock()
+	if !running {
+		return AuditOptions{}, false
+	}
+
+	typ := reflect.Indirect(reflect.ValueOf(md)).Type()
+	auditModelsMu.RLock()
+	opts, ok := auditModels[typ]
+	auditModelsMu.RUnlock()
+	return opts, ok
+}
+
+// _auditBefore 写操作前读取数据库中的原始数据，读写分离时在主库读取，避免读到从库延迟的旧数据
+// @param ctx context.Context
+// @param o orm.QueryExecutor
+// @param md interface{}
+// @return map[string]interface{}
+func _auditBefore(ctx context.Context, o orm.QueryExecutor, md interface{}) map[string]interface{} {
+	if _, ok := _auditEnabled(md); !ok {
+		return nil
+	}
+	meta, err := _getModelMeta(md)
+	if err != nil || meta.Pk == nil {
+		return nil
+	}
+
+	before := reflect.New(meta.Type)
+	before.Elem().FieldByIndex(meta.Pk.Index).Set(reflect.ValueOf(md).Elem().FieldByIndex(meta.Pk.Index))
+	if err = _primaryExecutor(o).ReadWithCtx(ctx, before.Interface()); err != nil {
+		return nil
+	}
+	return _auditSnapshot(meta, before.Interface())
+}
+
+// _auditRecord 写操作成功后生成审计记录，在WithTx创建的事务中时，提交成功后才加入审计队列
+// @param ctx context.Context
+// @param o orm.QueryExecutor
+// @param action string
+// @param md interface{}
+// @param before map[string]interface{}
+// @param cols []string 只记录这些字段的变更（为空时记录全部字段）
+// @param removed bool 数据是否已被物理删除
+func _auditRecord(ctx context.Context, o orm.QueryExecutor, action string, md interface{}, before map[string]interface{}, cols []string, removed bool) {
+	opts, ok := _auditEnabled(md)
+	if !ok {
+		return
+	}
+	meta, err := _getModelMeta(md)
+	if err != nil {
+		return
+	}
+
+	var after map[string]interface{}
+	if !removed {
+		after = _auditSnapshot(meta, md)
+	}
+
+	only := make(map[string]bool, len(cols))
+	for _, col := range cols {
+		if field := meta.Column(col); field != nil {
+			only[field.Column] = true
+		}
+	}
+	skip := make(map[string]bool)
+	for _, name := range opts.Ignore {
+		if field := meta.Column(name); field != nil {
+			skip[field.Column] = true
+		}
+	}
+	redact := make(map[string]bool)
+	for _, name := range append(append([]string{}, AuditRedactFields...), opts.Redact...) {
+		if field := meta.Column(name); field != nil {
+			redact[field.Column] = true
+		}
+	}
+
+	changes := make(map[string]AuditChange)
+	for _, field := range meta.Fields {
+		column := field.Column
+		if skip[column] || (len(only) > 0 && !only[column]) {
+			continue
+		}
+		beforeValue, afterValue := before[column], after[column]
+		if before != nil && after != nil && reflect.DeepEqual(beforeValue, afterValue) {
+			continue
+		}
+		if redact[column] {
+			if beforeValue != nil {
+				beforeValue = AuditRedactValue
+			}
+			if afterValue != nil {
+				afterValue = AuditRedactValue
+			}
+		}
+		changes[column] = AuditChange{Before: beforeValue, After: afterValue}
+	}
+	if len(changes) == 0 {
+		return
+	}
+
+	entry := AuditEntry{
+		Table:     meta.Table,
+		Action:    action,
+		Changes:   changes,
+		Meta:      AuditMetaFromContext(ctx),
+		CreatedAt: time.Now(),
+	}
+	if meta.Pk != nil {
+		entry.PK = fmt.Sprint(reflect.ValueOf(md).Elem().FieldByIndex(meta.Pk.Index).Interface())
+	}
+
+	if tx, ok := o.(*Tx); ok {
+		_ = AfterCommit(tx, func(context.Context) {
+			_auditEnqueue(entry)
+		})
+		return
+	}
+	_auditEnqueue(entry)
+}
+
+// _auditEnqueue 将审计记录加入异步写入队列
+// @param entry AuditEntry
+func _auditEnqueue(entry AuditEntry) {
+	auditMu.RLock()
+	defer auditMu.RUnlock()
+	if currentAudit == nil {
+		return
+	}
+	select {
+	case currentAudit.entries <- entry:
+	default:
+		logs.Warn("audit buffer is full, entry for %s %s dropped", entry.Table, entry.PK)
+	}
+}
+
+// _auditSnapshot 获取模型各字段的值（时间格式化为字符串，指针取值）
+// @param meta *modelMeta
+// @param md interface{}
+// @return map[string]interface{}
+func _auditSnapshot(meta *modelMeta, md interface{}) map[string]interface{} {
+	val := reflect.Indirect(reflect.ValueOf(md))
+	snapshot := make(map[string]interface{}, len(meta.Fields))
+	for _, field := range meta.Fields {
+		if field.Rel {
+			continue
+		}
+		value := val.FieldByIndex(field.Index)
+		if value.Kind() == reflect.Ptr {
+			if value.IsNil() {
+				snapshot[field.Column] = nil
+				continue
+			}
+			value = value.Elem()
+		}
+		if t, ok := value.Interface().(time.Time); ok {
+			snapshot[field.Column] = t.Format("2006-01-02 15:04:05")
+			continue
+		}
+		snapshot[field.Column] = value.Interface()
+	}
+	return snapshot
+}
+
+// MySQLAuditWriter 将审计记录写入MySQL表
+type MySQLAuditWriter struct {
+	Alias string // 数据库别名，默认为default
+	Table string // 审计表，默认为audit_logs
+
+	once    sync.Once
+	initErr error
+}
+
+// Write 批量写入审计记录，首次写入时自动创建审计表
+// @receiver w *MySQLAuditWriter
+// @param entries []AuditEntry
+// @return error
+func (w *MySQLAuditWriter) Write(entries []AuditEntry) error {
+	alias, table := w.Alias, w.Table
+	if alias == "" {
+		alias = "default"
+	}
+	if table == "" {
+		table = "audit_logs"
+	}
+	o := orm.NewOrmUsingDB(alias)
+
+	w.once.Do(func() {
+		_, w.initErr = o.Raw("CREATE TABLE IF NOT EXISTS `" + table + "` (" +
+			"`id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY," +
+			"`table_name` VARCHAR(128) NOT NULL," +
+			"`pk` VARCHAR(64) NOT NULL," +
+			"`action` VARCHAR(16) NOT NULL," +
+			"`changes` JSON NOT NULL," +
+			"`user_id` BIGINT NOT NULL DEFAULT 0," +
+			"`ip` VARCHAR(64) NOT NULL DEFAULT ''," +
+			"`method` VARCHAR(16) NOT NULL DEFAULT ''," +
+			"`path` VARCHAR(255) NOT NULL DEFAULT ''," +
+			"`created_at` DATETIME NOT NULL," +
+			"KEY `idx_table_pk` (`table_name`, `pk`)" +
+			") ENGINE=InnoDB").Exec()
+	})
+	if w.initErr != nil {
+		return w.initErr
+	}
+
+	placeholders := make([]string, 0, len(entries))
+	args := make([]interface{}, 0, len(entries)*9)
+	for _, entry := range entries {
+		changes, err := json.Marshal(entry.Changes)
+		if err != nil {
+			return err
+		}
+		placeholders = append(placeholders, "(?, ?, ?, ?, ?, ?, ?, ?, ?)")
+		args = append(args, entry.Table, entry.PK, entry.Action, string(changes),
+			entry.Meta.UserID, entry.Meta.IP, entry.Meta.Method, entry.Meta.Path, entry.CreatedAt)
+	}
+	_, err := o.Raw("INSERT INTO `"+table+"` (table_name, pk, action, changes, user_id, ip, method, path, created_at) VALUES "+
+		strings.Join(placeholders, ", "), args...).Exec()
+	return err
+}
+
+// RedisStreamAuditWriter 将审计记录写入redis stream
+type RedisStreamAuditWriter struct {
+	Stream string // stream名称（会添加redis key前缀）
+	MaxLen int64  // stream最大长度（近似裁剪），为0时不限制
+}
+
+// Write 批量写入审计记录
+// @receiver w *RedisStreamAuditWriter
+// @param entries []AuditEntry
+// @return error
+func (w *RedisStreamAuditWriter) Write(entries []AuditEntry) error {
+	if rdb == nil {
+		return errors.New("redis is not initialized")
+	}
+	stream := w.Stream
+	if redisKey != "" {
+		stream = redisKey + ":" + stream
+	}
+
+	pipe := rdb.Pipeline()
+	for _, entry := range entries {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream: stream,
+			MaxLen: w.MaxLen,
+			Approx: w.MaxLen > 0,
+			Values: map[string]interface{}{"table": entry.Table, "action": entry.Action, "entry": string(data)},
+		})
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
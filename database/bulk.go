@@ -0,0 +1,305 @@
+/**
+ * Created by goland.
+ * User: adam_wang
+ * Date: 2023-09-23 16:48:12
+ */
+
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/beego/beego/v2/client/orm"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// BulkOptions 批量写入配置
+type BulkOptions struct {
+	Alias           string   // 数据库别名，默认为default
+	BatchSize       int      // 每批条数，默认为500，超出MySQL单条语句的占位符上限（65535）时自动减小
+	UpdateColumns   []string // Upsert时发生唯一键冲突需要更新的字段（字段名或列名），为空时更新除主键外的全部字段
+	Transaction     bool     // 所有批次在同一个事务中执行（任一批次失败则全部回滚）
+	ContinueOnError bool     // 非事务模式下某一批次失败后继续执行后续批次
+}
+
+// BatchError 批次错误
+type BatchError struct {
+	Batch  int   // 批次序号（从0开始）
+	Offset int   // 批次第一条数据在切片中的下标
+	Size   int   // 批次条数
+	Err    error // 错误
+}
+
+// Error 错误信息
+// @receiver e *BatchError
+// @return string
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("batch %d (rows %d-%d): %v", e.Batch, e.Offset, e.Offset+e.Size-1, e.Err)
+}
+
+// Unwrap 获取原始错误
+// @receiver e *BatchError
+// @return error
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// BulkResult 批量写入结果
+type BulkResult struct {
+	Batches  int          // 执行的批次数
+	Affected int64        // MySQL返回的影响行数；Upsert时插入的行计1、更新的行计2、未变化的行计0
+	Inserted int64        // 插入的行数，Upsert时按影响行数推算（见BulkUpsert）
+	Updated  int64        // 更新的行数，Upsert时按影响行数推算（见BulkUpsert）
+	Errors   []BatchError // 失败的批次
+}
+
+// _maxPlaceholders MySQL单条预处理语句的占位符上限
+const _maxPlaceholders = 65535
+
+// BulkInsert 分批插入模型切片
+// @param ctx context.Context
+// @param models interface{} 模型切片，如[]*User
+// @param opts BulkOptions
+// @return *BulkResult
+// @return error
+func BulkInsert(ctx context.Context, models interface{}, opts BulkOptions) (*BulkResult, error) {
+	return _bulkWrite(ctx, models, opts, false)
+}
+
+// BulkUpsert 分批插入模型切片，唯一键冲突时更新指定字段（INSERT ... ON DUPLICATE KEY UPDATE）
+// MySQL只返回每批的影响行数（插入计1、更新计2、未变化计0），Inserted、Updated按每批N行推算：Updated = 影响行数 - N，Inserted = N - Updated；
+// 推算的前提是未开启clientFoundRows（开启后未变化的行也计1），且批次中没有值未变化的冲突行，
+// 每有一行未变化，Updated少计1、Inserted多计2，需要精确统计时请先查询已存在的数据
+// @param ctx context.Context
+// @param models interface{} 模型切片，如[]*User
+// @param opts BulkOptions
+// @return *BulkResult
+// @return error
+func BulkUpsert(ctx context.Context, models interface{}, opts BulkOptions) (*BulkResult, error) {
+	return _bulkWrite(ctx, models, opts, true)
+}
+
+// _bulkWrite 分批生成并执行批量写入语句
+// @param ctx context.Context
+// @param models interface{}
+// @param opts BulkOptions
+// @param upsert bool
+// @return *BulkResult
+// @return error
+func _bulkWrite(ctx context.Context, models interface{}, opts BulkOptions, upsert bool) (*BulkResult, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	list := reflect.Indirect(reflect.ValueOf(models))
+	if list.Kind() != reflect.Slice {
+		return nil, errors.New("bulk: models must be a slice")
+	}
+	meta, err := _getModelMeta(models)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Alias == "" {
+		opts.Alias = "default"
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 500
+	}
+	if len(meta.Fields) > 0 && opts.BatchSize*len(meta.Fields) > _maxPlaceholders {
+		opts.BatchSize = _maxPlaceholders / len(meta.Fields)
+	}
+
+	var updateColumns []string
+	if upsert {
+		if updateColumns, err = _bulkUpdateColumns(meta, opts.UpdateColumns); err != nil {
+			return nil, err
+		}
+	}
+
+	if list.Len() == 0 {
+		return &BulkResult{}, nil
+	}
+
+	if opts.Transaction {
+		var result *BulkResult
+		err = WithTx(ctx, opts.Alias, func(tx orm.TxOrmer) error {
+			var execErr error
+			result, execErr = _bulkExec(tx, meta, list, opts, updateColumns, upsert)
+			return execErr
+		})
+		if result == nil {
+			result = &BulkResult{}
+		}
+		if err != nil {
+			result.Inserted, result.Updated, result.Affected = 0, 0, 0
+		}
+		return result, err
+	}
+
+	result, err := _bulkExec(orm.NewOrmUsingDB(opts.Alias), meta, list, opts, updateColumns, upsert)
+	if err == nil && len(result.Errors) > 0 {
+		err = fmt.Errorf("bulk: %d of %d batches failed, first error: %w", len(result.Errors), result.Batches, &result.Errors[0])
+	}
+	return result, err
+}
+
+// _bulkExec 分批执行批量写入语句，每次调用（包括WithTx重试）都返回新的结果，不会累加上一次执行的统计
+// @param o orm.QueryExecutor
+// @param meta *modelMeta
+// @param list reflect.Value
+// @param opts BulkOptions
+// @param updateColumns []string
+// @param upsert bool
+// @return *BulkResult
+// @return error
+func _bulkExec(o orm.QueryExecutor, meta *modelMeta, list reflect.Value, opts BulkOptions, updateColumns []string, upsert bool) (*BulkResult, error) {
+	result := &BulkResult{}
+	for offset, batch := 0, 0; offset < list.Len(); offset, batch = offset+opts.BatchSize, batch+1 {
+		end := offset + opts.BatchSize
+		if end > list.Len() {
+			end = list.Len()
+		}
+		query, args := _bulkSQL(meta, list.Slice(offset, end), updateColumns)
+		res, err := o.Raw(query, args...).Exec()
+		result.Batches++
+		if err != nil {
+			batchErr := BatchError{Batch: batch, Offset: offset, Size: end - offset, Err: err}
+			result.Errors = append(result.Errors, batchErr)
+			if opts.Transaction || !opts.ContinueOnError {
+				return result, &batchErr
+			}
+			continue
+		}
+
+		affected, _ := res.RowsAffected()
+		result.Affected += affected
+		if !upsert {
+			result.Inserted += affected
+			continue
+		}
+		inserted, updated := _upsertCounts(affected, int64(end-offset))
+		result.Inserted += inserted
+		result.Updated += updated
+	}
+	return result, nil
+}
+
+// _upsertCounts 按批次的影响行数推算Upsert插入及更新的行数（未变化的行无法区分，见BulkUpsert）
+// @param affected int64 批次的影响行数
+// @param rows int64 批次条数
+// @return int64 插入的行数
+// @return int64 更新的行数
+func _upsertCounts(affected, rows int64) (int64, int64) {
+	updated := affected - rows
+	if updated < 0 {
+		updated = 0
+	}
+	if updated > rows {
+		updated = rows
+	}
+	return rows - updated, updated
+}
+
+// _bulkUpdateColumns 解析Upsert需要更新的列
+// @param meta *modelMeta
+// @param names []string
+// @return []string
+// @return error
+func _bulkUpdateColumns(meta *modelMeta, names []string) ([]string, error) {
+	var columns []string
+	if len(names) == 0 {
+		for _, field := range meta.Fields {
+			if !field.Pk && !field.AutoNowAdd {
+				columns = append(columns, field.Column)
+			}
+		}
+		return columns, nil
+	}
+
+	for _, name := range names {
+		field := meta.Column(name)
+		if field == nil {
+			return nil, fmt.Errorf("bulk: unknown update column %q", name)
+		}
+		columns = append(columns, field.Column)
+	}
+	return columns, nil
+}
+
+// _bulkSQL 生成一个批次的INSERT语句及参数
+// @param meta *modelMeta
+// @param rows reflect.Value
+// @param updateColumns []string
+// @return string
+// @return []interface{}
+func _bulkSQL(meta *modelMeta, rows reflect.Value, updateColumns []string) (string, []interface{}) {
+	//自增主键只有在批次中存在非零值时才写入
+	includePk := meta.Pk == nil || !meta.Pk.Auto
+	if !includePk {
+		for i := 0; i < rows.Len(); i++ {
+			if !reflect.Indirect(rows.Index(i)).FieldByIndex(meta.Pk.Index).IsZero() {
+				includePk = true
+				break
+			}
+		}
+	}
+
+	var fields []modelField
+	var columns []string
+	for _, field := range meta.Fields {
+		if field.Pk && !includePk {
+			continue
+		}
+		fields = append(fields, field)
+		columns = append(columns, "`"+field.Column+"`")
+	}
+
+	now := time.Now()
+	placeholder := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(fields)), ", ") + ")"
+	placeholders := make([]string, 0, rows.Len())
+	args := make([]interface{}, 0, rows.Len()*len(fields))
+	for i := 0; i < rows.Len(); i++ {
+		row := reflect.Indirect(rows.Index(i))
+		for _, field := range fields {
+			value := row.FieldByIndex(field.Index)
+			if (field.AutoNow || field.AutoNowAdd) && value.CanSet() && value.Type() == reflect.TypeOf(now) && value.IsZero() {
+				value.Set(reflect.ValueOf(now))
+			}
+			args = append(args, _bulkValue(field, value))
+		}
+		placeholders = append(placeholders, placeholder)
+	}
+
+	query := "INSERT INTO `" + meta.Table + "` (" + strings.Join(columns, ", ") + ") VALUES " + strings.Join(placeholders, ", ")
+	if len(updateColumns) > 0 {
+		updates := make([]string, 0, len(updateColumns))
+		for _, column := range updateColumns {
+			updates = append(updates, "`"+column+"` = VALUES(`"+column+"`)")
+		}
+		query += " ON DUPLICATE KEY UPDATE " + strings.Join(updates, ", ")
+	}
+	return query, args
+}
+
+// _bulkValue 获取字段写入数据库的值，关联字段取关联模型的主键
+// @param field modelField
+// @param value reflect.Value
+// @return interface{}
+func _bulkValue(field modelField, value reflect.Value) interface{} {
+	if field.Rel {
+		if value.Kind() != reflect.Ptr || value.IsNil() {
+			return nil
+		}
+		relMeta, err := _getModelMeta(value.Interface())
+		if err != nil || relMeta.Pk == nil {
+			return nil
+		}
+		return value.Elem().FieldByIndex(relMeta.Pk.Index).Interface()
+	}
+	if value.Kind() == reflect.Ptr && value.IsNil() {
+		return nil
+	}
+	return value.Interface()
+}
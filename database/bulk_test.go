@@ -0,0 +1,187 @@
+/**
+ * Created by goland.
+ * User: adam_wang
+ * Date: 2023-09-23 16:48:12
+ */
+
+package database
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"github.com/beego/beego/v2/client/orm"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type bulkTestGroup struct {
+	Id int64
+}
+
+type bulkTestUser struct {
+	Id        int64
+	Name      string         `orm:"size(32)"`
+	Nickname  *string        `orm:"null"`
+	Group     *bulkTestGroup `orm:"rel(fk)"`
+	CreatedAt time.Time      `orm:"auto_now_add"`
+	Ignored   string         `orm:"-"`
+}
+
+// bulkTestExecutor 按顺序返回各批次影响行数的QueryExecutor
+type bulkTestExecutor struct {
+	orm.QueryExecutor
+	results []int64
+	errs    []error
+	calls   int
+	queries []string
+}
+
+func (e *bulkTestExecutor) Raw(query string, args ...interface{}) orm.RawSeter {
+	e.queries = append(e.queries, query)
+	return &bulkTestRawSeter{executor: e}
+}
+
+type bulkTestRawSeter struct {
+	orm.RawSeter
+	executor *bulkTestExecutor
+}
+
+func (r *bulkTestRawSeter) Exec() (sql.Result, error) {
+	e := r.executor
+	i := e.calls % len(e.results)
+	e.calls++
+	if i < len(e.errs) && e.errs[i] != nil {
+		return nil, e.errs[i]
+	}
+	return driver.RowsAffected(e.results[i]), nil
+}
+
+func TestBulkSQL(t *testing.T) {
+	meta, err := _getModelMeta(&bulkTestUser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := time.Date(2023, 9, 23, 16, 48, 12, 0, time.Local)
+	nickname := "adam"
+
+	tests := []struct {
+		name          string
+		rows          []*bulkTestUser
+		updateColumns []string
+		wantQuery     string
+		wantArgs      []interface{}
+	}{
+		{
+			name:      "auto pk omitted",
+			rows:      []*bulkTestUser{{Name: "a", Group: &bulkTestGroup{Id: 3}, CreatedAt: created}, {Name: "b", Nickname: &nickname, CreatedAt: created}},
+			wantQuery: "INSERT INTO `bulk_test_user` (`name`, `nickname`, `group_id`, `created_at`) VALUES (?, ?, ?, ?), (?, ?, ?, ?)",
+			wantArgs:  []interface{}{"a", nil, int64(3), created, "b", &nickname, nil, created},
+		},
+		{
+			name:      "explicit pk kept",
+			rows:      []*bulkTestUser{{Name: "a", CreatedAt: created}, {Id: 9, Name: "b", CreatedAt: created}},
+			wantQuery: "INSERT INTO `bulk_test_user` (`id`, `name`, `nickname`, `group_id`, `created_at`) VALUES (?, ?, ?, ?, ?), (?, ?, ?, ?, ?)",
+			wantArgs:  []interface{}{int64(0), "a", nil, nil, created, int64(9), "b", nil, nil, created},
+		},
+		{
+			name:          "upsert",
+			rows:          []*bulkTestUser{{Id: 1, Name: "a", CreatedAt: created}},
+			updateColumns: []string{"name", "nickname"},
+			wantQuery: "INSERT INTO `bulk_test_user` (`id`, `name`, `nickname`, `group_id`, `created_at`) VALUES (?, ?, ?, ?, ?)" +
+				" ON DUPLICATE KEY UPDATE `name` = VALUES(`name`), `nickname` = VALUES(`nickname`)",
+			wantArgs: []interface{}{int64(1), "a", nil, nil, created},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, args := _bulkSQL(meta, reflect.ValueOf(tt.rows), tt.updateColumns)
+			if query != tt.wantQuery {
+				t.Errorf("query = %s\nwant %s", query, tt.wantQuery)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("args = %v, want %v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestBulkSQLFillsAutoNowAdd(t *testing.T) {
+	meta, err := _getModelMeta(&bulkTestUser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := []*bulkTestUser{{Name: "a"}}
+	_, args := _bulkSQL(meta, reflect.ValueOf(rows), nil)
+	if rows[0].CreatedAt.IsZero() || args[len(args)-1] != rows[0].CreatedAt {
+		t.Errorf("CreatedAt = %v, args = %v, want auto_now_add to be filled", rows[0].CreatedAt, args)
+	}
+}
+
+func TestUpsertCounts(t *testing.T) {
+	tests := []struct {
+		affected, rows            int64
+		wantInserted, wantUpdated int64
+	}{
+		{3, 3, 3, 0},
+		{6, 3, 0, 3},
+		{4, 3, 2, 1},
+		{2, 3, 3, 0},
+		{0, 3, 3, 0},
+		{7, 3, 0, 3},
+	}
+	for _, tt := range tests {
+		inserted, updated := _upsertCounts(tt.affected, tt.rows)
+		if inserted != tt.wantInserted || updated != tt.wantUpdated {
+			t.Errorf("_upsertCounts(%d, %d) = (%d, %d), want (%d, %d)",
+				tt.affected, tt.rows, inserted, updated, tt.wantInserted, tt.wantUpdated)
+		}
+	}
+}
+
+func TestBulkExecResetsResultPerAttempt(t *testing.T) {
+	meta, err := _getModelMeta(&bulkTestUser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := make([]*bulkTestUser, 5)
+	for i := range rows {
+		rows[i] = &bulkTestUser{Id: int64(i + 1), Name: "u"}
+	}
+	list := reflect.ValueOf(rows)
+	opts := BulkOptions{BatchSize: 2, Transaction: true}
+	updateColumns := []string{"name"}
+
+	tests := []struct {
+		name    string
+		results []int64
+		errs    []error
+		want    BulkResult
+		wantErr bool
+	}{
+		{"insert and update", []int64{2, 4, 1}, nil, BulkResult{Batches: 3, Affected: 7, Inserted: 3, Updated: 2}, false},
+		{"failed batch", []int64{2, 0}, []error{nil, errors.New("deadlock")}, BulkResult{Batches: 2, Affected: 2, Inserted: 2}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			executor := &bulkTestExecutor{results: tt.results, errs: tt.errs}
+			//模拟WithTx重试：同一个闭包执行两次，第二次的结果不能累加第一次的统计
+			for attempt := 0; attempt < 2; attempt++ {
+				executor.calls = 0
+				result, err := _bulkExec(executor, meta, list, opts, updateColumns, true)
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("attempt %d: error = %v, wantErr %v", attempt, err, tt.wantErr)
+				}
+				got := *result
+				got.Errors = nil
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("attempt %d: result = %+v, want %+v", attempt, got, tt.want)
+				}
+				if tt.wantErr && len(result.Errors) != 1 {
+					t.Errorf("attempt %d: errors = %v, want 1 batch error", attempt, result.Errors)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+/**
+ * Created by goland.
+ * User: adam_wang
+ * Date: 2023-08-29 21:02:33
+ */
+
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/beego/beego/v2/client/orm"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+// mysqlLock 基于MySQL GET_LOCK的锁
+type mysqlLock struct {
+	alias string
+	name  string
+}
+
+// MySQLLock 创建基于MySQL GET_LOCK的迁移锁
+// @param alias string
+// @param name string
+// @return MigrationLocker
+func MySQLLock(alias, name string) MigrationLocker {
+	return &mysqlLock{alias: alias, name: name}
+}
+
+// Lock 获取锁，GET_LOCK与连接绑定，因此持有锁期间独占一个连接
+// @receiver l *mysqlLock
+// @param ctx context.Context
+// @return func() error
+// @return error
+func (l *mysqlLock) Lock(ctx context.Context) (func() error, error) {
+	db, err := orm.GetDB(l.alias)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := 0
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = int(time.Until(deadline).Seconds())
+	}
+	var result *int
+	if err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", l.name, timeout).Scan(&result); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	if result == nil || *result != 1 {
+		_ = conn.Close()
+		return nil, fmt.Errorf("lock %q is held by another session", l.name)
+	}
+
+	return func() error {
+		defer conn.Close()
+		_, err := conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", l.name)
+		return err
+	}, nil
+}
+
+// redisLock 基于redis SET NX的锁
+type redisLock struct {
+	key string
+	ttl time.Duration
+}
+
+// redisUnlockScript 仅当锁仍由自己持有时才删除
+var redisUnlockScript = redis.NewScript(`if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) end return 0`)
+
+// RedisLock 创建基于redis的迁移锁，ttl为锁的最长持有时间
+// @param key string
+// @param ttl time.Duration
+// @return MigrationLocker
+func RedisLock(key string, ttl time.Duration) MigrationLocker {
+	return &redisLock{key: key, ttl: ttl}
+}
+
+// Lock 获取锁，在ctx超时前每隔200毫秒重试一次
+// @receiver l *redisLock
+// @param ctx context.Context
+// @return func() error
+// @return error
+func (l *redisLock) Lock(ctx context.Context) (func() error, error) {
+	if rdb == nil {
+		return nil, errors.New("redis is not initialized")
+	}
+	key := l.key
+	if redisKey != "" {
+		key = redisKey + ":" + key
+	}
+	tokenBytes := make([]byte, 16)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return nil, err
+	}
+	token := hex.EncodeToString(tokenBytes)
+
+	for {
+		ok, err := rdb.SetNX(ctx, key, token, l.ttl).Result()
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("lock %q is held by another instance: %w", l.key, ctx.Err())
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+
+	return func() error {
+		return redisUnlockScript.Run(context.Background(), rdb, []string{key}, token).Err()
+	}, nil
+}
@@ -0,0 +1,581 @@
+/**
+ * Created by goland.
+ * User: adam_wang
+ * Date: 2023-08-29 20:17:45
+ */
+
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/beego/beego/v2/client/orm"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Migration 数据库迁移
+// 可以使用SQL（UpSQL/DownSQL）或Go函数（Up/Down）定义，同时定义时优先使用Go函数；
+// 迁移在事务中执行，但MySQL的DDL（CREATE、ALTER、DROP等）会隐式提交，包含DDL的迁移失败时，
+// 失败语句之前已执行的DDL不会回滚且不会写入迁移记录，需要手动处理后重新执行，建议每个迁移只包含一条DDL或使用幂等的语句；
+// SQL迁移按分号拆分语句（见SplitSQLStatements），不支持DELIMITER及包含BEGIN...END的存储过程、函数、触发器、事件，
+// 这类定义请使用Go函数迁移整体执行；
+// Go函数迁移未设置Source时校验和只包含版本号和名称，修改函数体不会被Verify检测到
+type Migration struct {
+	Version int64
+	Name    string
+	UpSQL   string
+	DownSQL string
+	Up      func(tx orm.TxOrmer) error
+	Down    func(tx orm.TxOrmer) error
+	Source  string // Go函数迁移的内容（如通过go:embed嵌入迁移函数所在的源文件），参与校验和计算，使修改函数体时能被检测到
+}
+
+// Checksum 迁移内容校验和，SQL迁移按SQL内容计算，Go函数迁移按版本号、名称及Source计算（未设置Source时只按版本号和名称计算）
+// @receiver m Migration
+// @return string
+func (m Migration) Checksum() string {
+	var content string
+	if m.Up != nil || m.Down != nil {
+		content = fmt.Sprintf("go:%d:%s", m.Version, m.Name)
+		if m.Source != "" {
+			content += "\n" + m.Source
+		}
+	} else {
+		content = m.UpSQL + "\n-- down --\n" + m.DownSQL
+	}
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// MigrationStatus 迁移状态
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+	Checksum  string
+	Modified  bool // 已执行的迁移内容与当前定义不一致
+}
+
+// MigrationLocker 迁移锁，保证同一时间只有一个实例执行迁移
+type MigrationLocker interface {
+	Lock(ctx context.Context) (unlock func() error, err error)
+}
+
+// migrationStep 待执行的迁移及方向
+type migrationStep struct {
+	Migration
+	up bool
+}
+
+// Migrator 数据库迁移执行器
+type Migrator struct {
+	Alias       string          // 数据库别名，默认为default
+	Table       string          // 迁移记录表，默认为schema_migrations
+	Migrations  []Migration     // 迁移列表
+	Locker      MigrationLocker // 迁移锁，默认为MySQL GET_LOCK
+	LockTimeout time.Duration   // 获取锁的超时时间，默认为30秒
+	DryRun      bool            // 只输出将要执行的迁移，不实际执行（不会创建迁移记录表）
+	Out         io.Writer       // 执行过程输出，默认为os.Stdout
+}
+
+var (
+	migrationFileRegexp = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+	delimiterRegexp     = regexp.MustCompile(`(?im)^\s*DELIMITER\s`)
+	compoundRegexp      = regexp.MustCompile(`(?is)^CREATE\s+(OR\s+REPLACE\s+)?(DEFINER\s*=\s*\S+\s+)?(PROCEDURE|FUNCTION|TRIGGER|EVENT)\b.*\bBEGIN\b`)
+)
+
+// NewMigrator 创建迁移执行器
+// @param alias string
+// @param migrations ...Migration
+// @return *Migrator
+func NewMigrator(alias string, migrations ...Migration) *Migrator {
+	return &Migrator{Alias: alias, Migrations: migrations}
+}
+
+// LoadSQLMigrations 从目录（可以是embed.FS）中加载SQL迁移文件
+// 文件名格式为：版本号_名称.up.sql、版本号_名称.down.sql，如0001_create_user.up.sql
+// @param fsys fs.FS
+// @param dir string
+// @return []Migration
+// @return error
+func LoadSQLMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		match := migrationFileRegexp.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		version, _ := strconv.ParseInt(match[1], 10, 64)
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := migrations[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			migrations[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migrate: version %d has different names %q and %q", version, m.Name, match[2])
+		}
+		if _, err := _splitMigrationSQL(string(content)); err != nil {
+			return nil, fmt.Errorf("%w (%s)", err, entry.Name())
+		}
+		if match[3] == "up" {
+			m.UpSQL = string(content)
+		} else {
+			m.DownSQL = string(content)
+		}
+	}
+
+	list := make([]Migration, 0, len(migrations))
+	for _, m := range migrations {
+		list = append(list, *m)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+// Up 执行所有未执行的迁移
+// @receiver m *Migrator
+// @return error
+func (m *Migrator) Up() error {
+	return m.To(-1)
+}
+
+// Down 回滚最近执行的count个迁移
+// @receiver m *Migrator
+// @param count int
+// @return error
+func (m *Migrator) Down(count int) error {
+	return m.run(func(applied map[int64]MigrationStatus) []migrationStep {
+		list := m.sorted()
+		var steps []migrationStep
+		for i := len(list) - 1; i >= 0 && len(steps) < count; i-- {
+			if _, ok := applied[list[i].Version]; ok {
+				steps = append(steps, migrationStep{Migration: list[i]})
+			}
+		}
+		return steps
+	})
+}
+
+// To 迁移到指定版本：执行小于等于该版本的未执行迁移，回滚大于该版本的已执行迁移；version为-1时执行全部
+// @receiver m *Migrator
+// @param version int64
+// @return error
+func (m *Migrator) To(version int64) error {
+	return m.run(func(applied map[int64]MigrationStatus) []migrationStep {
+		list := m.sorted()
+		var steps []migrationStep
+		for i := len(list) - 1; i >= 0; i-- {
+			if _, ok := applied[list[i].Version]; ok && version >= 0 && list[i].Version > version {
+				steps = append(steps, migrationStep{Migration: list[i]})
+			}
+		}
+		for _, migration := range list {
+			if _, ok := applied[migration.Version]; !ok && (version < 0 || migration.Version <= version) {
+				steps = append(steps, migrationStep{Migration: migration, up: true})
+			}
+		}
+		return steps
+	})
+}
+
+// Status 获取所有迁移的执行状态
+// @receiver m *Migrator
+// @return []MigrationStatus
+// @return error
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]MigrationStatus, 0, len(m.Migrations))
+	for _, migration := range m.sorted() {
+		status := MigrationStatus{Version: migration.Version, Name: migration.Name, Checksum: migration.Checksum()}
+		if record, ok := applied[migration.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = record.AppliedAt
+			status.Modified = record.Checksum != status.Checksum
+		}
+		list = append(list, status)
+	}
+	return list, nil
+}
+
+// Verify 校验已执行迁移的校验和与当前定义是否一致
+// @receiver m *Migrator
+// @return error
+func (m *Migrator) Verify() error {
+	list, err := m.Status()
+	if err != nil {
+		return err
+	}
+	for _, status := range list {
+		if status.Modified {
+			return fmt.Errorf("migrate: checksum mismatch for applied migration %d_%s", status.Version, status.Name)
+		}
+	}
+	return nil
+}
+
+// Run 按命令执行迁移，支持：up、down [steps]、to <version>、status，可附加--dry-run
+// @receiver m *Migrator
+// @param args ...string
+// @return error
+func (m *Migrator) Run(args ...string) error {
+	var params []string
+	dryRun := m.DryRun
+	for _, arg := range args {
+		if arg == "--dry-run" {
+			dryRun = true
+			continue
+		}
+		params = append(params, arg)
+	}
+	if len(params) == 0 {
+		return errors.New("migrate: missing command, expected up, down, to or status")
+	}
+	if dryRun != m.DryRun {
+		migrator := *m
+		migrator.DryRun = dryRun
+		m = &migrator
+	}
+
+	switch params[0] {
+	case "up":
+		return m.Up()
+	case "down":
+		steps := 1
+		if len(params) > 1 {
+			n, err := strconv.Atoi(params[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("migrate: invalid steps %q", params[1])
+			}
+			steps = n
+		}
+		return m.Down(steps)
+	case "to":
+		if len(params) < 2 {
+			return errors.New("migrate: missing target version")
+		}
+		version, err := strconv.ParseInt(params[1], 10, 64)
+		if err != nil || version < 0 {
+			return fmt.Errorf("migrate: invalid version %q", params[1])
+		}
+		return m.To(version)
+	case "status":
+		list, err := m.Status()
+		if err != nil {
+			return err
+		}
+		for _, status := range list {
+			state := "pending"
+			if status.Applied {
+				state = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			if status.Modified {
+				state += " (modified)"
+			}
+			_, _ = fmt.Fprintf(m.out(), "%d_%s\t%s\n", status.Version, status.Name, state)
+		}
+		return nil
+	default:
+		return fmt.Errorf("migrate: unknown command %q", params[0])
+	}
+}
+
+// run 加锁后校验并执行选出的迁移
+// @receiver m *Migrator
+// @param plan func(applied map[int64]MigrationStatus) []migrationStep
+// @return error
+func (m *Migrator) run(plan func(applied map[int64]MigrationStatus) []migrationStep) error {
+	if !m.DryRun {
+		if err := m.ensureTable(); err != nil {
+			return err
+		}
+		lockTimeout := m.LockTimeout
+		if lockTimeout <= 0 {
+			lockTimeout = 30 * time.Second
+		}
+		lockCtx, cancel := context.WithTimeout(context.Background(), lockTimeout)
+		defer cancel()
+		unlock, err := m.locker().Lock(lockCtx)
+		if err != nil {
+			return fmt.Errorf("migrate: acquire lock: %w", err)
+		}
+		defer func() {
+			_ = unlock()
+		}()
+	}
+
+	if err := m.Verify(); err != nil {
+		return err
+	}
+	applied, err := m.applied()
+	if err != nil {
+		return err
+	}
+	for _, step := range plan(applied) {
+		if err := m.apply(step.Migration, step.up); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// apply 在事务中执行单个迁移并更新迁移记录
+// @receiver m *Migrator
+// @param migration Migration
+// @param up bool
+// @return error
+func (m *Migrator) apply(migration Migration, up bool) error {
+	direction, fn, sql := "down", migration.Down, migration.DownSQL
+	if up {
+		direction, fn, sql = "up", migration.Up, migration.UpSQL
+	}
+	if fn == nil && strings.TrimSpace(sql) == "" {
+		return fmt.Errorf("migrate: migration %d_%s has no %s definition", migration.Version, migration.Name, direction)
+	}
+	var statements []string
+	if fn == nil {
+		var err error
+		if statements, err = _splitMigrationSQL(sql); err != nil {
+			return fmt.Errorf("%w (%d_%s %s)", err, migration.Version, migration.Name, direction)
+		}
+	}
+
+	out := m.out()
+	_, _ = fmt.Fprintf(out, "-- migrate %s: %d_%s\n", direction, migration.Version, migration.Name)
+	if m.DryRun {
+		if fn != nil {
+			_, _ = fmt.Fprintf(out, "-- go func\n")
+		} else {
+			for _, statement := range statements {
+				_, _ = fmt.Fprintf(out, "%s;\n", statement)
+			}
+		}
+		return nil
+	}
+
+	tx, err := orm.NewOrmUsingDB(m.alias()).Begin()
+	if err != nil {
+		return err
+	}
+	err = func() error {
+		if fn != nil {
+			if err := fn(tx); err != nil {
+				return err
+			}
+		} else {
+			for i, statement := range statements {
+				if _, err := tx.Raw(statement).Exec(); err != nil {
+					if i > 0 {
+						return fmt.Errorf("statement %d of %d: %w (DDL in the previous statements was committed implicitly and is not rolled back)\n%s",
+							i+1, len(statements), err, statement)
+					}
+					return fmt.Errorf("%w\n%s", err, statement)
+				}
+			}
+		}
+		if up {
+			_, err := tx.Raw("INSERT INTO `"+m.table()+"` (version, name, checksum, applied_at) VALUES (?, ?, ?, ?)",
+				migration.Version, migration.Name, migration.Checksum(), time.Now()).Exec()
+			return err
+		}
+		_, err := tx.Raw("DELETE FROM `"+m.table()+"` WHERE version = ?", migration.Version).Exec()
+		return err
+	}()
+	if err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("migrate %s %d_%s: %w", direction, migration.Version, migration.Name, err)
+	}
+	return tx.Commit()
+}
+
+// ensureTable 创建迁移记录表
+// @receiver m *Migrator
+// @return error
+func (m *Migrator) ensureTable() error {
+	_, err := orm.NewOrmUsingDB(m.alias()).Raw("CREATE TABLE IF NOT EXISTS `" + m.table() + "` (" +
+		"`version` BIGINT NOT NULL PRIMARY KEY," +
+		"`name` VARCHAR(255) NOT NULL," +
+		"`checksum` CHAR(64) NOT NULL," +
+		"`applied_at` DATETIME NOT NULL" +
+		") ENGINE=InnoDB").Exec()
+	return err
+}
+
+// applied 获取已执行的迁移，迁移记录表不存在时返回空
+// @receiver m *Migrator
+// @return map[int64]MigrationStatus
+// @return error
+func (m *Migrator) applied() (map[int64]MigrationStatus, error) {
+	o := orm.NewOrmUsingDB(m.alias())
+	var exists int
+	err := o.Raw("SELECT COUNT(*) FROM information_schema.TABLES WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?", m.table()).QueryRow(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if exists == 0 {
+		return map[int64]MigrationStatus{}, nil
+	}
+
+	var rows []orm.Params
+	if _, err = o.Raw("SELECT version, name, checksum, applied_at FROM `" + m.table() + "`").Values(&rows); err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int64]MigrationStatus, len(rows))
+	for _, row := range rows {
+		version, _ := strconv.ParseInt(fmt.Sprint(row["version"]), 10, 64)
+		appliedAt, ok := row["applied_at"].(time.Time)
+		if !ok {
+			appliedAt, _ = time.ParseInLocation("2006-01-02 15:04:05", fmt.Sprint(row["applied_at"]), time.Local)
+		}
+		applied[version] = MigrationStatus{
+			Version:   version,
+			Name:      fmt.Sprint(row["name"]),
+			Applied:   true,
+			AppliedAt: appliedAt,
+			Checksum:  fmt.Sprint(row["checksum"]),
+		}
+	}
+	return applied, nil
+}
+
+func (m *Migrator) sorted() []Migration {
+	list := append([]Migration{}, m.Migrations...)
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list
+}
+
+func (m *Migrator) alias() string {
+	if m.Alias == "" {
+		return "default"
+	}
+	return m.Alias
+}
+
+func (m *Migrator) table() string {
+	if m.Table == "" {
+		return "schema_migrations"
+	}
+	return m.Table
+}
+
+func (m *Migrator) out() io.Writer {
+	if m.Out == nil {
+		return os.Stdout
+	}
+	return m.Out
+}
+
+func (m *Migrator) locker() MigrationLocker {
+	if m.Locker == nil {
+		return MySQLLock(m.alias(), m.table()+"_lock")
+	}
+	return m.Locker
+}
+
+// _splitMigrationSQL 拆分迁移SQL，包含DELIMITER或BEGIN...END复合语句（会被分号错误拆分）时返回错误
+// @param sql string
+// @return []string
+// @return error
+func _splitMigrationSQL(sql string) ([]string, error) {
+	statements := SplitSQLStatements(sql)
+	for _, statement := range statements {
+		if delimiterRegexp.MatchString(statement) {
+			return nil, errors.New("migrate: DELIMITER is not supported in sql migrations, use a go func migration instead")
+		}
+		if compoundRegexp.MatchString(statement) {
+			return nil, errors.New("migrate: stored programs with BEGIN...END are not supported in sql migrations, use a go func migration instead")
+		}
+	}
+	return statements, nil
+}
+
+// SplitSQLStatements 按分号拆分多条SQL语句（忽略引号及注释中的分号）；
+// 不识别DELIMITER及BEGIN...END复合语句，存储过程等定义会被错误拆分
+// @param sql string
+// @return []string
+func SplitSQLStatements(sql string) []string {
+	var statements []string
+	var current strings.Builder
+	var quote byte
+	lineComment, blockComment := false, false
+
+	flush := func() {
+		if statement := strings.TrimSpace(current.String()); statement != "" {
+			statements = append(statements, statement)
+		}
+		current.Reset()
+	}
+
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		switch {
+		case lineComment:
+			if c == '\n' {
+				lineComment = false
+				current.WriteByte(c)
+			}
+			continue
+		case blockComment:
+			if c == '*' && i+1 < len(sql) && sql[i+1] == '/' {
+				blockComment = false
+				i++
+			}
+			continue
+		case quote != 0:
+			current.WriteByte(c)
+			if c == '\\' && i+1 < len(sql) {
+				i++
+				current.WriteByte(sql[i])
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+			current.WriteByte(c)
+		case c == '#' || (c == '-' && i+1 < len(sql) && sql[i+1] == '-'):
+			lineComment = true
+		case c == '/' && i+1 < len(sql) && sql[i+1] == '*':
+			blockComment = true
+			i++
+		case c == ';':
+			flush()
+		default:
+			current.WriteByte(c)
+		}
+	}
+	flush()
+
+	return statements
+}
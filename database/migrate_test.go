@@ -0,0 +1,88 @@
+/**
+ * Created by goland.
+ * User: adam_wang
+ * Date: 2023-08-29 20:17:45
+ */
+
+package database
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestSplitSQLStatements(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want []string
+	}{
+		{"empty", "  \n ", nil},
+		{"single without semicolon", "SELECT 1", []string{"SELECT 1"}},
+		{"multiple", "CREATE TABLE a (id INT);\nCREATE TABLE b (id INT);\n", []string{"CREATE TABLE a (id INT)", "CREATE TABLE b (id INT)"}},
+		{"empty statements", ";;SELECT 1;;", []string{"SELECT 1"}},
+		{"single quote", "INSERT INTO a VALUES ('x;y');SELECT 1", []string{"INSERT INTO a VALUES ('x;y')", "SELECT 1"}},
+		{"double quote", `INSERT INTO a VALUES ("x;y")`, []string{`INSERT INTO a VALUES ("x;y")`}},
+		{"backtick", "SELECT `a;b` FROM t", []string{"SELECT `a;b` FROM t"}},
+		{"escaped quote", `INSERT INTO a VALUES ('it\'s;ok');SELECT 1`, []string{`INSERT INTO a VALUES ('it\'s;ok')`, "SELECT 1"}},
+		{"line comment", "-- drop; table\nSELECT 1; # trailing; comment\nSELECT 2", []string{"SELECT 1", "SELECT 2"}},
+		{"block comment", "SELECT /* a; b */ 1;SELECT 2", []string{"SELECT  1", "SELECT 2"}},
+		{"comment only", "-- nothing here;\n/* nor; here */", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SplitSQLStatements(tt.sql); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SplitSQLStatements(%q) = %q, want %q", tt.sql, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitMigrationSQLRejectsStoredPrograms(t *testing.T) {
+	tests := []struct {
+		name    string
+		sql     string
+		wantErr bool
+	}{
+		{"plain ddl", "CREATE TABLE a (id INT);ALTER TABLE a ADD name VARCHAR(32)", false},
+		{"single statement procedure", "CREATE PROCEDURE p() SELECT 1", false},
+		{"delimiter", "DELIMITER $$\nCREATE PROCEDURE p() BEGIN SELECT 1; END$$\nDELIMITER ;", true},
+		{"procedure", "CREATE PROCEDURE p() BEGIN SELECT 1; SELECT 2; END", true},
+		{"definer function", "CREATE DEFINER=`root`@`%` FUNCTION f() RETURNS INT BEGIN RETURN 1; END", true},
+		{"trigger", "CREATE TRIGGER t BEFORE INSERT ON a FOR EACH ROW BEGIN SET NEW.id = 1; END", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := _splitMigrationSQL(tt.sql)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("_splitMigrationSQL(%q) error = %v, wantErr %v", tt.sql, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadSQLMigrationsRejectsDelimiter(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/0001_create_user.up.sql":   {Data: []byte("CREATE TABLE user (id INT)")},
+		"migrations/0002_add_proc.up.sql":      {Data: []byte("DELIMITER $$\nCREATE PROCEDURE p() BEGIN SELECT 1; END$$")},
+		"migrations/0001_create_user.down.sql": {Data: []byte("DROP TABLE user")},
+	}
+	_, err := LoadSQLMigrations(fsys, "migrations")
+	if err == nil || !strings.Contains(err.Error(), "0002_add_proc.up.sql") {
+		t.Fatalf("LoadSQLMigrations error = %v, want DELIMITER error for 0002_add_proc.up.sql", err)
+	}
+}
+
+func TestRunDryRunDoesNotChangeMigrator(t *testing.T) {
+	var out bytes.Buffer
+	m := &Migrator{Out: &out}
+	if err := m.Run("unknown", "--dry-run"); err == nil {
+		t.Fatal("Run with unknown command should fail")
+	}
+	if m.DryRun {
+		t.Error("Run --dry-run changed Migrator.DryRun")
+	}
+}
@@ -0,0 +1,397 @@
+/**
+ * Created by goland.
+ * User: adam_wang
+ * Date: 2023-09-15 22:03:26
+ */
+
+package database
+
+import (
+	"context"
+	"errors"
+	"github.com/beego/beego/v2/client/orm"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// TimestampModel 创建时间及更新时间，嵌入到ORM模型中使用
+type TimestampModel struct {
+	CreatedAt time.Time `orm:"auto_now_add;type(datetime)" json:"created_at"`
+	UpdatedAt time.Time `orm:"auto_now;type(datetime)" json:"updated_at"`
+}
+
+// SoftDeleteModel 软删除时间，嵌入到ORM模型中使用，DeletedAt为NULL表示未删除
+type SoftDeleteModel struct {
+	DeletedAt *time.Time `orm:"null;type(datetime)" json:"deleted_at"`
+}
+
+// AuditModel 创建人及更新人，嵌入到ORM模型中使用
+type AuditModel struct {
+	CreatedBy int64 `orm:"default(0)" json:"created_by"`
+	UpdatedBy int64 `orm:"default(0)" json:"updated_by"`
+}
+
+// BaseModel 包含时间戳、软删除及操作人的基础模型
+type BaseModel struct {
+	TimestampModel
+	SoftDeleteModel
+	AuditModel
+}
+
+// BeforeInsertHook 插入前钩子
+type BeforeInsertHook interface {
+	BeforeInsert(ctx context.Context) error
+}
+
+// AfterInsertHook 插入后钩子
+type AfterInsertHook interface {
+	AfterInsert(ctx context.Context)
+}
+
+// BeforeUpdateHook 更新前钩子
+type BeforeUpdateHook interface {
+	BeforeUpdate(ctx context.Context) error
+}
+
+// AfterUpdateHook 更新后钩子
+type AfterUpdateHook interface {
+	AfterUpdate(ctx context.Context)
+}
+
+// BeforeDeleteHook 删除（含软删除）前钩子
+type BeforeDeleteHook interface {
+	BeforeDelete(ctx context.Context) error
+}
+
+// AfterDeleteHook 删除（含软删除）后钩子
+type AfterDeleteHook interface {
+	AfterDelete(ctx context.Context)
+}
+
+// actorContextKey 上下文中保存操作人的key
+type actorContextKey struct{}
+
+// queryOptions 查询选项
+type queryOptions struct {
+	withTrashed bool
+	onlyTrashed bool
+}
+
+// QueryOption 查询选项
+type QueryOption func(opts *queryOptions)
+
+// WithActor 获取携带操作人ID的上下文，InsertModel/UpdateModel/DeleteModel时自动填充CreatedBy/UpdatedBy
+// @param ctx context.Context
+// @param actorID int64
+// @return context.Context
+func WithActor(ctx context.Context, actorID int64) context.Context {
+	return context.WithValue(ctx, actorContextKey{}, actorID)
+}
+
+// ActorFromContext 获取上下文中的操作人ID
+// @param ctx context.Context
+// @return int64
+// @return bool
+func ActorFromContext(ctx context.Context) (int64, bool) {
+	if ctx == nil {
+		return 0, false
+	}
+	actorID, ok := ctx.Value(actorContextKey{}).(int64)
+	return actorID, ok
+}
+
+// WithTrashed 查询时包含已软删除的数据
+// @return QueryOption
+func WithTrashed() QueryOption {
+	return func(opts *queryOptions) {
+		opts.withTrashed = true
+	}
+}
+
+// OnlyTrashed 查询时只查已软删除的数据
+// @return QueryOption
+func OnlyTrashed() QueryOption {
+	return func(opts *queryOptions) {
+		opts.onlyTrashed = true
+	}
+}
+
+// QueryTable 获取模型的QuerySeter，默认过滤已软删除的数据
+// @param o orm.QueryExecutor
+// @param md interface{}
+// @param options ...QueryOption
+// @return orm.QuerySeter
+func QueryTable(o orm.QueryExecutor, md interface{}, options ...QueryOption) orm.QuerySeter {
+	opts := &queryOptions{}
+	for _, option := range options {
+		option(opts)
+	}
+
+	qs := o.QueryTable(md)
+	meta, err := _getModelMeta(md)
+	if err != nil || meta.Column("DeletedAt") == nil {
+		return qs
+	}
+	switch {
+	case opts.onlyTrashed:
+		return qs.Filter("DeletedAt__isnull", false)
+	case opts.withTrashed:
+		return qs
+	default:
+		return qs.Filter("DeletedAt__isnull", true)
+	}
+}
+
+// ReadModel 按主键（或指定字段）读取数据，已软删除的数据返回orm.ErrNoRows
+// @param ctx context.Context
+// @param o orm.QueryExecutor
+// @param md interface{}
+// @param cols ...string
+// @return error
+func ReadModel(ctx context.Context, o orm.QueryExecutor, md interface{}, cols ...string) error {
+	if err := o.ReadWithCtx(ctx, md, cols...); err != nil {
+		return err
+	}
+	if _isTrashed(md) {
+		return orm.ErrNoRows
+	}
+	return nil
+}
+
+// InsertModel 插入数据，自动填充CreatedAt/UpdatedAt及CreatedBy/UpdatedBy
+// @param ctx context.Context
+// @param o orm.QueryExecutor
+// @param md interface{}
+// @return int64
+// @return error
+func InsertModel(ctx context.Context, o orm.QueryExecutor, md interface{}) (int64, error) {
+	if hook, ok := md.(BeforeInsertHook); ok {
+		if err := hook.BeforeInsert(ctx); err != nil {
+			return 0, err
+		}
+	}
+
+	now := time.Now()
+	_setModelField(md, "CreatedAt", now)
+	_setModelField(md, "UpdatedAt", now)
+	if actorID, ok := ActorFromContext(ctx); ok {
+		_setModelField(md, "CreatedBy", actorID)
+		_setModelField(md, "UpdatedBy", actorID)
+	}
+
+	id, err := o.InsertWithCtx(ctx, md)
+	if err != nil {
+		return id, err
+	}
+	_auditRecord(ctx, o, AuditActionInsert, md, nil, nil, false)
+	if hook, ok := md.(AfterInsertHook); ok {
+		hook.AfterInsert(ctx)
+	}
+	return id, nil
+}
+
+// UpdateModel 更新数据，自动填充UpdatedAt及UpdatedBy（指定cols时会自动追加这两个字段）
+// @param ctx context.Context
+// @param o orm.QueryExecutor
+// @param md interface{}
+// @param cols ...string
+// @return int64
+// @return error
+func UpdateModel(ctx context.Context, o orm.QueryExecutor, md interface{}, cols ...string) (int64, error) {
+	if hook, ok := md.(BeforeUpdateHook); ok {
+		if err := hook.BeforeUpdate(ctx); err != nil {
+			return 0, err
+		}
+	}
+
+	if _setModelField(md, "UpdatedAt", time.Now()) && len(cols) > 0 {
+		cols = append(cols, "UpdatedAt")
+	}
+	if actorID, ok := ActorFromContext(ctx); ok {
+		if _setModelField(md, "UpdatedBy", actorID) && len(cols) > 0 {
+			cols = append(cols, "UpdatedBy")
+		}
+	}
+
+	before := _auditBefore(ctx, o, md)
+	num, err := o.UpdateWithCtx(ctx, md, cols...)
+	if err != nil {
+		return num, err
+	}
+	_auditRecord(ctx, o, AuditActionUpdate, md, before, cols, false)
+	if hook, ok := md.(AfterUpdateHook); ok {
+		hook.AfterUpdate(ctx)
+	}
+	return num, nil
+}
+
+// DeleteModel 删除数据，模型包含DeletedAt字段时为软删除，否则为物理删除
+// @param ctx context.Context
+// @param o orm.QueryExecutor
+// @param md interface{}
+// @return int64
+// @return error
+func DeleteModel(ctx context.Context, o orm.QueryExecutor, md interface{}) (int64, error) {
+	if hook, ok := md.(BeforeDeleteHook); ok {
+		if err := hook.BeforeDelete(ctx); err != nil {
+			return 0, err
+		}
+	}
+
+	var num int64
+	var err error
+	before := _auditBefore(ctx, o, md)
+	now := time.Now()
+	if _setModelField(md, "DeletedAt", &now) {
+		cols := []string{"DeletedAt"}
+		if actorID, ok := ActorFromContext(ctx); ok && _setModelField(md, "UpdatedBy", actorID) {
+			cols = append(cols, "UpdatedBy")
+		}
+		if num, err = o.UpdateWithCtx(ctx, md, cols...); err == nil {
+			_auditRecord(ctx, o, AuditActionDelete, md, before, cols, false)
+		}
+	} else if num, err = o.DeleteWithCtx(ctx, md); err == nil {
+		_auditRecord(ctx, o, AuditActionDelete, md, before, nil, true)
+	}
+	if err != nil {
+		return num, err
+	}
+	if hook, ok := md.(AfterDeleteHook); ok {
+		hook.AfterDelete(ctx)
+	}
+	return num, nil
+}
+
+// ForceDeleteModel 物理删除数据（忽略软删除）
+// @param ctx context.Context
+// @param o orm.QueryExecutor
+// @param md interface{}
+// @return int64
+// @return error
+func ForceDeleteModel(ctx context.Context, o orm.QueryExecutor, md interface{}) (int64, error) {
+	if hook, ok := md.(BeforeDeleteHook); ok {
+		if err := hook.BeforeDelete(ctx); err != nil {
+			return 0, err
+		}
+	}
+
+	before := _auditBefore(ctx, o, md)
+	num, err := o.DeleteWithCtx(ctx, md)
+	if err != nil {
+		return num, err
+	}
+	_auditRecord(ctx, o, AuditActionForceDelete, md, before, nil, true)
+	if hook, ok := md.(AfterDeleteHook); ok {
+		hook.AfterDelete(ctx)
+	}
+	return num, nil
+}
+
+// RestoreModel 恢复已软删除的数据
+// @param ctx context.Context
+// @param o orm.QueryExecutor
+// @param md interface{}
+// @return int64
+// @return error
+func RestoreModel(ctx context.Context, o orm.QueryExecutor, md interface{}) (int64, error) {
+	if !_setModelField(md, "DeletedAt", (*time.Time)(nil)) {
+		return 0, errors.New("model does not support soft delete")
+	}
+
+	cols := []string{"DeletedAt"}
+	if actorID, ok := ActorFromContext(ctx); ok && _setModelField(md, "UpdatedBy", actorID) {
+		cols = append(cols, "UpdatedBy")
+	}
+	before := _auditBefore(ctx, o, md)
+	num, err := o.UpdateWithCtx(ctx, md, cols...)
+	if err != nil {
+		return num, err
+	}
+	_auditRecord(ctx, o, AuditActionRestore, md, before, cols, false)
+	return num, nil
+}
+
+// _isTrashed 模型数据是否已软删除
+// @param md interface{}
+// @return bool
+func _isTrashed(md interface{}) bool {
+	field, ok := _modelFieldValue(md, "DeletedAt")
+	if !ok {
+		return false
+	}
+	if field.Kind() == reflect.Ptr {
+		return !field.IsNil()
+	}
+	if t, ok := field.Interface().(time.Time); ok {
+		return !t.IsZero()
+	}
+	return false
+}
+
+// _modelFieldValue 获取模型字段（含匿名嵌入结构体中的字段）
+// @param md interface{}
+// @param name string
+// @return reflect.Value
+// @return bool
+func _modelFieldValue(md interface{}, name string) (reflect.Value, bool) {
+	meta, err := _getModelMeta(md)
+	if err != nil {
+		return reflect.Value{}, false
+	}
+	field := meta.Column(name)
+	if field == nil {
+		return reflect.Value{}, false
+	}
+	val := reflect.ValueOf(md)
+	if val.Kind() != reflect.Ptr || val.IsNil() {
+		return reflect.Value{}, false
+	}
+	return val.Elem().FieldByIndex(field.Index), true
+}
+
+// _setModelField 设置模型字段值，按字段类型转换时间及操作人ID，字段不存在或类型不支持时返回false
+// @param md interface{}
+// @param name string
+// @param value interface{}
+// @return bool
+func _setModelField(md interface{}, name string, value interface{}) bool {
+	field, ok := _modelFieldValue(md, name)
+	if !ok || !field.CanSet() {
+		return false
+	}
+
+	switch v := value.(type) {
+	case time.Time:
+		if field.Type() == reflect.TypeOf(time.Time{}) {
+			field.Set(reflect.ValueOf(v))
+			return true
+		}
+	case *time.Time:
+		if field.Type() == reflect.TypeOf(v) {
+			field.Set(reflect.ValueOf(v))
+			return true
+		}
+		if field.Type() == reflect.TypeOf(time.Time{}) {
+			if v == nil {
+				field.Set(reflect.ValueOf(time.Time{}))
+			} else {
+				field.Set(reflect.ValueOf(*v))
+			}
+			return true
+		}
+	case int64:
+		switch field.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			field.SetInt(v)
+			return true
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			field.SetUint(uint64(v))
+			return true
+		case reflect.String:
+			field.SetString(strconv.FormatInt(v, 10))
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,284 @@
+/**
+ * Created by goland.
+ * User: adam_wang
+ * Date: 2023-09-02 16:25:18
+ */
+
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/adam-qiang/beego-tool/internal/naming"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// modelField ORM模型字段信息
+type modelField struct {
+	Name       string
+	Column     string
+	Index      []int
+	Pk         bool
+	Auto       bool
+	Null       bool
+	AutoNow    bool
+	AutoNowAdd bool
+	Rel        bool
+	RelType    reflect.Type // 关联模型类型
+	ColumnType string       // 按beego ORM建表规则生成的MySQL字段类型，如varchar(255)、int unsigned，关联字段及不支持的类型为空
+	Default    *string      // 按beego ORM建表规则生成的默认值，无默认值时为nil
+}
+
+// modelMeta ORM模型信息（与beego ORM的表名、字段名规则保持一致）
+type modelMeta struct {
+	Type   reflect.Type
+	Table  string
+	Fields []modelField
+	Pk     *modelField
+}
+
+// intColumnTypes 整数类型对应的MySQL字段类型（与beego ORM建表规则一致）
+var intColumnTypes = map[reflect.Kind]string{
+	reflect.Int8:   "tinyint",
+	reflect.Int16:  "smallint",
+	reflect.Int32:  "int",
+	reflect.Int:    "int",
+	reflect.Int64:  "bigint",
+	reflect.Uint8:  "tinyint unsigned",
+	reflect.Uint16: "smallint unsigned",
+	reflect.Uint32: "int unsigned",
+	reflect.Uint:   "int unsigned",
+	reflect.Uint64: "bigint unsigned",
+}
+
+var (
+	modelPrefixes   = make(map[reflect.Type]string)
+	modelPrefixesMu sync.RWMutex
+	modelMetas      sync.Map
+)
+
+// Column 按字段名或列名查找字段
+// @receiver m *modelMeta
+// @param name string
+// @return *modelField
+func (m *modelMeta) Column(name string) *modelField {
+	for i := range m.Fields {
+		if m.Fields[i].Name == name || m.Fields[i].Column == name {
+			return &m.Fields[i]
+		}
+	}
+	return nil
+}
+
+// _modelPrefix 获取模型注册时使用的表名前缀
+// @param model interface{}
+// @return string
+func _modelPrefix(model interface{}) string {
+	typ := reflect.Indirect(reflect.ValueOf(model)).Type()
+	modelPrefixesMu.RLock()
+	defer modelPrefixesMu.RUnlock()
+
+	return modelPrefixes[typ]
+}
+
+// _setModelPrefix 记录模型注册时使用的表名前缀
+// @param model interface{}
+// @param prefix string
+func _setModelPrefix(model interface{}, prefix string) {
+	typ := reflect.Indirect(reflect.ValueOf(model)).Type()
+	modelPrefixesMu.Lock()
+	modelPrefixes[typ] = prefix
+	modelPrefixesMu.Unlock()
+	modelMetas.Delete(typ)
+}
+
+// _getModelMeta 获取模型信息
+// @param model interface{}
+// @return *modelMeta
+// @return error
+func _getModelMeta(model interface{}) (*modelMeta, error) {
+	typ := reflect.TypeOf(model)
+	for typ != nil && (typ.Kind() == reflect.Ptr || typ.Kind() == reflect.Slice) {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return nil, errors.New("model must be a struct or a pointer to struct")
+	}
+	if cached, ok := modelMetas.Load(typ); ok {
+		return cached.(*modelMeta), nil
+	}
+
+	val := reflect.New(typ)
+	table := naming.SnakeString(typ.Name())
+	if fun := val.MethodByName("TableName"); fun.IsValid() {
+		if values := fun.Call(nil); len(values) > 0 && values[0].Kind() == reflect.String {
+			table = values[0].String()
+		}
+	}
+	modelPrefixesMu.RLock()
+	table = modelPrefixes[typ] + table
+	modelPrefixesMu.RUnlock()
+
+	meta := &modelMeta{Type: typ, Table: table}
+	_collectModelFields(meta, typ, nil)
+	for i := range meta.Fields {
+		if meta.Fields[i].Pk {
+			meta.Pk = &meta.Fields[i]
+			break
+		}
+	}
+	if meta.Pk == nil {
+		if field := meta.Column("Id"); field != nil {
+			field.Pk, field.Auto = true, true
+			meta.Pk = field
+		}
+	}
+
+	modelMetas.Store(typ, meta)
+	return meta, nil
+}
+
+// _collectModelFields 收集模型字段，匿名嵌入的结构体字段展开到当前模型
+// @param meta *modelMeta
+// @param typ reflect.Type
+// @param index []int
+func _collectModelFields(meta *modelMeta, typ reflect.Type, index []int) {
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		if sf.PkgPath != "" {
+			continue
+		}
+		fieldIndex := append(append([]int{}, index...), i)
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			_collectModelFields(meta, sf.Type, fieldIndex)
+			continue
+		}
+
+		tags := _parseOrmTag(sf.Tag.Get("orm"))
+		if _, ok := tags["-"]; ok {
+			continue
+		}
+		rel := tags["rel"]
+		if _, ok := tags["reverse"]; ok || rel == "m2m" {
+			continue
+		}
+
+		field := modelField{Name: sf.Name, Index: fieldIndex, Column: tags["column"]}
+		_, field.Pk = tags["pk"]
+		_, field.Auto = tags["auto"]
+		_, field.Null = tags["null"]
+		_, field.AutoNow = tags["auto_now"]
+		_, field.AutoNowAdd = tags["auto_now_add"]
+		field.Rel = rel == "fk" || rel == "one"
+		if field.Rel {
+			field.RelType = sf.Type
+		} else {
+			field.ColumnType, field.Default = _fieldColumnType(sf.Type, tags, field.Null)
+		}
+		if field.Column == "" {
+			field.Column = naming.SnakeString(sf.Name)
+			if field.Rel {
+				field.Column += "_id"
+			}
+		}
+		meta.Fields = append(meta.Fields, field)
+	}
+}
+
+// _parseOrmTag 解析orm标签，如column(name);pk;auto
+// @param tag string
+// @return map[string]string
+func _parseOrmTag(tag string) map[string]string {
+	tags := make(map[string]string)
+	for _, item := range strings.Split(tag, ";") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if i := strings.Index(item, "("); i > 0 && strings.HasSuffix(item, ")") {
+			tags[item[:i]] = item[i+1 : len(item)-1]
+		} else {
+			tags[item] = ""
+		}
+	}
+	return tags
+}
+
+// _fieldColumnType 按beego ORM的MySQL建表规则获取字段类型及默认值
+// @param typ reflect.Type
+// @param tags map[string]string
+// @param null bool
+// @return string
+// @return *string
+func _fieldColumnType(typ reflect.Type, tags map[string]string, null bool) (string, *string) {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	size := tags["size"]
+	if size == "" {
+		size = "255"
+	}
+	var columnType, zero string
+	switch {
+	case typ == reflect.TypeOf(time.Time{}):
+		columnType = "datetime"
+		if tags["type"] == "date" {
+			columnType = "date"
+		} else if precision := tags["precision"]; precision != "" {
+			columnType = "datetime(" + precision + ")"
+		}
+		//时间类型不生成默认值
+		return columnType, nil
+	case typ == reflect.TypeOf(sql.NullInt64{}):
+		columnType, zero = "bigint", "0"
+	case typ == reflect.TypeOf(sql.NullFloat64{}):
+		columnType, zero = "double", "0"
+	case typ == reflect.TypeOf(sql.NullBool{}):
+		columnType, zero = "tinyint", "0"
+	case typ == reflect.TypeOf(sql.NullString{}):
+		columnType = "varchar(" + size + ")"
+	default:
+		switch typ.Kind() {
+		case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int, reflect.Int64,
+			reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint, reflect.Uint64:
+			columnType, zero = intColumnTypes[typ.Kind()], "0"
+		case reflect.Float32, reflect.Float64:
+			columnType, zero = "double", "0"
+			if tags["digits"] != "" || tags["decimals"] != "" {
+				columnType = fmt.Sprintf("decimal(%s,%s)", tags["digits"], tags["decimals"])
+			}
+		case reflect.Bool:
+			columnType, zero = "tinyint", "0"
+		case reflect.String:
+			switch tags["type"] {
+			case "char":
+				columnType = "char(" + size + ")"
+			case "text":
+				//长文本类型不生成默认值
+				return "longtext", nil
+			default:
+				columnType = "varchar(" + size + ")"
+			}
+		default:
+			return "", nil
+		}
+	}
+
+	if value, ok := tags["default"]; ok {
+		if zero == "0" && strings.EqualFold(value, "true") {
+			value = "1"
+		} else if zero == "0" && strings.EqualFold(value, "false") {
+			value = "0"
+		}
+		return columnType, &value
+	}
+	if null {
+		return columnType, nil
+	}
+	return columnType, &zero
+}
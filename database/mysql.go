@@ -7,44 +7,53 @@
 package database
 
 import (
-	"github.com/beego/beego/v2/client/orm"
+	"github.com/beego/beego/v2/core/logs"
 	beego "github.com/beego/beego/v2/server/web"
 	_ "github.com/go-sql-driver/mysql"
+	"strings"
 )
 
-type Mysql struct {
-	Id  int
-	Pre string
-	Ng  string
-	Ser string
-	Th  string `orm:"size(500)"`
-}
-
 var mysqlDb = ""
 
 func init() {
-	//注册驱动,可以不加
-	err := orm.RegisterDriver("mysql", orm.DRMySQL)
+	//配置无法解析时启动失败，避免错误的配置被默认值替换
+	cfg, err := LoadMySQLConfig("mysql")
 	if err != nil {
-		return
+		panic("failed to load mysql config: " + err.Error())
 	}
+	mysqlDb = cfg.Database
 
-	mysqlUser, _ := beego.AppConfig.String("mysql::mysql_user")
-	mysqlPass, _ := beego.AppConfig.String("mysql::mysql_pass")
-	mysqlUrls, _ := beego.AppConfig.String("mysql::mysql_urls")
-	mysqlPort, _ := beego.AppConfig.String("mysql::mysql_port")
-	mysqlDb, _ := beego.AppConfig.String("mysql::mysql_db")
+	//开发模式下按配置自动同步已注册模型的表结构
+	beego.AddAPPStartHook(_autoSyncModels)
 
-	//注册 model
-	orm.RegisterModel(new(Mysql))
-	//注册默认数据库,必须注册一个别名为default的数据库，作为默认使用。("root:password@(127.0.0.1:3306)/databasename?charset=utf8")
-	err = orm.RegisterDataBase("default", "mysql", mysqlUser+":"+mysqlPass+"@("+mysqlUrls+":"+mysqlPort+")/"+mysqlDb+"?charset=utf8", orm.ConnMaxLifetime(600))
-	if err != nil {
+	//未配置MySQL地址时不进行注册
+	if cfg.Host == "" {
 		return
 	}
+
+	//注册默认数据库,必须注册一个别名为default的数据库，作为默认使用
+	if err = InitMySQL(cfg); err != nil {
+		logs.Error("failed to init mysql: %v", err)
+	}
+
+	//注册其他命名数据库，配置段为[mysql.别名]
+	databases, _ := beego.AppConfig.String("mysql::databases")
+	for _, alias := range strings.Split(databases, ",") {
+		if alias = strings.TrimSpace(alias); alias == "" || alias == "default" {
+			continue
+		}
+		aliasCfg, err := LoadMySQLConfig("mysql." + alias)
+		if err != nil {
+			panic("failed to load mysql config: " + err.Error())
+		}
+		aliasCfg.Alias = alias
+		if err = InitMySQL(aliasCfg); err != nil {
+			logs.Error("failed to init mysql `%s`: %v", alias, err)
+		}
+	}
 }
 
-// IsExistTable 判断表是否存在
+// IsExistTable 判断表是否存在（查询出错时返回false，需要错误信息时使用SchemaInspector.TableExists）
 // @param tableName string
 // @param databaseName string
 // @return bool
@@ -53,11 +62,6 @@ func IsExistTable(tableName string, databaseName string) bool {
 		databaseName = mysqlDb
 	}
 
-	o := orm.NewOrm()
-	var count int64
-	err := o.Raw("SELECT COUNT(*) FROM information_schema.TABLES WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ?", databaseName, tableName).QueryRow(&count)
-	if err == nil && count == 0 {
-		return false
-	}
-	return true
+	exists, err := NewSchemaInspector("default", databaseName).TableExists(tableName)
+	return err == nil && exists
 }
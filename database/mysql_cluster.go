@@ -0,0 +1,516 @@
+/**
+ * Created by goland.
+ * User: adam_wang
+ * Date: 2023-08-24 22:08:51
+ */
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/beego/beego/v2/client/orm"
+	"github.com/beego/beego/v2/client/orm/clauses/order_clause"
+	"github.com/beego/beego/v2/core/logs"
+	"github.com/beego/beego/v2/core/utils"
+	"net"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// replica 只读从库
+type replica struct {
+	alias      string
+	dsn        string
+	desc       string
+	options    []orm.DBOption
+	registered bool // 启动时不可用的从库在健康检查中重试注册
+	healthy    int32
+}
+
+// replicaSet 某个数据库别名下的从库集合
+type replicaSet struct {
+	replicas []*replica
+	next     uint32
+	stop     chan struct{}
+}
+
+var (
+	replicaSets   = make(map[string]*replicaSet)
+	replicaSetsMu sync.RWMutex
+)
+
+// _registerReplicas 注册数据库别名的只读从库并启动健康检查；
+// 注册失败（如从库不可用）的从库记录日志并标记为不可用，由健康检查重试注册，不影响主库初始化
+// @param alias string
+// @param cfg MySQLConfig
+// @param options []orm.DBOption
+// @return error
+func _registerReplicas(alias string, cfg MySQLConfig, options []orm.DBOption) error {
+	if len(cfg.Replicas) == 0 {
+		return nil
+	}
+
+	set := &replicaSet{stop: make(chan struct{})}
+	for i, addr := range cfg.Replicas {
+		replicaCfg := cfg
+		replicaCfg.Host, replicaCfg.Port = addr, ""
+		if host, port, err := net.SplitHostPort(addr); err == nil {
+			replicaCfg.Host, replicaCfg.Port = host, port
+		}
+		dsn, err := replicaCfg.DSN()
+		if err != nil {
+			return err
+		}
+
+		r := &replica{
+			alias:   fmt.Sprintf("%s_replica_%d", alias, i+1),
+			dsn:     dsn,
+			desc:    replicaCfg.String(),
+			options: options,
+		}
+		r.check()
+		set.replicas = append(set.replicas, r)
+	}
+
+	replicaSetsMu.Lock()
+	if old, ok := replicaSets[alias]; ok {
+		close(old.stop)
+	}
+	replicaSets[alias] = set
+	replicaSetsMu.Unlock()
+
+	interval := cfg.ReplicaCheckInterval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	go set.healthCheck(interval)
+
+	return nil
+}
+
+// StopReplicaCheck 停止所有从库的健康检查（如在程序退出或测试结束时调用），从库保持最后一次检查的状态
+func StopReplicaCheck() {
+	replicaSetsMu.Lock()
+	defer replicaSetsMu.Unlock()
+	for alias, set := range replicaSets {
+		close(set.stop)
+		delete(replicaSets, alias)
+	}
+}
+
+// check 检测从库是否可用，尚未注册的从库先尝试注册
+// @receiver r *replica
+func (r *replica) check() {
+	if !r.registered {
+		if _, err := orm.GetDB(r.alias); err == nil {
+			r.registered = true
+		} else if err := orm.RegisterDataBase(r.alias, "mysql", r.dsn, r.options...); err != nil {
+			if atomic.SwapInt32(&r.healthy, -1) != -1 {
+				logs.Warn("mysql register replica `%s` (%s) failed, will retry: %v", r.alias, r.desc, err)
+			}
+			return
+		} else {
+			r.registered = true
+			logs.Info("mysql replica `%s` registered: %s", r.alias, r.desc)
+		}
+	}
+
+	var healthy int32
+	if db, err := orm.GetDB(r.alias); err == nil {
+		pingCtx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		if db.PingContext(pingCtx) == nil {
+			healthy = 1
+		}
+		cancel()
+	}
+	if atomic.SwapInt32(&r.healthy, healthy) != healthy {
+		if healthy == 1 {
+			logs.Info("mysql replica `%s` is healthy", r.alias)
+		} else {
+			logs.Warn("mysql replica `%s` is unhealthy, reads fall back to other replicas or primary", r.alias)
+		}
+	}
+}
+
+// healthCheck 定时检测所有从库，直到StopReplicaCheck或同一别名重新初始化
+// @receiver s *replicaSet
+// @param interval time.Duration
+func (s *replicaSet) healthCheck(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			for _, r := range s.replicas {
+				r.check()
+			}
+		}
+	}
+}
+
+// pick 轮询选取一个可用的从库，全部不可用时返回空
+// @receiver s *replicaSet
+// @return string
+func (s *replicaSet) pick() string {
+	n := uint32(len(s.replicas))
+	start := atomic.AddUint32(&s.next, 1)
+	for i := uint32(0); i < n; i++ {
+		r := s.replicas[(start+i)%n]
+		if atomic.LoadInt32(&r.healthy) == 1 {
+			return r.alias
+		}
+	}
+	return ""
+}
+
+// ReadAlias 获取用于只读查询的数据库别名，在可用从库间负载均衡，无可用从库时返回主库别名
+// @param alias string
+// @return string
+func ReadAlias(alias string) string {
+	if alias == "" {
+		alias = "default"
+	}
+	replicaSetsMu.RLock()
+	set, ok := replicaSets[alias]
+	replicaSetsMu.RUnlock()
+	if !ok {
+		return alias
+	}
+	if replicaAlias := set.pick(); replicaAlias != "" {
+		return replicaAlias
+	}
+	return alias
+}
+
+// ReadOrm 获取只读查询使用的Ormer（从库）
+// @param alias string
+// @return orm.Ormer
+func ReadOrm(alias string) orm.Ormer {
+	return orm.NewOrmUsingDB(ReadAlias(alias))
+}
+
+// WriteOrm 获取写操作及事务使用的Ormer（主库）
+// @param alias string
+// @return orm.Ormer
+func WriteOrm(alias string) orm.Ormer {
+	return orm.NewOrmUsingDB(WriteAlias(alias))
+}
+
+// splitOrmer 读写分离的Ormer，Read/LoadRelated及QueryTable、Raw的只读查询走从库，写操作及事务走主库
+type splitOrmer struct {
+	orm.Ormer
+	alias string
+}
+
+// NewOrm 获取读写分离的Ormer
+// Read、LoadRelated，QueryTable的All、One、Count、Exist、Values等查询，以及Raw的SELECT语句的QueryRow、QueryRows、Values等查询在从库执行；
+// 写操作、事务、ReadForUpdate、QueryTable的Update/Delete/ForUpdate、Raw的Exec及非SELECT语句在主库执行；
+// 从库存在复制延迟，写入后需要立即读取的数据请使用WriteOrm
+// @param alias string
+// @return orm.Ormer
+func NewOrm(alias string) orm.Ormer {
+	return &splitOrmer{Ormer: WriteOrm(alias), alias: alias}
+}
+
+func (o *splitOrmer) Read(md interface{}, cols ...string) error {
+	return ReadOrm(o.alias).Read(md, cols...)
+}
+
+func (o *splitOrmer) ReadWithCtx(ctx context.Context, md interface{}, cols ...string) error {
+	return ReadOrm(o.alias).ReadWithCtx(ctx, md, cols...)
+}
+
+func (o *splitOrmer) LoadRelated(md interface{}, name string, args ...utils.KV) (int64, error) {
+	return ReadOrm(o.alias).LoadRelated(md, name, args...)
+}
+
+func (o *splitOrmer) LoadRelatedWithCtx(ctx context.Context, md interface{}, name string, args ...utils.KV) (int64, error) {
+	return ReadOrm(o.alias).LoadRelatedWithCtx(ctx, md, name, args...)
+}
+
+func (o *splitOrmer) QueryTable(ptrStructOrTableName interface{}) orm.QuerySeter {
+	primary := o.Ormer.QueryTable(ptrStructOrTableName)
+	readAlias := ReadAlias(o.alias)
+	if readAlias == WriteAlias(o.alias) {
+		return primary
+	}
+	return &splitQuerySeter{primary: primary, replica: orm.NewOrmUsingDB(readAlias).QueryTable(ptrStructOrTableName)}
+}
+
+func (o *splitOrmer) Raw(query string, args ...interface{}) orm.RawSeter {
+	return o.RawWithCtx(context.Background(), query, args...)
+}
+
+func (o *splitOrmer) RawWithCtx(ctx context.Context, query string, args ...interface{}) orm.RawSeter {
+	primary := o.Ormer.RawWithCtx(ctx, query, args...)
+	readAlias := ReadAlias(o.alias)
+	if readAlias == WriteAlias(o.alias) || !_isReadQuery(query) {
+		return primary
+	}
+	return &splitRawSeter{primary: primary, replica: orm.NewOrmUsingDB(readAlias).RawWithCtx(ctx, query, args...)}
+}
+
+// _primaryExecutor 获取在主库执行的QueryExecutor，读写分离的Ormer返回其主库Ormer，其他（如事务）原样返回
+// @param o orm.QueryExecutor
+// @return orm.QueryExecutor
+func _primaryExecutor(o orm.QueryExecutor) orm.QueryExecutor {
+	if split, ok := o.(*splitOrmer); ok {
+		return split.Ormer
+	}
+	return o
+}
+
+// WriteAlias 获取写操作使用的数据库别名（主库）
+// @param alias string
+// @return string
+func WriteAlias(alias string) string {
+	if alias == "" {
+		alias = "default"
+	}
+	return alias
+}
+
+var (
+	readQueryRegexp    = regexp.MustCompile(`(?i)^\s*(\(\s*)*(SELECT|WITH)\b`)
+	primaryQueryRegexp = regexp.MustCompile(`(?i)\bFOR\s+(UPDATE|SHARE)\b|\bLOCK\s+IN\s+SHARE\s+MODE\b|\bINTO\s+(OUTFILE|DUMPFILE|@)|\b(INSERT|UPDATE|DELETE|REPLACE)\s`)
+)
+
+// _isReadQuery 是否为可以在从库执行的只读查询（SELECT语句，不加锁且不包含写语句，无法判断时在主库执行）
+// @param query string
+// @return bool
+func _isReadQuery(query string) bool {
+	return readQueryRegexp.MatchString(query) && !primaryQueryRegexp.MatchString(query)
+}
+
+// splitQuerySeter 读写分离的QuerySeter，条件同时作用于主库及从库的QuerySeter，查询在从库执行，更新、删除及ForUpdate在主库执行
+type splitQuerySeter struct {
+	primary   orm.QuerySeter
+	replica   orm.QuerySeter
+	forUpdate bool
+}
+
+// chain 对主库及从库的QuerySeter执行相同的条件方法
+// @receiver q *splitQuerySeter
+// @param fn func(qs orm.QuerySeter) orm.QuerySeter
+// @return orm.QuerySeter
+func (q *splitQuerySeter) chain(fn func(qs orm.QuerySeter) orm.QuerySeter) orm.QuerySeter {
+	return &splitQuerySeter{primary: fn(q.primary), replica: fn(q.replica), forUpdate: q.forUpdate}
+}
+
+// read 获取执行查询的QuerySeter，ForUpdate时使用主库
+// @receiver q *splitQuerySeter
+// @return orm.QuerySeter
+func (q *splitQuerySeter) read() orm.QuerySeter {
+	if q.forUpdate {
+		return q.primary
+	}
+	return q.replica
+}
+
+func (q *splitQuerySeter) Filter(expr string, args ...interface{}) orm.QuerySeter {
+	return q.chain(func(qs orm.QuerySeter) orm.QuerySeter { return qs.Filter(expr, args...) })
+}
+
+func (q *splitQuerySeter) FilterRaw(expr string, sql string) orm.QuerySeter {
+	return q.chain(func(qs orm.QuerySeter) orm.QuerySeter { return qs.FilterRaw(expr, sql) })
+}
+
+func (q *splitQuerySeter) Exclude(expr string, args ...interface{}) orm.QuerySeter {
+	return q.chain(func(qs orm.QuerySeter) orm.QuerySeter { return qs.Exclude(expr, args...) })
+}
+
+func (q *splitQuerySeter) SetCond(cond *orm.Condition) orm.QuerySeter {
+	return q.chain(func(qs orm.QuerySeter) orm.QuerySeter { return qs.SetCond(cond) })
+}
+
+func (q *splitQuerySeter) GetCond() *orm.Condition {
+	return q.primary.GetCond()
+}
+
+func (q *splitQuerySeter) Limit(limit interface{}, args ...interface{}) orm.QuerySeter {
+	return q.chain(func(qs orm.QuerySeter) orm.QuerySeter { return qs.Limit(limit, args...) })
+}
+
+func (q *splitQuerySeter) Offset(offset interface{}) orm.QuerySeter {
+	return q.chain(func(qs orm.QuerySeter) orm.QuerySeter { return qs.Offset(offset) })
+}
+
+func (q *splitQuerySeter) GroupBy(exprs ...string) orm.QuerySeter {
+	return q.chain(func(qs orm.QuerySeter) orm.QuerySeter { return qs.GroupBy(exprs...) })
+}
+
+func (q *splitQuerySeter) OrderBy(exprs ...string) orm.QuerySeter {
+	return q.chain(func(qs orm.QuerySeter) orm.QuerySeter { return qs.OrderBy(exprs...) })
+}
+
+func (q *splitQuerySeter) OrderClauses(orders ...*order_clause.Order) orm.QuerySeter {
+	return q.chain(func(qs orm.QuerySeter) orm.QuerySeter { return qs.OrderClauses(orders...) })
+}
+
+func (q *splitQuerySeter) ForceIndex(indexes ...string) orm.QuerySeter {
+	return q.chain(func(qs orm.QuerySeter) orm.QuerySeter { return qs.ForceIndex(indexes...) })
+}
+
+func (q *splitQuerySeter) UseIndex(indexes ...string) orm.QuerySeter {
+	return q.chain(func(qs orm.QuerySeter) orm.QuerySeter { return qs.UseIndex(indexes...) })
+}
+
+func (q *splitQuerySeter) IgnoreIndex(indexes ...string) orm.QuerySeter {
+	return q.chain(func(qs orm.QuerySeter) orm.QuerySeter { return qs.IgnoreIndex(indexes...) })
+}
+
+func (q *splitQuerySeter) RelatedSel(params ...interface{}) orm.QuerySeter {
+	return q.chain(func(qs orm.QuerySeter) orm.QuerySeter { return qs.RelatedSel(params...) })
+}
+
+func (q *splitQuerySeter) Distinct() orm.QuerySeter {
+	return q.chain(func(qs orm.QuerySeter) orm.QuerySeter { return qs.Distinct() })
+}
+
+func (q *splitQuerySeter) ForUpdate() orm.QuerySeter {
+	return &splitQuerySeter{primary: q.primary.ForUpdate(), replica: q.replica, forUpdate: true}
+}
+
+func (q *splitQuerySeter) Aggregate(s string) orm.QuerySeter {
+	return q.chain(func(qs orm.QuerySeter) orm.QuerySeter { return qs.Aggregate(s) })
+}
+
+func (q *splitQuerySeter) Count() (int64, error) {
+	return q.read().Count()
+}
+
+func (q *splitQuerySeter) CountWithCtx(ctx context.Context) (int64, error) {
+	return q.read().CountWithCtx(ctx)
+}
+
+func (q *splitQuerySeter) Exist() bool {
+	return q.read().Exist()
+}
+
+func (q *splitQuerySeter) ExistWithCtx(ctx context.Context) bool {
+	return q.read().ExistWithCtx(ctx)
+}
+
+func (q *splitQuerySeter) Update(values orm.Params) (int64, error) {
+	return q.primary.Update(values)
+}
+
+func (q *splitQuerySeter) UpdateWithCtx(ctx context.Context, values orm.Params) (int64, error) {
+	return q.primary.UpdateWithCtx(ctx, values)
+}
+
+func (q *splitQuerySeter) Delete() (int64, error) {
+	return q.primary.Delete()
+}
+
+func (q *splitQuerySeter) DeleteWithCtx(ctx context.Context) (int64, error) {
+	return q.primary.DeleteWithCtx(ctx)
+}
+
+func (q *splitQuerySeter) PrepareInsert() (orm.Inserter, error) {
+	return q.primary.PrepareInsert()
+}
+
+func (q *splitQuerySeter) PrepareInsertWithCtx(ctx context.Context) (orm.Inserter, error) {
+	return q.primary.PrepareInsertWithCtx(ctx)
+}
+
+func (q *splitQuerySeter) All(container interface{}, cols ...string) (int64, error) {
+	return q.read().All(container, cols...)
+}
+
+func (q *splitQuerySeter) AllWithCtx(ctx context.Context, container interface{}, cols ...string) (int64, error) {
+	return q.read().AllWithCtx(ctx, container, cols...)
+}
+
+func (q *splitQuerySeter) One(container interface{}, cols ...string) error {
+	return q.read().One(container, cols...)
+}
+
+func (q *splitQuerySeter) OneWithCtx(ctx context.Context, container interface{}, cols ...string) error {
+	return q.read().OneWithCtx(ctx, container, cols...)
+}
+
+func (q *splitQuerySeter) Values(results *[]orm.Params, exprs ...string) (int64, error) {
+	return q.read().Values(results, exprs...)
+}
+
+func (q *splitQuerySeter) ValuesWithCtx(ctx context.Context, results *[]orm.Params, exprs ...string) (int64, error) {
+	return q.read().ValuesWithCtx(ctx, results, exprs...)
+}
+
+func (q *splitQuerySeter) ValuesList(results *[]orm.ParamsList, exprs ...string) (int64, error) {
+	return q.read().ValuesList(results, exprs...)
+}
+
+func (q *splitQuerySeter) ValuesListWithCtx(ctx context.Context, results *[]orm.ParamsList, exprs ...string) (int64, error) {
+	return q.read().ValuesListWithCtx(ctx, results, exprs...)
+}
+
+func (q *splitQuerySeter) ValuesFlat(result *orm.ParamsList, expr string) (int64, error) {
+	return q.read().ValuesFlat(result, expr)
+}
+
+func (q *splitQuerySeter) ValuesFlatWithCtx(ctx context.Context, result *orm.ParamsList, expr string) (int64, error) {
+	return q.read().ValuesFlatWithCtx(ctx, result, expr)
+}
+
+func (q *splitQuerySeter) RowsToMap(result *orm.Params, keyCol, valueCol string) (int64, error) {
+	return q.read().RowsToMap(result, keyCol, valueCol)
+}
+
+func (q *splitQuerySeter) RowsToStruct(ptrStruct interface{}, keyCol, valueCol string) (int64, error) {
+	return q.read().RowsToStruct(ptrStruct, keyCol, valueCol)
+}
+
+// splitRawSeter 读写分离的RawSeter，只读SELECT语句的查询在从库执行，Exec及Prepare在主库执行
+type splitRawSeter struct {
+	primary orm.RawSeter
+	replica orm.RawSeter
+}
+
+func (r *splitRawSeter) Exec() (sql.Result, error) {
+	return r.primary.Exec()
+}
+
+func (r *splitRawSeter) QueryRow(containers ...interface{}) error {
+	return r.replica.QueryRow(containers...)
+}
+
+func (r *splitRawSeter) QueryRows(containers ...interface{}) (int64, error) {
+	return r.replica.QueryRows(containers...)
+}
+
+func (r *splitRawSeter) SetArgs(args ...interface{}) orm.RawSeter {
+	return &splitRawSeter{primary: r.primary.SetArgs(args...), replica: r.replica.SetArgs(args...)}
+}
+
+func (r *splitRawSeter) Values(container *[]orm.Params, cols ...string) (int64, error) {
+	return r.replica.Values(container, cols...)
+}
+
+func (r *splitRawSeter) ValuesList(container *[]orm.ParamsList, cols ...string) (int64, error) {
+	return r.replica.ValuesList(container, cols...)
+}
+
+func (r *splitRawSeter) ValuesFlat(container *orm.ParamsList, cols ...string) (int64, error) {
+	return r.replica.ValuesFlat(container, cols...)
+}
+
+func (r *splitRawSeter) RowsToMap(result *orm.Params, keyCol, valueCol string) (int64, error) {
+	return r.replica.RowsToMap(result, keyCol, valueCol)
+}
+
+func (r *splitRawSeter) RowsToStruct(ptrStruct interface{}, keyCol, valueCol string) (int64, error) {
+	return r.replica.RowsToStruct(ptrStruct, keyCol, valueCol)
+}
+
+func (r *splitRawSeter) Prepare() (orm.RawPreparer, error) {
+	return r.primary.Prepare()
+}
@@ -0,0 +1,59 @@
+/**
+ * Created by goland.
+ * User: adam_wang
+ * Date: 2023-08-24 22:08:51
+ */
+
+package database
+
+import (
+	"github.com/beego/beego/v2/client/orm"
+	"testing"
+)
+
+func TestIsReadQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{"SELECT * FROM user WHERE id = ?", true},
+		{"  select id from user", true},
+		{"(SELECT id FROM a) UNION (SELECT id FROM b)", true},
+		{"WITH t AS (SELECT id FROM user) SELECT * FROM t", true},
+		{"SELECT * FROM user WHERE id = ? FOR UPDATE", false},
+		{"SELECT * FROM user FOR SHARE", false},
+		{"SELECT * FROM user LOCK IN SHARE MODE", false},
+		{"SELECT id INTO @id FROM user LIMIT 1", false},
+		{"WITH t AS (SELECT id FROM user) DELETE FROM user WHERE id IN (SELECT id FROM t)", false},
+		{"UPDATE user SET name = ?", false},
+		{"INSERT INTO user (name) VALUES (?)", false},
+		{"CALL refresh_stats()", false},
+		{"SHOW TABLES", false},
+	}
+	for _, tt := range tests {
+		if got := _isReadQuery(tt.query); got != tt.want {
+			t.Errorf("_isReadQuery(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+type primaryTestOrmer struct {
+	orm.Ormer
+}
+
+func TestPrimaryExecutor(t *testing.T) {
+	primary := &primaryTestOrmer{}
+	tests := []struct {
+		name string
+		o    orm.QueryExecutor
+		want orm.QueryExecutor
+	}{
+		{"split ormer", &splitOrmer{Ormer: primary, alias: "default"}, primary},
+		{"plain ormer", primary, primary},
+	}
+	for _, tt := range tests {
+		if got := _primaryExecutor(tt.o); got != tt.want {
+			t.Errorf("%s: _primaryExecutor() = %T, want %T", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+/**
+ * Created by goland.
+ * User: adam_wang
+ * Date: 2023-08-22 21:35:12
+ */
+
+package database
+
+import (
+	"errors"
+	"fmt"
+	"github.com/beego/beego/v2/client/orm"
+	"github.com/beego/beego/v2/core/logs"
+	beego "github.com/beego/beego/v2/server/web"
+	"github.com/go-sql-driver/mysql"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MySQLConfig MySQL连接配置
+type MySQLConfig struct {
+	Alias           string            // 数据库别名，默认为default
+	Host            string            // 地址
+	Port            string            // 端口，默认为3306
+	User            string            // 用户名
+	Password        string            // 密码
+	Database        string            // 数据库名
+	Charset         string            // 字符集，默认为utf8，推荐utf8mb4
+	Collation       string            // 排序规则
+	Loc             string            // 时区（如Local、Asia/Shanghai），需配合ParseTime使用
+	ParseTime       bool              // 是否将DATE/DATETIME解析为time.Time
+	TLS             string            // TLS配置（true、false、skip-verify、preferred或通过mysql.RegisterTLSConfig注册的名称）
+	Timeout         time.Duration     // 连接超时时间
+	ReadTimeout     time.Duration     // 读超时时间
+	WriteTimeout    time.Duration     // 写超时时间
+	MaxIdleConns    int               // 最大空闲连接数
+	MaxOpenConns    int               // 最大打开连接数
+	ConnMaxLifetime time.Duration     // 连接最大存活时间，默认为600秒
+	Params          map[string]string // 其他DSN参数
+
+	Replicas             []string      // 只读从库地址列表（host:port），与主库使用相同的账号和库名
+	ReplicaCheckInterval time.Duration // 从库健康检查间隔，默认为10秒
+}
+
+// LoadMySQLConfig 从app.conf中读取指定配置段的MySQL配置，环境变量优先
+// 环境变量名为配置段名与配置项名（去掉mysql_前缀）的大写组合，如[mysql]的mysql_pass对应MYSQL_PASS；
+// parse_time、超时时间、连接池大小等配置无法解析时返回错误（包含所有无法解析的配置项）
+// @param section string
+// @return MySQLConfig
+// @return error
+func LoadMySQLConfig(section string) (MySQLConfig, error) {
+	get := func(key string) string {
+		envKey := strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(section) + "_" + strings.TrimPrefix(key, "mysql_"))
+		if value, ok := os.LookupEnv(envKey); ok {
+			return value
+		}
+		value, _ := beego.AppConfig.String(section + "::" + key)
+		return value
+	}
+
+	var errs []error
+	getBool := func(key string) bool {
+		value := get(key)
+		if value == "" {
+			return false
+		}
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("mysql config [%s] %s: invalid bool %q", section, key, value))
+		}
+		return b
+	}
+	getInt := func(key string) int {
+		value := get(key)
+		if value == "" {
+			return 0
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			errs = append(errs, fmt.Errorf("mysql config [%s] %s: invalid number %q", section, key, value))
+		}
+		return n
+	}
+	getDuration := func(key string) time.Duration {
+		duration, err := _parseDuration(get(key))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("mysql config [%s] %s: %w", section, key, err))
+		}
+		return duration
+	}
+
+	cfg := MySQLConfig{
+		Host:      get("mysql_urls"),
+		Port:      get("mysql_port"),
+		User:      get("mysql_user"),
+		Password:  get("mysql_pass"),
+		Database:  get("mysql_db"),
+		Charset:   get("charset"),
+		Collation: get("collation"),
+		Loc:       get("loc"),
+		TLS:       get("tls"),
+	}
+	cfg.ParseTime = getBool("parse_time")
+	cfg.Timeout = getDuration("timeout")
+	cfg.ReadTimeout = getDuration("read_timeout")
+	cfg.WriteTimeout = getDuration("write_timeout")
+	cfg.ConnMaxLifetime = getDuration("conn_max_lifetime")
+	cfg.MaxIdleConns = getInt("max_idle_conns")
+	cfg.MaxOpenConns = getInt("max_open_conns")
+	cfg.ReplicaCheckInterval = getDuration("replica_check_interval")
+	for _, replica := range strings.Split(get("replicas"), ",") {
+		if replica = strings.TrimSpace(replica); replica != "" {
+			cfg.Replicas = append(cfg.Replicas, replica)
+		}
+	}
+
+	return cfg, errors.Join(errs...)
+}
+
+// DSN 生成go-sql-driver/mysql的连接字符串
+// @receiver c MySQLConfig
+// @return string
+// @return error
+func (c MySQLConfig) DSN() (string, error) {
+	dsnConfig := mysql.NewConfig()
+	dsnConfig.User = c.User
+	dsnConfig.Passwd = c.Password
+	dsnConfig.Net = "tcp"
+	port := c.Port
+	if port == "" {
+		port = "3306"
+	}
+	dsnConfig.Addr = c.Host + ":" + port
+	dsnConfig.DBName = c.Database
+	dsnConfig.Collation = c.Collation
+	dsnConfig.ParseTime = c.ParseTime
+	dsnConfig.TLSConfig = c.TLS
+	dsnConfig.Timeout = c.Timeout
+	dsnConfig.ReadTimeout = c.ReadTimeout
+	dsnConfig.WriteTimeout = c.WriteTimeout
+
+	if c.Loc != "" {
+		loc, err := time.LoadLocation(c.Loc)
+		if err != nil {
+			return "", fmt.Errorf("mysql config: invalid loc %q: %w", c.Loc, err)
+		}
+		dsnConfig.Loc = loc
+	}
+
+	dsnConfig.Params = make(map[string]string, len(c.Params)+1)
+	for k, v := range c.Params {
+		dsnConfig.Params[k] = v
+	}
+	charset := c.Charset
+	if charset == "" {
+		charset = "utf8"
+	}
+	dsnConfig.Params["charset"] = charset
+
+	return dsnConfig.FormatDSN(), nil
+}
+
+// String 输出隐藏密码后的连接信息，用于日志记录
+// @receiver c MySQLConfig
+// @return string
+func (c MySQLConfig) String() string {
+	if c.Password != "" {
+		c.Password = "******"
+	}
+	dsn, err := c.DSN()
+	if err != nil {
+		return fmt.Sprintf("%s@%s:%s/%s", c.User, c.Host, c.Port, c.Database)
+	}
+	return dsn
+}
+
+// InitMySQL 按配置注册MySQL数据库并检测连接
+// @param cfg MySQLConfig
+// @return error
+func InitMySQL(cfg MySQLConfig) error {
+	if cfg.Host == "" {
+		return errors.New("mysql config: host is required")
+	}
+	if cfg.Timeout < 0 || cfg.ReadTimeout < 0 || cfg.WriteTimeout < 0 || cfg.ConnMaxLifetime < 0 || cfg.ReplicaCheckInterval < 0 {
+		return errors.New("mysql config: durations must not be negative")
+	}
+	if cfg.MaxIdleConns < 0 || cfg.MaxOpenConns < 0 {
+		return errors.New("mysql config: pool sizes must not be negative")
+	}
+	alias := cfg.Alias
+	if alias == "" {
+		alias = "default"
+	}
+
+	dsn, err := cfg.DSN()
+	if err != nil {
+		return err
+	}
+
+	err = orm.RegisterDriver("mysql", orm.DRMySQL)
+	if err != nil {
+		return fmt.Errorf("mysql register driver: %w", err)
+	}
+
+	lifetime := cfg.ConnMaxLifetime
+	if lifetime <= 0 {
+		lifetime = 600 * time.Second
+	}
+	options := []orm.DBOption{orm.ConnMaxLifetime(lifetime)}
+	if cfg.MaxIdleConns > 0 {
+		options = append(options, orm.MaxIdleConnections(cfg.MaxIdleConns))
+	}
+	if cfg.MaxOpenConns > 0 {
+		options = append(options, orm.MaxOpenConnections(cfg.MaxOpenConns))
+	}
+
+	err = orm.RegisterDataBase(alias, "mysql", dsn, options...)
+	if err != nil {
+		return fmt.Errorf("mysql register database `%s` (%s): %w", alias, cfg, err)
+	}
+
+	db, err := orm.GetDB(alias)
+	if err != nil {
+		return fmt.Errorf("mysql get database `%s`: %w", alias, err)
+	}
+	if err = db.Ping(); err != nil {
+		return fmt.Errorf("mysql ping `%s` (%s): %w", alias, cfg, err)
+	}
+
+	logs.Info("mysql database `%s` registered: %s", alias, cfg)
+
+	return _registerReplicas(alias, cfg, options)
+}
+
+// _parseDuration 解析时间配置，纯数字按秒处理
+// @param value string
+// @return time.Duration
+// @return error
+func _parseDuration(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		value = strconv.Itoa(seconds) + "s"
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	return duration, nil
+}
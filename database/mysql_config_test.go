@@ -0,0 +1,69 @@
+/**
+ * Created by goland.
+ * User: adam_wang
+ * Date: 2023-08-22 21:35:12
+ */
+
+package database
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadMySQLConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr []string
+		check   func(t *testing.T, cfg MySQLConfig)
+	}{
+		{
+			name: "valid values",
+			env: map[string]string{
+				"TESTDB_URLS": "127.0.0.1", "TESTDB_PARSE_TIME": "true", "TESTDB_TIMEOUT": "5",
+				"TESTDB_READ_TIMEOUT": "1m", "TESTDB_MAX_OPEN_CONNS": "20", "TESTDB_REPLICAS": "a:3306, b:3306",
+			},
+			check: func(t *testing.T, cfg MySQLConfig) {
+				if cfg.Host != "127.0.0.1" || !cfg.ParseTime || cfg.Timeout != 5*time.Second || cfg.ReadTimeout != time.Minute ||
+					cfg.MaxOpenConns != 20 || len(cfg.Replicas) != 2 {
+					t.Errorf("unexpected config %+v", cfg)
+				}
+			},
+		},
+		{
+			name:    "invalid bool",
+			env:     map[string]string{"TESTDB_PARSE_TIME": "yes"},
+			wantErr: []string{"parse_time"},
+		},
+		{
+			name:    "invalid values are all reported",
+			env:     map[string]string{"TESTDB_TIMEOUT": "5sec", "TESTDB_MAX_IDLE_CONNS": "ten", "TESTDB_CONN_MAX_LIFETIME": "-1"},
+			wantErr: []string{"timeout", "max_idle_conns", "conn_max_lifetime"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			cfg, err := LoadMySQLConfig("testdb")
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				tt.check(t, cfg)
+				return
+			}
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			for _, key := range tt.wantErr {
+				if !strings.Contains(err.Error(), key) {
+					t.Errorf("error %q does not mention %s", err, key)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+/**
+ * Created by goland.
+ * User: adam_wang
+ * Date: 2023-08-26 15:41:07
+ */
+
+package database
+
+import (
+	"fmt"
+	"github.com/beego/beego/v2/client/orm"
+	"github.com/beego/beego/v2/core/logs"
+	beego "github.com/beego/beego/v2/server/web"
+	"sort"
+	"sync"
+)
+
+var (
+	registeredModels   = make(map[string][]interface{})
+	registeredModelsMu sync.RWMutex
+)
+
+// RegisterModels 注册ORM模型到默认数据库，prefix为表名前缀（可以为空）
+// @param prefix string
+// @param models ...interface{}
+func RegisterModels(prefix string, models ...interface{}) {
+	RegisterModelsWithAlias("default", prefix, models...)
+}
+
+// RegisterModelsWithAlias 注册ORM模型并归属到指定数据库别名，prefix为表名前缀（可以为空）
+// 注：beego ORM的模型为全局注册，别名只记录模型归属（见RegisteredModels、SyncModels），不限制模型在哪个数据库中使用
+// @param alias string
+// @param prefix string
+// @param models ...interface{}
+func RegisterModelsWithAlias(alias, prefix string, models ...interface{}) {
+	if len(models) == 0 {
+		return
+	}
+	if alias == "" {
+		alias = "default"
+	}
+
+	orm.RegisterModelWithPrefix(prefix, models...)
+	for _, model := range models {
+		_setModelPrefix(model, prefix)
+	}
+
+	registeredModelsMu.Lock()
+	registeredModels[alias] = append(registeredModels[alias], models...)
+	registeredModelsMu.Unlock()
+}
+
+// SetModelPrefix 记录直接通过orm.RegisterModelWithPrefix注册的模型的表名前缀，
+// BulkInsert、DiffModel等按模型生成表名时使用（通过RegisterModels注册的模型无需调用）
+// @param prefix string
+// @param models ...interface{}
+func SetModelPrefix(prefix string, models ...interface{}) {
+	for _, model := range models {
+		_setModelPrefix(model, prefix)
+	}
+}
+
+// RegisteredModels 获取归属到指定数据库别名的已注册模型
+// @param alias string
+// @return []interface{}
+func RegisteredModels(alias string) []interface{} {
+	if alias == "" {
+		alias = "default"
+	}
+
+	registeredModelsMu.RLock()
+	defer registeredModelsMu.RUnlock()
+
+	return append([]interface{}{}, registeredModels[alias]...)
+}
+
+// SyncModels 同步已注册模型的表结构：按RegisterModelsWithAlias记录的归属对每个数据库别名分别执行orm.RunSyncdb，
+// 各数据库只创建归属于它的模型的表；
+// beego的模型缓存为全局缓存，模型归属于多个数据库时会临时重置模型缓存、只注册当前别名的模型后执行同步，完成后恢复全部模型，因此：
+// 1、需在应用启动阶段、使用ORM之前调用（mysql::auto_sync即在启动时执行）；
+// 2、所有模型都需通过RegisterModels/RegisterModelsWithAlias注册，直接通过orm.RegisterModel注册的模型在恢复后会丢失；
+// 3、模型的关联字段（rel/reverse）只能指向同一数据库别名下的模型
+// @param force bool 是否先删除表再创建
+// @param verbose bool 是否输出执行的SQL
+// @return error
+func SyncModels(force bool, verbose bool) error {
+	registeredModelsMu.RLock()
+	aliases := make([]string, 0, len(registeredModels))
+	groups := make(map[string][]interface{}, len(registeredModels))
+	for alias, models := range registeredModels {
+		aliases = append(aliases, alias)
+		groups[alias] = append([]interface{}{}, models...)
+	}
+	registeredModelsMu.RUnlock()
+	sort.Strings(aliases)
+
+	if len(aliases) == 0 {
+		return nil
+	}
+	for _, alias := range aliases {
+		if _, err := orm.GetDB(alias); err != nil {
+			return fmt.Errorf("mysql sync models: database `%s` not registered: %w", alias, err)
+		}
+	}
+	if len(aliases) == 1 {
+		if err := orm.RunSyncdb(aliases[0], force, verbose); err != nil {
+			return fmt.Errorf("mysql sync models `%s`: %w", aliases[0], err)
+		}
+		return nil
+	}
+
+	defer func() {
+		orm.ResetModelCache()
+		for _, alias := range aliases {
+			_registerModelGroup(groups[alias])
+		}
+		orm.BootStrap()
+	}()
+	for _, alias := range aliases {
+		orm.ResetModelCache()
+		_registerModelGroup(groups[alias])
+		if err := orm.RunSyncdb(alias, force, verbose); err != nil {
+			return fmt.Errorf("mysql sync models `%s`: %w", alias, err)
+		}
+	}
+	return nil
+}
+
+// _registerModelGroup 按记录的表名前缀将模型重新注册到beego的模型缓存，保持原注册顺序
+// @param models []interface{}
+func _registerModelGroup(models []interface{}) {
+	for i := 0; i < len(models); {
+		prefix := _modelPrefix(models[i])
+		j := i + 1
+		for j < len(models) && _modelPrefix(models[j]) == prefix {
+			j++
+		}
+		orm.RegisterModelWithPrefix(prefix, models[i:j]...)
+		i = j
+	}
+}
+
+// _autoSyncModels 开发模式（runmode = dev）且配置mysql::auto_sync = true时，在应用启动时自动同步表结构
+// @return error
+func _autoSyncModels() error {
+	if beego.BConfig.RunMode != beego.DEV {
+		return nil
+	}
+	autoSync, _ := beego.AppConfig.Bool("mysql::auto_sync")
+	if !autoSync {
+		return nil
+	}
+
+	if err := SyncModels(false, true); err != nil {
+		logs.Error("failed to auto sync models: %v", err)
+		return err
+	}
+	return nil
+}
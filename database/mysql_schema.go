@@ -0,0 +1,475 @@
+/**
+ * Created by goland.
+ * User: adam_wang
+ * Date: 2023-09-02 17:10:52
+ */
+
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/beego/beego/v2/client/orm"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var intDisplayWidthRegexp = regexp.MustCompile(`^(tinyint|smallint|mediumint|int|bigint)\(\d+\)`)
+
+// Column 表字段信息
+type Column struct {
+	Name       string  // 字段名
+	Position   int     // 字段顺序
+	DataType   string  // 数据类型，如varchar
+	ColumnType string  // 完整类型，如varchar(255) unsigned
+	Nullable   bool    // 是否允许为NULL
+	Default    *string // 默认值，无默认值时为nil
+	Key        string  // 键类型：PRI、UNI、MUL
+	Extra      string  // 额外信息，如auto_increment
+	Comment    string  // 注释
+}
+
+// Index 索引信息
+type Index struct {
+	Name    string   // 索引名
+	Columns []string // 按顺序排列的索引字段
+	Unique  bool     // 是否唯一索引
+	Type    string   // 索引类型，如BTREE
+}
+
+// ForeignKey 外键信息
+type ForeignKey struct {
+	Name             string // 外键名
+	Column           string // 字段
+	ReferencedTable  string // 关联表
+	ReferencedColumn string // 关联字段
+	OnUpdate         string // 更新规则
+	OnDelete         string // 删除规则
+}
+
+// TableSchema 表结构信息
+type TableSchema struct {
+	Name        string
+	Engine      string
+	Comment     string
+	Columns     []Column
+	Indexes     []Index
+	ForeignKeys []ForeignKey
+}
+
+// ColumnDiff 模型字段与数据表字段的差异
+type ColumnDiff struct {
+	Column string // 字段名
+	Field  string // 模型字段名
+	Reason string // 差异说明
+}
+
+// ModelDiff 已注册ORM模型与数据表的差异
+type ModelDiff struct {
+	Table          string       // 表名
+	TableMissing   bool         // 数据表不存在
+	MissingColumns []string     // 模型中有而数据表中没有的字段
+	ExtraColumns   []string     // 数据表中有而模型中没有的字段
+	Mismatched     []ColumnDiff // 字段属性不一致
+}
+
+// Empty 是否没有差异
+// @receiver d *ModelDiff
+// @return bool
+func (d *ModelDiff) Empty() bool {
+	return !d.TableMissing && len(d.MissingColumns) == 0 && len(d.ExtraColumns) == 0 && len(d.Mismatched) == 0
+}
+
+// SchemaInspector 表结构查询器
+type SchemaInspector struct {
+	Alias    string // 数据库别名，默认为default
+	Database string // 数据库名，为空时使用当前连接的数据库
+}
+
+// NewSchemaInspector 创建表结构查询器
+// @param alias string
+// @param databaseName string
+// @return *SchemaInspector
+func NewSchemaInspector(alias, databaseName string) *SchemaInspector {
+	return &SchemaInspector{Alias: alias, Database: databaseName}
+}
+
+// Tables 获取数据库中所有表名
+// @receiver s *SchemaInspector
+// @return []string
+// @return error
+func (s *SchemaInspector) Tables() ([]string, error) {
+	rows, err := s.query("SELECT TABLE_NAME FROM information_schema.TABLES WHERE TABLE_SCHEMA = "+s.schema()+" AND TABLE_TYPE = 'BASE TABLE' ORDER BY TABLE_NAME", s.Database)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		tables = append(tables, name)
+	}
+	return tables, rows.Err()
+}
+
+// Table 获取表的完整结构（字段、索引、外键）
+// @receiver s *SchemaInspector
+// @param tableName string
+// @return *TableSchema
+// @return error
+func (s *SchemaInspector) Table(tableName string) (*TableSchema, error) {
+	rows, err := s.query("SELECT IFNULL(ENGINE, ''), IFNULL(TABLE_COMMENT, '') FROM information_schema.TABLES WHERE TABLE_SCHEMA = "+s.schema()+" AND TABLE_NAME = ?", s.Database, tableName)
+	if err != nil {
+		return nil, err
+	}
+	table := &TableSchema{Name: tableName}
+	found := rows.Next()
+	if found {
+		err = rows.Scan(&table.Engine, &table.Comment)
+	}
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("table `%s` does not exist", tableName)
+	}
+
+	if table.Columns, err = s.Columns(tableName); err != nil {
+		return nil, err
+	}
+	if table.Indexes, err = s.Indexes(tableName); err != nil {
+		return nil, err
+	}
+	if table.ForeignKeys, err = s.ForeignKeys(tableName); err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+// Columns 获取表的所有字段
+// @receiver s *SchemaInspector
+// @param tableName string
+// @return []Column
+// @return error
+func (s *SchemaInspector) Columns(tableName string) ([]Column, error) {
+	rows, err := s.query("SELECT COLUMN_NAME, ORDINAL_POSITION, DATA_TYPE, COLUMN_TYPE, IS_NULLABLE, COLUMN_DEFAULT, COLUMN_KEY, EXTRA, COLUMN_COMMENT "+
+		"FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = "+s.schema()+" AND TABLE_NAME = ? ORDER BY ORDINAL_POSITION", s.Database, tableName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []Column
+	for rows.Next() {
+		var column Column
+		var nullable string
+		var defaultValue sql.NullString
+		if err = rows.Scan(&column.Name, &column.Position, &column.DataType, &column.ColumnType, &nullable, &defaultValue, &column.Key, &column.Extra, &column.Comment); err != nil {
+			return nil, err
+		}
+		column.Nullable = nullable == "YES"
+		if defaultValue.Valid {
+			column.Default = &defaultValue.String
+		}
+		columns = append(columns, column)
+	}
+	return columns, rows.Err()
+}
+
+// Indexes 获取表的所有索引（含主键，主键索引名为PRIMARY）
+// @receiver s *SchemaInspector
+// @param tableName string
+// @return []Index
+// @return error
+func (s *SchemaInspector) Indexes(tableName string) ([]Index, error) {
+	rows, err := s.query("SELECT INDEX_NAME, COLUMN_NAME, NON_UNIQUE, INDEX_TYPE FROM information_schema.STATISTICS "+
+		"WHERE TABLE_SCHEMA = "+s.schema()+" AND TABLE_NAME = ? ORDER BY INDEX_NAME, SEQ_IN_INDEX", s.Database, tableName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var indexes []Index
+	positions := make(map[string]int)
+	for rows.Next() {
+		var name, column, indexType string
+		var nonUnique int
+		if err = rows.Scan(&name, &column, &nonUnique, &indexType); err != nil {
+			return nil, err
+		}
+		i, ok := positions[name]
+		if !ok {
+			i = len(indexes)
+			positions[name] = i
+			indexes = append(indexes, Index{Name: name, Unique: nonUnique == 0, Type: indexType})
+		}
+		indexes[i].Columns = append(indexes[i].Columns, column)
+	}
+	return indexes, rows.Err()
+}
+
+// ForeignKeys 获取表的所有外键
+// @receiver s *SchemaInspector
+// @param tableName string
+// @return []ForeignKey
+// @return error
+func (s *SchemaInspector) ForeignKeys(tableName string) ([]ForeignKey, error) {
+	rows, err := s.query("SELECT k.CONSTRAINT_NAME, k.COLUMN_NAME, k.REFERENCED_TABLE_NAME, k.REFERENCED_COLUMN_NAME, r.UPDATE_RULE, r.DELETE_RULE "+
+		"FROM information_schema.KEY_COLUMN_USAGE k JOIN information_schema.REFERENTIAL_CONSTRAINTS r "+
+		"ON r.CONSTRAINT_SCHEMA = k.CONSTRAINT_SCHEMA AND r.CONSTRAINT_NAME = k.CONSTRAINT_NAME "+
+		"WHERE k.TABLE_SCHEMA = "+s.schema()+" AND k.TABLE_NAME = ? AND k.REFERENCED_TABLE_NAME IS NOT NULL "+
+		"ORDER BY k.CONSTRAINT_NAME, k.ORDINAL_POSITION", s.Database, tableName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var foreignKeys []ForeignKey
+	for rows.Next() {
+		var fk ForeignKey
+		if err = rows.Scan(&fk.Name, &fk.Column, &fk.ReferencedTable, &fk.ReferencedColumn, &fk.OnUpdate, &fk.OnDelete); err != nil {
+			return nil, err
+		}
+		foreignKeys = append(foreignKeys, fk)
+	}
+	return foreignKeys, rows.Err()
+}
+
+// TableExists 判断表是否存在
+// @receiver s *SchemaInspector
+// @param tableName string
+// @return bool
+// @return error
+func (s *SchemaInspector) TableExists(tableName string) (bool, error) {
+	return s.exists("SELECT COUNT(*) FROM information_schema.TABLES WHERE TABLE_SCHEMA = "+s.schema()+" AND TABLE_NAME = ?", s.Database, tableName)
+}
+
+// ColumnExists 判断字段是否存在
+// @receiver s *SchemaInspector
+// @param tableName string
+// @param columnName string
+// @return bool
+// @return error
+func (s *SchemaInspector) ColumnExists(tableName, columnName string) (bool, error) {
+	return s.exists("SELECT COUNT(*) FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = "+s.schema()+" AND TABLE_NAME = ? AND COLUMN_NAME = ?", s.Database, tableName, columnName)
+}
+
+// IndexExists 判断索引是否存在
+// @receiver s *SchemaInspector
+// @param tableName string
+// @param indexName string
+// @return bool
+// @return error
+func (s *SchemaInspector) IndexExists(tableName, indexName string) (bool, error) {
+	return s.exists("SELECT COUNT(*) FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = "+s.schema()+" AND TABLE_NAME = ? AND INDEX_NAME = ?", s.Database, tableName, indexName)
+}
+
+// DiffModel 对比ORM模型与数据表结构的差异：字段是否存在、类型（含长度、精度）、是否允许为NULL、默认值及自增，
+// 类型及默认值按beego ORM的MySQL建表规则推导
+// @receiver s *SchemaInspector
+// @param model interface{}
+// @return *ModelDiff
+// @return error
+func (s *SchemaInspector) DiffModel(model interface{}) (*ModelDiff, error) {
+	meta, err := _getModelMeta(model)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &ModelDiff{Table: meta.Table}
+	exists, err := s.TableExists(meta.Table)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		diff.TableMissing = true
+		return diff, nil
+	}
+
+	columns, err := s.Columns(meta.Table)
+	if err != nil {
+		return nil, err
+	}
+	live := make(map[string]Column, len(columns))
+	for _, column := range columns {
+		live[strings.ToLower(column.Name)] = column
+	}
+
+	modelColumns := make(map[string]bool, len(meta.Fields))
+	for _, field := range meta.Fields {
+		modelColumns[strings.ToLower(field.Column)] = true
+		column, ok := live[strings.ToLower(field.Column)]
+		if !ok {
+			diff.MissingColumns = append(diff.MissingColumns, field.Column)
+			continue
+		}
+		if !field.Pk && field.Null != column.Nullable {
+			diff.Mismatched = append(diff.Mismatched, ColumnDiff{
+				Column: field.Column,
+				Field:  field.Name,
+				Reason: fmt.Sprintf("nullable: model %t, table %t", field.Null, column.Nullable),
+			})
+		}
+		if field.Auto && !strings.Contains(column.Extra, "auto_increment") {
+			diff.Mismatched = append(diff.Mismatched, ColumnDiff{Column: field.Column, Field: field.Name, Reason: "model is auto increment, table is not"})
+		}
+
+		columnType := field.ColumnType
+		if field.Rel {
+			if relMeta, err := _getModelMeta(reflect.Zero(field.RelType).Interface()); err == nil && relMeta.Pk != nil {
+				columnType = relMeta.Pk.ColumnType
+			}
+		}
+		if liveType := _normalizeColumnType(column.ColumnType); columnType != "" && columnType != liveType {
+			diff.Mismatched = append(diff.Mismatched, ColumnDiff{
+				Column: field.Column,
+				Field:  field.Name,
+				Reason: fmt.Sprintf("type: model %s, table %s", columnType, liveType),
+			})
+		}
+		if !field.Pk && !field.Rel && field.ColumnType != "" && !_sameDefault(field.Default, column.Default) {
+			diff.Mismatched = append(diff.Mismatched, ColumnDiff{
+				Column: field.Column,
+				Field:  field.Name,
+				Reason: fmt.Sprintf("default: model %s, table %s", _defaultString(field.Default), _defaultString(column.Default)),
+			})
+		}
+	}
+	for _, column := range columns {
+		if !modelColumns[strings.ToLower(column.Name)] {
+			diff.ExtraColumns = append(diff.ExtraColumns, column.Name)
+		}
+	}
+	sort.Strings(diff.ExtraColumns)
+
+	return diff, nil
+}
+
+// _normalizeColumnType 统一数据表字段类型的写法（去掉整数类型的显示宽度），如int(10) unsigned转为int unsigned
+// @param columnType string
+// @return string
+func _normalizeColumnType(columnType string) string {
+	columnType = strings.ToLower(strings.TrimSpace(columnType))
+	columnType = intDisplayWidthRegexp.ReplaceAllString(columnType, "$1")
+	columnType = strings.ReplaceAll(strings.ReplaceAll(columnType, " zerofill", ""), ", ", ",")
+	return columnType
+}
+
+// _sameDefault 模型与数据表的默认值是否一致，数值按数值比较（如0与0.00）
+// @param model *string
+// @param table *string
+// @return bool
+func _sameDefault(model, table *string) bool {
+	if table != nil && *table == "NULL" {
+		table = nil
+	}
+	if model == nil || table == nil {
+		return model == nil && table == nil
+	}
+
+	//MariaDB的字符串默认值带有单引号
+	a, b := *model, *table
+	if len(b) >= 2 && strings.HasPrefix(b, "'") && strings.HasSuffix(b, "'") {
+		b = b[1 : len(b)-1]
+	}
+	if x, err := strconv.ParseFloat(a, 64); err == nil {
+		if y, err := strconv.ParseFloat(b, 64); err == nil {
+			return x == y
+		}
+	}
+	return a == b
+}
+
+// _defaultString 默认值的展示文本
+// @param value *string
+// @return string
+func _defaultString(value *string) string {
+	if value == nil {
+		return "none"
+	}
+	return strconv.Quote(*value)
+}
+
+// schema 数据库名条件，未指定时使用当前连接的数据库
+// @receiver s *SchemaInspector
+// @return string
+func (s *SchemaInspector) schema() string {
+	return "IF(? = '', DATABASE(), ?)"
+}
+
+// query 执行查询，args的第一个参数为数据库名（对应schema条件中的两个占位符）
+// @receiver s *SchemaInspector
+// @param query string
+// @param args ...interface{}
+// @return *sql.Rows
+// @return error
+func (s *SchemaInspector) query(query string, args ...interface{}) (*sql.Rows, error) {
+	alias := s.Alias
+	if alias == "" {
+		alias = "default"
+	}
+	db, err := orm.GetDB(alias)
+	if err != nil {
+		return nil, err
+	}
+	args = append([]interface{}{args[0]}, args...)
+	return db.Query(query, args...)
+}
+
+// exists 执行COUNT查询并判断结果是否大于0
+// @receiver s *SchemaInspector
+// @param query string
+// @param args ...interface{}
+// @return bool
+// @return error
+func (s *SchemaInspector) exists(query string, args ...interface{}) (bool, error) {
+	rows, err := s.query(query, args...)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	var count int64
+	if rows.Next() {
+		if err = rows.Scan(&count); err != nil {
+			return false, err
+		}
+	}
+	return count > 0, rows.Err()
+}
+
+// IsExistColumn 判断默认数据库中表的字段是否存在
+// @param tableName string
+// @param columnName string
+// @param databaseName string
+// @return bool
+// @return error
+func IsExistColumn(tableName, columnName, databaseName string) (bool, error) {
+	return NewSchemaInspector("default", databaseName).ColumnExists(tableName, columnName)
+}
+
+// IsExistIndex 判断默认数据库中表的索引是否存在
+// @param tableName string
+// @param indexName string
+// @param databaseName string
+// @return bool
+// @return error
+func IsExistIndex(tableName, indexName, databaseName string) (bool, error) {
+	return NewSchemaInspector("default", databaseName).IndexExists(tableName, indexName)
+}
+
+// DiffModel 对比已注册ORM模型与默认数据库中数据表结构的差异
+// @param model interface{}
+// @return *ModelDiff
+// @return error
+func DiffModel(model interface{}) (*ModelDiff, error) {
+	return NewSchemaInspector("default", "").DiffModel(model)
+}
@@ -8,8 +8,11 @@ package database
 
 import (
 	"context"
+	"errors"
 	beego "github.com/beego/beego/v2/server/web"
 	"github.com/redis/go-redis/v9"
+	"net"
+	"os"
 	"strconv"
 	"strings"
 	"time"
@@ -27,6 +30,17 @@ func init() {
 	password, _ := beego.AppConfig.String("redis::password")
 	redisKey, _ = beego.AppConfig.String("redis::cache_key")
 
+	//关闭redis时不进行连接，操作均返回errRedisDisabled（单元测试中由databasetest替换客户端）
+	if _redisDisabled(redisHost) {
+		rdb = redis.NewClient(&redis.Options{
+			Dialer: func(context.Context, string, string) (net.Conn, error) {
+				return nil, errRedisDisabled
+			},
+			MaxRetries: -1,
+		})
+		return
+	}
+
 	rdb = redis.NewClient(&redis.Options{
 		Addr:     redisHost + ":" + port,
 		Password: password,    // no password set
@@ -39,6 +53,44 @@ func init() {
 	}
 }
 
+// errRedisDisabled 关闭redis时操作返回的错误
+var errRedisDisabled = errors.New("redis is disabled (redis::disabled or REDIS_DISABLED), replace the client with database.SetRedisClient or databasetest.NewRedis")
+
+// _redisDisabled 是否关闭redis连接：配置redis::disabled = true或环境变量REDIS_DISABLED=true时关闭；
+// 均未设置时，只有在go test中运行且未配置redis::address时关闭，其他情况与之前一致（未配置地址时连接本机）
+// @param redisHost string
+// @return bool
+func _redisDisabled(redisHost string) bool {
+	if value, ok := os.LookupEnv("REDIS_DISABLED"); ok {
+		disabled, _ := strconv.ParseBool(value)
+		return disabled
+	}
+	if disabled, err := beego.AppConfig.Bool("redis::disabled"); err == nil {
+		return disabled
+	}
+	return redisHost == "" && strings.HasSuffix(os.Args[0], ".test")
+}
+
+// SetRedisClient 替换redis操作使用的客户端及key前缀（主要用于单元测试）
+// @param client *redis.Client
+// @param keyPrefix string
+func SetRedisClient(client *redis.Client, keyPrefix string) {
+	rdb = client
+	redisKey = keyPrefix
+}
+
+// RedisClient 获取当前redis操作使用的客户端
+// @return *redis.Client
+func RedisClient() *redis.Client {
+	return rdb
+}
+
+// RedisKeyPrefix 获取当前redis操作使用的key前缀
+// @return string
+func RedisKeyPrefix() string {
+	return redisKey
+}
+
 // Del 删除一个指定key
 // @param key string
 // @return bool
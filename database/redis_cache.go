@@ -7,10 +7,12 @@
 package database
 
 import (
+	"context"
 	"fmt"
 	"github.com/beego/beego/v2/client/cache"
 	_ "github.com/beego/beego/v2/client/cache/redis"
 	beego "github.com/beego/beego/v2/server/web"
+	"time"
 )
 
 var RedisCache cache.Cache
@@ -22,6 +24,12 @@ func init() {
 	password, _ := beego.AppConfig.String("redis::password")
 	redisKey, _ := beego.AppConfig.String("redis::cache_key")
 
+	//关闭redis时不进行连接，操作均返回errRedisDisabled（单元测试中由databasetest替换缓存）
+	if _redisDisabled(redisHost) {
+		RedisCache = disabledCache{}
+		return
+	}
+
 	config := fmt.Sprintf(`{"key":"%s","conn":"%s","dbNum":"%s","password":"%s"}`, redisKey, redisHost+":"+port, dataBase, password)
 	var err error
 	RedisCache, err = cache.NewCache("redis", config)
@@ -29,5 +37,58 @@ func init() {
 		errMsg := "failed to init redis cache"
 		panic(errMsg)
 	}
+}
+
+// SetRedisCache 替换redis缓存实例（主要用于单元测试）
+// @param c cache.Cache
+func SetRedisCache(c cache.Cache) {
+	RedisCache = c
+}
+
+// disabledCache 关闭redis时使用的缓存，所有操作返回errRedisDisabled
+type disabledCache struct{}
+
+// Get 获取缓存
+func (disabledCache) Get(context.Context, string) (interface{}, error) {
+	return nil, errRedisDisabled
+}
+
+// GetMulti 批量获取缓存
+func (disabledCache) GetMulti(context.Context, []string) ([]interface{}, error) {
+	return nil, errRedisDisabled
+}
+
+// Put 设置缓存
+func (disabledCache) Put(context.Context, string, interface{}, time.Duration) error {
+	return errRedisDisabled
+}
+
+// Delete 删除缓存
+func (disabledCache) Delete(context.Context, string) error {
+	return errRedisDisabled
+}
+
+// Incr 计数加一
+func (disabledCache) Incr(context.Context, string) error {
+	return errRedisDisabled
+}
+
+// Decr 计数减一
+func (disabledCache) Decr(context.Context, string) error {
+	return errRedisDisabled
+}
+
+// IsExist 判断缓存是否存在
+func (disabledCache) IsExist(context.Context, string) (bool, error) {
+	return false, errRedisDisabled
+}
+
+// ClearAll 清空缓存
+func (disabledCache) ClearAll(context.Context) error {
+	return errRedisDisabled
+}
 
+// StartAndGC 启动缓存
+func (disabledCache) StartAndGC(string) error {
+	return nil
 }
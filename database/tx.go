@@ -0,0 +1,205 @@
+/**
+ * Created by goland.
+ * User: adam_wang
+ * Date: 2023-09-08 21:46:03
+ */
+
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/beego/beego/v2/client/orm"
+	"github.com/beego/beego/v2/core/logs"
+	"github.com/go-sql-driver/mysql"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+// TxMaxRetries 事务遇到死锁（1213）或锁等待超时（1205）时的最大重试次数
+var TxMaxRetries = 3
+
+// TxRetryBackoff 事务重试的初始等待时间，每次重试翻倍
+var TxRetryBackoff = 50 * time.Millisecond
+
+// txContextKey 上下文中保存事务的key，按数据库别名区分
+type txContextKey struct {
+	alias string
+}
+
+// Tx 由WithTx创建的事务，嵌套调用时使用SAVEPOINT
+type Tx struct {
+	orm.TxOrmer
+	ctx         context.Context
+	alias       string
+	depth       int
+	savepoints  int
+	afterCommit []func(ctx context.Context)
+	pipeline    redis.Pipeliner
+}
+
+// WithTx 在事务中执行fn：fn返回nil时提交，返回错误或panic时回滚；
+// ctx中已存在同一数据库别名的事务时（通过TxContext获取），使用SAVEPOINT执行嵌套事务；
+// 最外层事务遇到MySQL死锁（1213）或锁等待超时（1205）时按退避策略重试
+// @param ctx context.Context
+// @param alias string
+// @param fn func(tx orm.TxOrmer) error
+// @return error
+func WithTx(ctx context.Context, alias string, fn func(tx orm.TxOrmer) error) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if alias == "" {
+		alias = "default"
+	}
+	if parent, ok := ctx.Value(txContextKey{alias: alias}).(*Tx); ok {
+		return parent.nested(fn)
+	}
+
+	backoff := TxRetryBackoff
+	for attempt := 0; ; attempt++ {
+		err := _runTx(ctx, alias, fn)
+		if err == nil || attempt >= TxMaxRetries || !IsRetryableTxError(err) {
+			return err
+		}
+
+		logs.Warn("mysql transaction on `%s` retrying after error: %v", alias, err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// TxContext 获取携带事务的上下文，在fn中以此上下文调用WithTx即为嵌套事务
+// @param tx orm.TxOrmer
+// @return context.Context
+func TxContext(tx orm.TxOrmer) context.Context {
+	if t, ok := tx.(*Tx); ok {
+		return t.ctx
+	}
+	return context.Background()
+}
+
+// AfterCommit 注册在最外层事务提交成功后执行的函数，事务（或所在的SAVEPOINT）回滚时不会执行
+// @param tx orm.TxOrmer
+// @param fn func(ctx context.Context)
+// @return error
+func AfterCommit(tx orm.TxOrmer, fn func(ctx context.Context)) error {
+	t, ok := tx.(*Tx)
+	if !ok {
+		return errors.New("transaction is not created by WithTx")
+	}
+	t.afterCommit = append(t.afterCommit, fn)
+	return nil
+}
+
+// TxPipeline 获取与事务绑定的redis管道，其中的命令在最外层事务提交成功后才执行，回滚时丢弃；
+// 注意管道中的命令不会自动添加key前缀（可通过RedisKeyPrefix获取）
+// @param tx orm.TxOrmer
+// @return redis.Pipeliner
+// @return error
+func TxPipeline(tx orm.TxOrmer) (redis.Pipeliner, error) {
+	t, ok := tx.(*Tx)
+	if !ok {
+		return nil, errors.New("transaction is not created by WithTx")
+	}
+	if rdb == nil {
+		return nil, errors.New("redis is not initialized")
+	}
+	if t.pipeline == nil {
+		pipeline := rdb.TxPipeline()
+		t.pipeline = pipeline
+		t.afterCommit = append(t.afterCommit, func(ctx context.Context) {
+			if _, err := pipeline.Exec(ctx); err != nil {
+				logs.Error("failed to exec redis pipeline after commit: %v", err)
+			}
+		})
+	}
+	return t.pipeline, nil
+}
+
+// IsRetryableTxError 是否为可重试的事务错误（死锁1213、锁等待超时1205）
+// @param err error
+// @return bool
+func IsRetryableTxError(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == 1213 || mysqlErr.Number == 1205
+	}
+	return false
+}
+
+// _runTx 开启最外层事务并执行fn
+// @param ctx context.Context
+// @param alias string
+// @param fn func(tx orm.TxOrmer) error
+// @return err error
+func _runTx(ctx context.Context, alias string, fn func(tx orm.TxOrmer) error) (err error) {
+	txOrm, err := orm.NewOrmUsingDB(alias).BeginWithCtx(ctx)
+	if err != nil {
+		return err
+	}
+	t := &Tx{TxOrmer: txOrm, alias: alias}
+	t.ctx = context.WithValue(ctx, txContextKey{alias: alias}, t)
+
+	defer func() {
+		if r := recover(); r != nil {
+			_ = txOrm.Rollback()
+			panic(r)
+		}
+	}()
+
+	if err = fn(t); err != nil {
+		if rollbackErr := txOrm.Rollback(); rollbackErr != nil {
+			logs.Error("mysql transaction rollback on `%s` failed: %v", alias, rollbackErr)
+		}
+		return err
+	}
+	if err = txOrm.Commit(); err != nil {
+		return err
+	}
+
+	for _, callback := range t.afterCommit {
+		callback(ctx)
+	}
+	return nil
+}
+
+// nested 使用SAVEPOINT执行嵌套事务
+// @receiver t *Tx
+// @param fn func(tx orm.TxOrmer) error
+// @return err error
+func (t *Tx) nested(fn func(tx orm.TxOrmer) error) (err error) {
+	t.savepoints++
+	savepoint := fmt.Sprintf("sp_%d_%d", t.depth+1, t.savepoints)
+	if _, err = t.Raw("SAVEPOINT " + savepoint).Exec(); err != nil {
+		return err
+	}
+
+	child := &Tx{TxOrmer: t.TxOrmer, alias: t.alias, depth: t.depth + 1}
+	child.ctx = context.WithValue(t.ctx, txContextKey{alias: t.alias}, child)
+
+	defer func() {
+		if r := recover(); r != nil {
+			_, _ = t.Raw("ROLLBACK TO SAVEPOINT " + savepoint).Exec()
+			panic(r)
+		}
+	}()
+
+	if err = fn(child); err != nil {
+		if _, rollbackErr := t.Raw("ROLLBACK TO SAVEPOINT " + savepoint).Exec(); rollbackErr != nil {
+			logs.Error("mysql rollback to savepoint %s failed: %v", savepoint, rollbackErr)
+		}
+		return err
+	}
+	if _, err = t.Raw("RELEASE SAVEPOINT " + savepoint).Exec(); err != nil {
+		return err
+	}
+
+	t.afterCommit = append(t.afterCommit, child.afterCommit...)
+	return nil
+}
@@ -0,0 +1,199 @@
+/**
+ * Created by goland.
+ * User: adam_wang
+ * Date: 2023-08-20 10:12:36
+ */
+
+// Package databasetest 为database包提供单元测试辅助工具
+//
+// 使用进程内的redis替身（miniredis）替换database包中的redis客户端及redis缓存，
+// 无需启动真实的redis服务即可测试调用database.Set、database.HGetAll等方法的代码
+package databasetest
+
+import (
+	"fmt"
+	"github.com/adam-qiang/beego-tool/database"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/beego/beego/v2/client/cache"
+	_ "github.com/beego/beego/v2/client/cache/redis"
+	"github.com/redis/go-redis/v9"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Redis 进程内redis替身
+type Redis struct {
+	*miniredis.Miniredis
+	t      testing.TB
+	client *redis.Client
+	prefix string
+}
+
+// NewRedis 启动一个进程内redis并替换database包的redis客户端及缓存，测试结束后自动恢复
+// @param t testing.TB
+// @return *Redis
+func NewRedis(t testing.TB) *Redis {
+	return NewRedisWithPrefix(t, "")
+}
+
+// NewRedisWithPrefix 与NewRedis相同，同时指定redis操作的key前缀
+// @param t testing.TB
+// @param prefix string
+// @return *Redis
+func NewRedisWithPrefix(t testing.TB, prefix string) *Redis {
+	t.Helper()
+
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("databasetest: failed to start fake redis: %v", err)
+	}
+
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+
+	config := fmt.Sprintf(`{"key":"%s","conn":"%s","dbNum":"0"}`, prefix, mr.Addr())
+	redisCache, err := cache.NewCache("redis", config)
+	if err != nil {
+		mr.Close()
+		t.Fatalf("databasetest: failed to init fake redis cache: %v", err)
+	}
+
+	prevClient, prevPrefix := database.RedisClient(), database.RedisKeyPrefix()
+	prevCache := database.RedisCache
+	database.SetRedisClient(client, prefix)
+	database.SetRedisCache(redisCache)
+
+	t.Cleanup(func() {
+		database.SetRedisClient(prevClient, prevPrefix)
+		database.SetRedisCache(prevCache)
+		_ = client.Close()
+		mr.Close()
+	})
+
+	return &Redis{Miniredis: mr, t: t, client: client, prefix: prefix}
+}
+
+// Client 获取连接到替身的redis客户端
+// @receiver r *Redis
+// @return *redis.Client
+func (r *Redis) Client() *redis.Client {
+	return r.client
+}
+
+// Key 获取加上前缀后实际存储的key
+// @receiver r *Redis
+// @param key string
+// @return string
+func (r *Redis) Key(key string) string {
+	if r.prefix != "" {
+		return r.prefix + ":" + key
+	}
+	return key
+}
+
+// FastForward 时间快进，用于测试TTL/Expire等过期行为
+// @receiver r *Redis
+// @param d time.Duration
+func (r *Redis) FastForward(d time.Duration) {
+	r.Miniredis.FastForward(d)
+}
+
+// StoredKeys 获取所有已存储的key（去掉前缀，按字母排序）
+// @receiver r *Redis
+// @return []string
+func (r *Redis) StoredKeys() []string {
+	keys := r.Miniredis.Keys()
+	list := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if r.prefix != "" {
+			if !strings.HasPrefix(key, r.prefix+":") {
+				continue
+			}
+			key = strings.TrimPrefix(key, r.prefix+":")
+		}
+		list = append(list, key)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// AssertExists 断言key存在
+// @receiver r *Redis
+// @param key string
+func (r *Redis) AssertExists(key string) {
+	r.t.Helper()
+	if !r.Miniredis.Exists(r.Key(key)) {
+		r.t.Errorf("databasetest: expected key %q to exist", key)
+	}
+}
+
+// AssertNotExists 断言key不存在
+// @receiver r *Redis
+// @param key string
+func (r *Redis) AssertNotExists(key string) {
+	r.t.Helper()
+	if r.Miniredis.Exists(r.Key(key)) {
+		r.t.Errorf("databasetest: expected key %q not to exist", key)
+	}
+}
+
+// AssertGet 断言字符串类型key的值
+// @receiver r *Redis
+// @param key string
+// @param expected string
+func (r *Redis) AssertGet(key, expected string) {
+	r.t.Helper()
+	value, err := r.Miniredis.Get(r.Key(key))
+	if err != nil {
+		r.t.Errorf("databasetest: get key %q: %v", key, err)
+		return
+	}
+	if value != expected {
+		r.t.Errorf("databasetest: key %q: expected %q, got %q", key, expected, value)
+	}
+}
+
+// AssertHash 断言hash类型key的所有field和value
+// @receiver r *Redis
+// @param key string
+// @param expected map[string]string
+func (r *Redis) AssertHash(key string, expected map[string]string) {
+	r.t.Helper()
+	fields, err := r.Miniredis.HKeys(r.Key(key))
+	if err != nil {
+		r.t.Errorf("databasetest: hash key %q: %v", key, err)
+		return
+	}
+	actual := make(map[string]string, len(fields))
+	for _, field := range fields {
+		actual[field] = r.Miniredis.HGet(r.Key(key), field)
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		r.t.Errorf("databasetest: hash key %q: expected %v, got %v", key, expected, actual)
+	}
+}
+
+// AssertTTL 断言key的剩余生存时间
+// @receiver r *Redis
+// @param key string
+// @param expected time.Duration
+func (r *Redis) AssertTTL(key string, expected time.Duration) {
+	r.t.Helper()
+	if ttl := r.Miniredis.TTL(r.Key(key)); ttl != expected {
+		r.t.Errorf("databasetest: key %q: expected ttl %v, got %v", key, expected, ttl)
+	}
+}
+
+// AssertKeys 断言已存储的key集合（去掉前缀，与顺序无关）
+// @receiver r *Redis
+// @param expected ...string
+func (r *Redis) AssertKeys(expected ...string) {
+	r.t.Helper()
+	want := append([]string{}, expected...)
+	sort.Strings(want)
+	if got := r.StoredKeys(); !reflect.DeepEqual(got, want) && !(len(got) == 0 && len(want) == 0) {
+		r.t.Errorf("databasetest: expected keys %v, got %v", want, got)
+	}
+}
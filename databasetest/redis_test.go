@@ -0,0 +1,81 @@
+/**
+ * Created by goland.
+ * User: adam_wang
+ * Date: 2023-08-20 10:12:36
+ */
+
+package databasetest
+
+import (
+	"context"
+	"github.com/adam-qiang/beego-tool/database"
+	"testing"
+	"time"
+)
+
+func TestNewRedisReplacesClient(t *testing.T) {
+	tests := []struct {
+		name   string
+		prefix string
+	}{
+		{name: "no prefix"},
+		{name: "prefix", prefix: "app"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRedisWithPrefix(t, tt.prefix)
+			if database.RedisClient() != r.Client() {
+				t.Fatal("redis client was not replaced")
+			}
+
+			if !database.Set("token", "abc", 60) {
+				t.Fatal("Set failed")
+			}
+			r.AssertGet("token", "abc")
+			r.AssertTTL("token", 60*time.Second)
+			if got := database.Get("token"); got != "abc" {
+				t.Fatalf("Get: expected abc, got %q", got)
+			}
+
+			database.HSet("user", "name", "adam")
+			r.AssertHash("user", map[string]string{"name": "adam"})
+			r.AssertKeys("token", "user")
+
+			r.FastForward(61 * time.Second)
+			r.AssertNotExists("token")
+		})
+	}
+}
+
+func TestNewRedisReplacesCache(t *testing.T) {
+	r := NewRedis(t)
+	if err := database.RedisCache.Put(context.Background(), "k", "v", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	value, err := database.RedisCache.Get(context.Background(), "k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(value.([]byte)) != "v" {
+		t.Fatalf("expected v, got %v", value)
+	}
+	if len(r.StoredKeys()) != 1 {
+		t.Fatalf("expected 1 stored key, got %v", r.StoredKeys())
+	}
+}
+
+func TestNewRedisRestoresClient(t *testing.T) {
+	previous := database.RedisClient()
+	t.Run("replace", func(t *testing.T) {
+		NewRedis(t)
+		if database.RedisClient() == previous {
+			t.Fatal("redis client was not replaced")
+		}
+	})
+	if database.RedisClient() != previous {
+		t.Fatal("redis client was not restored")
+	}
+	if database.Set("token", "abc", 60) {
+		t.Fatal("expected Set to fail with redis disabled")
+	}
+}
@@ -0,0 +1,444 @@
+/**
+ * Created by goland.
+ * User: adam_wang
+ * Date: 2023-09-30 15:06:41
+ */
+
+package tool
+
+import (
+	"fmt"
+	"github.com/tealeg/xlsx/v3"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// ExcelMaxColumnWidth 自动列宽的最大宽度
+var ExcelMaxColumnWidth = 60.0
+
+// ExcelColumn excel列配置
+type ExcelColumn struct {
+	Title    string                         // 表头
+	Width    float64                        // 列宽，为0时按内容自动调整
+	Format   string                         // 数值或日期格式，如0.00、#,##0、yyyy-mm-dd
+	DropList []string                       // 下拉选项（数据验证）
+	Fill     func(value interface{}) string // 条件填充，按单元格的值返回ARGB背景色（如FFFFC7CE），返回空字符串时不填充
+	Sum      bool                           // 是否在汇总行中对该列求和
+}
+
+// ExcelBuilder excel构建器，支持多工作表、表头样式、列宽、格式、合并单元格、条件填充、超链接、下拉选项和汇总公式
+type ExcelBuilder struct {
+	file   *xlsx.File
+	sheets []*ExcelSheet
+	err    error
+}
+
+// ExcelSheet 工作表
+type ExcelSheet struct {
+	builder *ExcelBuilder
+	sheet   *xlsx.Sheet
+	columns []ExcelColumn
+	widths  []float64
+	rows    int
+	header  bool
+	fills   map[string]*xlsx.Style
+}
+
+// NewExcelBuilder 创建excel构建器
+// @return *ExcelBuilder
+func NewExcelBuilder() *ExcelBuilder {
+	return &ExcelBuilder{file: xlsx.NewFile()}
+}
+
+// Sheet 获取指定名称的工作表，不存在时创建
+// @receiver b *ExcelBuilder
+// @param name string
+// @return *ExcelSheet
+func (b *ExcelBuilder) Sheet(name string) *ExcelSheet {
+	for _, s := range b.sheets {
+		if s.sheet.Name == name {
+			return s
+		}
+	}
+
+	sheet, err := b.file.AddSheet(name)
+	if err != nil {
+		b._setError(err)
+		//名称不合法时使用默认名称，保证链式调用可以继续，错误在写入时返回
+		sheet, _ = b.file.AddSheet(fmt.Sprintf("Sheet%d", len(b.sheets)+1))
+	}
+	s := &ExcelSheet{builder: b, sheet: sheet, fills: make(map[string]*xlsx.Style)}
+	b.sheets = append(b.sheets, s)
+	return s
+}
+
+// File 获取底层的xlsx文件，用于构建器未覆盖的设置
+// @receiver b *ExcelBuilder
+// @return *xlsx.File
+func (b *ExcelBuilder) File() *xlsx.File {
+	return b.file
+}
+
+// Err 获取构建过程中的第一个错误
+// @receiver b *ExcelBuilder
+// @return error
+func (b *ExcelBuilder) Err() error {
+	return b.err
+}
+
+// Write 将excel写入io.Writer
+// @receiver b *ExcelBuilder
+// @param w io.Writer
+// @return error
+func (b *ExcelBuilder) Write(w io.Writer) error {
+	if err := b._finish(); err != nil {
+		return err
+	}
+	return b.file.Write(w)
+}
+
+// Export 将excel作为附件写入响应
+// @receiver b *ExcelBuilder
+// @param w http.ResponseWriter
+// @param r *http.Request
+// @param fileName string 不含扩展名的文件名
+// @return error
+func (b *ExcelBuilder) Export(w http.ResponseWriter, r *http.Request, fileName string) error {
+	if err := b._finish(); err != nil {
+		return err
+	}
+	return _writeExcel(w, r, b.file, fileName)
+}
+
+// Columns 设置列并写入表头（加粗、冻结首行），需在写入数据前调用
+// @receiver s *ExcelSheet
+// @param columns ...ExcelColumn
+// @return *ExcelSheet
+func (s *ExcelSheet) Columns(columns ...ExcelColumn) *ExcelSheet {
+	if s.rows > 0 {
+		s.builder._setError(fmt.Errorf("excel: sheet %s: columns must be set before rows", s.sheet.Name))
+		return s
+	}
+	s.columns = columns
+	s.header = true
+
+	style := xlsx.NewStyle()
+	style.Font.Bold = true
+	style.ApplyFont = true
+	style.Fill = *xlsx.NewFill(xlsx.Solid_Cell_Fill, "FFD9D9D9", "FFD9D9D9")
+	style.ApplyFill = true
+
+	row := s.sheet.AddRow()
+	for i, column := range columns {
+		cell := row.AddCell()
+		cell.SetString(column.Title)
+		cell.SetStyle(style)
+		s._fitWidth(i, column.Title)
+	}
+	s.rows++
+
+	s.sheet.SheetViews = []xlsx.SheetView{{Pane: &xlsx.Pane{
+		YSplit:      1,
+		TopLeftCell: "A2",
+		ActivePane:  "bottomLeft",
+		State:       "frozen",
+	}}}
+	return s
+}
+
+// AddRow 写入一行数据，支持字符串、数值、布尔值及time.Time
+// @receiver s *ExcelSheet
+// @param values ...interface{}
+// @return *ExcelSheet
+func (s *ExcelSheet) AddRow(values ...interface{}) *ExcelSheet {
+	row := s.sheet.AddRow()
+	for i, value := range values {
+		cell := row.AddCell()
+		var column ExcelColumn
+		if i < len(s.columns) {
+			column = s.columns[i]
+		}
+		_setExcelCell(cell, value, column.Format)
+		if column.Fill != nil {
+			if color := column.Fill(value); color != "" {
+				cell.SetStyle(s._fillStyle(color))
+			}
+		}
+		if t, ok := value.(time.Time); ok {
+			s._fitWidth(i, t.Format("2006-01-02 15:04:05"))
+		} else {
+			s._fitWidth(i, cell.Value)
+		}
+	}
+	s.rows++
+	return s
+}
+
+// AddRows 写入多行数据
+// @receiver s *ExcelSheet
+// @param rows [][]interface{}
+// @return *ExcelSheet
+func (s *ExcelSheet) AddRows(rows [][]interface{}) *ExcelSheet {
+	for _, row := range rows {
+		s.AddRow(row...)
+	}
+	return s
+}
+
+// Summary 写入汇总行，对Sum为true的列使用SUM公式求和，title写入第一个非求和列
+// @receiver s *ExcelSheet
+// @param title string
+// @return *ExcelSheet
+func (s *ExcelSheet) Summary(title string) *ExcelSheet {
+	first := 1
+	if s.header {
+		first = 2
+	}
+	last := s.rows
+
+	row := s.sheet.AddRow()
+	titled := false
+	for i, column := range s.columns {
+		cell := row.AddCell()
+		if column.Sum {
+			letter := xlsx.ColIndexToLetters(i)
+			cell.SetFormula(fmt.Sprintf("SUM(%s%d:%s%d)", letter, first, letter, last))
+			cell.NumFmt = column.Format
+			continue
+		}
+		if !titled {
+			cell.SetString(title)
+			titled = true
+		}
+	}
+	style := xlsx.NewStyle()
+	style.Font.Bold = true
+	style.ApplyFont = true
+	row.ForEachCell(func(c *xlsx.Cell) error {
+		c.SetStyle(style)
+		return nil
+	})
+	s.rows++
+	return s
+}
+
+// Merge 合并单元格，row、col为左上角单元格的下标（从0开始，含表头行）
+// @receiver s *ExcelSheet
+// @param row int
+// @param col int
+// @param hcells int 向右合并的单元格数
+// @param vcells int 向下合并的单元格数
+// @return *ExcelSheet
+func (s *ExcelSheet) Merge(row, col, hcells, vcells int) *ExcelSheet {
+	if cell := s._cell(row, col); cell != nil {
+		cell.Merge(hcells, vcells)
+	}
+	return s
+}
+
+// Hyperlink 设置单元格超链接
+// @receiver s *ExcelSheet
+// @param row int
+// @param col int
+// @param link string
+// @param text string 显示文本，为空时显示链接
+// @return *ExcelSheet
+func (s *ExcelSheet) Hyperlink(row, col int, link, text string) *ExcelSheet {
+	if cell := s._cell(row, col); cell != nil {
+		if text == "" {
+			text = link
+		}
+		cell.SetHyperlink(link, text, "")
+		s._fitWidth(col, text)
+	}
+	return s
+}
+
+// Formula 设置单元格公式，如SUM(B2:B10)
+// @receiver s *ExcelSheet
+// @param row int
+// @param col int
+// @param formula string
+// @return *ExcelSheet
+func (s *ExcelSheet) Formula(row, col int, formula string) *ExcelSheet {
+	if cell := s._cell(row, col); cell != nil {
+		cell.SetFormula(formula)
+	}
+	return s
+}
+
+// Cell 获取单元格，用于构建器未覆盖的设置
+// @receiver s *ExcelSheet
+// @param row int
+// @param col int
+// @return *xlsx.Cell
+// @return error
+func (s *ExcelSheet) Cell(row, col int) (*xlsx.Cell, error) {
+	return s.sheet.Cell(row, col)
+}
+
+// Rows 获取已写入的行数（含表头行）
+// @receiver s *ExcelSheet
+// @return int
+func (s *ExcelSheet) Rows() int {
+	return s.rows
+}
+
+// _cell 获取单元格，失败时记录错误
+// @receiver s *ExcelSheet
+// @param row int
+// @param col int
+// @return *xlsx.Cell
+func (s *ExcelSheet) _cell(row, col int) *xlsx.Cell {
+	cell, err := s.sheet.Cell(row, col)
+	if err != nil {
+		s.builder._setError(fmt.Errorf("excel: sheet %s cell (%d, %d): %w", s.sheet.Name, row, col, err))
+		return nil
+	}
+	return cell
+}
+
+// _fitWidth 按内容记录列的最大宽度（中文等宽字符按2个字符计算）
+// @receiver s *ExcelSheet
+// @param col int
+// @param value string
+func (s *ExcelSheet) _fitWidth(col int, value string) {
+	for len(s.widths) <= col {
+		s.widths = append(s.widths, 0)
+	}
+	width := 0.0
+	for _, r := range value {
+		if utf8.RuneLen(r) > 1 {
+			width += 2
+		} else {
+			width++
+		}
+	}
+	width = width*1.1 + 2
+	if width > ExcelMaxColumnWidth {
+		width = ExcelMaxColumnWidth
+	}
+	if width > s.widths[col] {
+		s.widths[col] = width
+	}
+}
+
+// _fillStyle 获取指定背景色的样式（同一颜色复用样式）
+// @receiver s *ExcelSheet
+// @param color string
+// @return *xlsx.Style
+func (s *ExcelSheet) _fillStyle(color string) *xlsx.Style {
+	if style, ok := s.fills[color]; ok {
+		return style
+	}
+	style := xlsx.NewStyle()
+	style.Fill = *xlsx.NewFill(xlsx.Solid_Cell_Fill, color, color)
+	style.ApplyFill = true
+	s.fills[color] = style
+	return style
+}
+
+// _finish 写入前设置列宽及下拉选项
+// @receiver s *ExcelSheet
+// @return error
+func (s *ExcelSheet) _finish() error {
+	for i := range s.widths {
+		width := s.widths[i]
+		if i < len(s.columns) && s.columns[i].Width > 0 {
+			width = s.columns[i].Width
+		}
+		if width > 0 {
+			s.sheet.SetColWidth(i+1, i+1, width)
+		}
+	}
+	for i := len(s.widths); i < len(s.columns); i++ {
+		if s.columns[i].Width > 0 {
+			s.sheet.SetColWidth(i+1, i+1, s.columns[i].Width)
+		}
+	}
+
+	first := 0
+	if s.header {
+		first = 1
+	}
+	for i, column := range s.columns {
+		if len(column.DropList) == 0 {
+			continue
+		}
+		//下拉选项作用于整列（表头除外），新增的行同样生效
+		dv := xlsx.NewDataValidation(first, i, xlsx.Excel2006MaxRowIndex, i, true)
+		if err := dv.SetDropList(column.DropList); err != nil {
+			return fmt.Errorf("excel: sheet %s column %s: %w", s.sheet.Name, column.Title, err)
+		}
+		s.sheet.AddDataValidation(dv)
+	}
+	return nil
+}
+
+// _finish 完成所有工作表的设置
+// @receiver b *ExcelBuilder
+// @return error
+func (b *ExcelBuilder) _finish() error {
+	if b.err != nil {
+		return b.err
+	}
+	if len(b.sheets) == 0 {
+		b.Sheet("Sheet1")
+	}
+	for _, s := range b.sheets {
+		if err := s._finish(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// _setError 记录第一个错误
+// @receiver b *ExcelBuilder
+// @param err error
+func (b *ExcelBuilder) _setError(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+// _setExcelCell 按值的类型设置单元格，format为数值或日期格式
+// @param cell *xlsx.Cell
+// @param value interface{}
+// @param format string
+func _setExcelCell(cell *xlsx.Cell, value interface{}, format string) {
+	switch v := value.(type) {
+	case time.Time:
+		if v.IsZero() {
+			cell.SetString("")
+			return
+		}
+		if format == "" {
+			format = "yyyy-mm-dd hh:mm:ss"
+		}
+		cell.SetDateWithOptions(v, xlsx.DateTimeOptions{Location: time.Local, ExcelTimeFormat: format})
+		return
+	case *time.Time:
+		if v == nil {
+			cell.SetString("")
+			return
+		}
+		_setExcelCell(cell, *v, format)
+		return
+	case bool:
+		cell.SetBool(v)
+		return
+	case uint, uint8, uint16, uint32, uint64:
+		cell.SetNumeric(fmt.Sprintf("%d", v))
+	case float64:
+		cell.SetNumeric(strconv.FormatFloat(v, 'f', -1, 64))
+	default:
+		cell.SetValue(v)
+	}
+	if format != "" && cell.Type() == xlsx.CellTypeNumeric {
+		cell.NumFmt = format
+	}
+}
@@ -0,0 +1,405 @@
+/**
+ * Created by goland.
+ * User: adam_wang
+ * Date: 2023-10-03 10:27:35
+ */
+
+package tool
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/tealeg/xlsx/v3"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExcelStreamOptions 流式excel写入配置
+type ExcelStreamOptions struct {
+	SheetName   string   // 工作表名称，默认为Sheet，超出行数拆分时依次为Sheet1、Sheet2……
+	Header      []string // 表头，拆分出的每个工作表都会写入表头并冻结
+	MaxRows     int      // 每个工作表的最大行数（含表头），默认为excel上限1048576
+	FlushEvery  int      // 每写入多少行刷新一次输出，默认为1000
+	UseTempFile bool     // 先写入临时文件再输出，出错时可以返回错误响应，并支持Content-Length及断点续传
+	TempDir     string   // 临时文件目录，默认为系统临时目录
+}
+
+// ExcelStreamWriter 流式excel写入器，逐行写入zip输出，内存占用与数据量无关
+type ExcelStreamWriter struct {
+	zip       *zip.Writer
+	out       io.Writer
+	buf       *bufio.Writer
+	opts      ExcelStreamOptions
+	sheets    []string
+	sheetRows int
+	rows      int
+	closed    bool
+	err       error
+}
+
+// NewExcelStreamWriter 创建流式excel写入器，写入完成后需调用Close
+// @param w io.Writer
+// @param opts ExcelStreamOptions
+// @return *ExcelStreamWriter
+func NewExcelStreamWriter(w io.Writer, opts ExcelStreamOptions) *ExcelStreamWriter {
+	if opts.SheetName == "" {
+		opts.SheetName = "Sheet"
+	}
+	if opts.MaxRows <= 0 || opts.MaxRows > xlsx.Excel2006MaxRowCount {
+		opts.MaxRows = xlsx.Excel2006MaxRowCount
+	}
+	if len(opts.Header) > 0 && opts.MaxRows < 2 {
+		opts.MaxRows = 2
+	}
+	if opts.FlushEvery <= 0 {
+		opts.FlushEvery = 1000
+	}
+	return &ExcelStreamWriter{zip: zip.NewWriter(w), out: w, opts: opts}
+}
+
+// WriteRow 写入一行数据，当前工作表写满时自动创建新的工作表
+// @receiver s *ExcelStreamWriter
+// @param values ...interface{} 支持字符串、数值、布尔值及time.Time
+// @return error
+func (s *ExcelStreamWriter) WriteRow(values ...interface{}) error {
+	if s.err != nil {
+		return s.err
+	}
+	if s.closed {
+		return errors.New("excel: write to closed stream writer")
+	}
+	if s.buf == nil || s.sheetRows >= s.opts.MaxRows {
+		if err := s._nextSheet(); err != nil {
+			return s._fail(err)
+		}
+	}
+	if err := s._writeRow(values); err != nil {
+		return s._fail(err)
+	}
+	s.rows++
+
+	if s.rows%s.opts.FlushEvery == 0 {
+		if err := s._flush(); err != nil {
+			return s._fail(err)
+		}
+	}
+	return nil
+}
+
+// WriteStrings 写入一行字符串数据
+// @receiver s *ExcelStreamWriter
+// @param row []string
+// @return error
+func (s *ExcelStreamWriter) WriteStrings(row []string) error {
+	values := make([]interface{}, len(row))
+	for i, value := range row {
+		values[i] = value
+	}
+	return s.WriteRow(values...)
+}
+
+// WriteAll 从迭代器中读取并写入所有行（不会关闭写入器）
+// @receiver s *ExcelStreamWriter
+// @param rows RowIterator
+// @return error
+func (s *ExcelStreamWriter) WriteAll(rows RowIterator) error {
+	for {
+		row, err := rows.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return s._fail(err)
+		}
+		if err = s.WriteStrings(row); err != nil {
+			return err
+		}
+	}
+}
+
+// Rows 获取已写入的数据行数（不含表头）
+// @receiver s *ExcelStreamWriter
+// @return int
+func (s *ExcelStreamWriter) Rows() int {
+	return s.rows
+}
+
+// Sheets 获取工作表数量
+// @receiver s *ExcelStreamWriter
+// @return int
+func (s *ExcelStreamWriter) Sheets() int {
+	return len(s.sheets)
+}
+
+// Close 结束当前工作表并写入工作簿信息
+// @receiver s *ExcelStreamWriter
+// @return error
+func (s *ExcelStreamWriter) Close() error {
+	if s.closed {
+		return s.err
+	}
+	s.closed = true
+	if s.err != nil {
+		return s.err
+	}
+	if s.buf == nil {
+		//没有数据时也生成一个工作表
+		if err := s._nextSheet(); err != nil {
+			return s._fail(err)
+		}
+	}
+	if err := s._closeSheet(); err != nil {
+		return s._fail(err)
+	}
+	if err := s._writeWorkbook(); err != nil {
+		return s._fail(err)
+	}
+	if err := s.zip.Close(); err != nil {
+		return s._fail(err)
+	}
+	return nil
+}
+
+// ExportExcelStream 流式导出excel，数据逐行写入响应（或临时文件），超出行数上限时自动拆分工作表
+// 直接写入响应时，响应头发送后发生的错误只能中断下载；需要在出错时返回错误响应请开启UseTempFile
+// @param w http.ResponseWriter
+// @param r *http.Request
+// @param fileName string 不含扩展名的文件名
+// @param rows RowIterator
+// @param opts ExcelStreamOptions
+// @return error
+func ExportExcelStream(w http.ResponseWriter, r *http.Request, fileName string, rows RowIterator, opts ExcelStreamOptions) error {
+	fileName = fmt.Sprintf("%s.xlsx", fileName)
+	if !opts.UseTempFile {
+		w.Header().Set("Content-Disposition", ContentDisposition(fileName))
+		w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		writer := NewExcelStreamWriter(w, opts)
+		if err := writer.WriteAll(rows); err != nil {
+			return err
+		}
+		return writer.Close()
+	}
+
+	file, err := os.CreateTemp(opts.TempDir, "export-*.xlsx")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+	}()
+
+	buf := bufio.NewWriter(file)
+	writer := NewExcelStreamWriter(buf, opts)
+	if err = writer.WriteAll(rows); err != nil {
+		return err
+	}
+	if err = writer.Close(); err != nil {
+		return err
+	}
+	if err = buf.Flush(); err != nil {
+		return err
+	}
+	if _, err = file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Disposition", ContentDisposition(fileName))
+	w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	http.ServeContent(w, r, fileName, time.Now(), file)
+	return nil
+}
+
+// _fail 记录错误，之后的写入直接返回该错误
+// @receiver s *ExcelStreamWriter
+// @param err error
+// @return error
+func (s *ExcelStreamWriter) _fail(err error) error {
+	if s.err == nil {
+		s.err = err
+	}
+	return s.err
+}
+
+// _nextSheet 结束当前工作表并创建新的工作表
+// @receiver s *ExcelStreamWriter
+// @return error
+func (s *ExcelStreamWriter) _nextSheet() error {
+	if s.buf != nil {
+		if err := s._closeSheet(); err != nil {
+			return err
+		}
+	}
+
+	name := s.opts.SheetName
+	if s.opts.SheetName == "Sheet" || len(s.sheets) > 0 {
+		name = fmt.Sprintf("%s%d", s.opts.SheetName, len(s.sheets)+1)
+	}
+	s.sheets = append(s.sheets, name)
+	entry, err := s.zip.Create(fmt.Sprintf("xl/worksheets/sheet%d.xml", len(s.sheets)))
+	if err != nil {
+		return err
+	}
+	s.buf = bufio.NewWriter(entry)
+	s.sheetRows = 0
+
+	_, _ = s.buf.WriteString(xml.Header + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	if len(s.opts.Header) > 0 {
+		_, _ = s.buf.WriteString(`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>`)
+	}
+	_, _ = s.buf.WriteString(`<sheetData>`)
+
+	if len(s.opts.Header) > 0 {
+		values := make([]interface{}, len(s.opts.Header))
+		for i, title := range s.opts.Header {
+			values[i] = title
+		}
+		if err = s._writeRow(values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// _closeSheet 结束当前工作表
+// @receiver s *ExcelStreamWriter
+// @return error
+func (s *ExcelStreamWriter) _closeSheet() error {
+	if _, err := s.buf.WriteString(`</sheetData></worksheet>`); err != nil {
+		return err
+	}
+	return s.buf.Flush()
+}
+
+// _writeRow 写入一行的XML
+// @receiver s *ExcelStreamWriter
+// @param values []interface{}
+// @return error
+func (s *ExcelStreamWriter) _writeRow(values []interface{}) error {
+	s.sheetRows++
+	row := strconv.Itoa(s.sheetRows)
+	_, _ = s.buf.WriteString(`<row r="` + row + `">`)
+	for i, value := range values {
+		ref := xlsx.ColIndexToLetters(i) + row
+		if t, ok := value.(*time.Time); ok {
+			if t == nil {
+				value = nil
+			} else {
+				value = *t
+			}
+		}
+		switch v := value.(type) {
+		case nil:
+			continue
+		case bool:
+			b := "0"
+			if v {
+				b = "1"
+			}
+			_, _ = s.buf.WriteString(`<c r="` + ref + `" t="b"><v>` + b + `</v></c>`)
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+			_, _ = s.buf.WriteString(`<c r="` + ref + `"><v>` + fmt.Sprint(v) + `</v></c>`)
+		case float32:
+			_, _ = s.buf.WriteString(`<c r="` + ref + `"><v>` + strconv.FormatFloat(float64(v), 'f', -1, 32) + `</v></c>`)
+		case float64:
+			_, _ = s.buf.WriteString(`<c r="` + ref + `"><v>` + strconv.FormatFloat(v, 'f', -1, 64) + `</v></c>`)
+		case time.Time:
+			if !v.IsZero() {
+				s._writeString(ref, v.Format("2006-01-02 15:04:05"))
+			}
+		case string:
+			s._writeString(ref, v)
+		default:
+			s._writeString(ref, fmt.Sprint(v))
+		}
+	}
+	_, err := s.buf.WriteString(`</row>`)
+	return err
+}
+
+// _writeString 写入内联字符串单元格
+// @receiver s *ExcelStreamWriter
+// @param ref string
+// @param value string
+func (s *ExcelStreamWriter) _writeString(ref, value string) {
+	_, _ = s.buf.WriteString(`<c r="` + ref + `" t="inlineStr"><is><t xml:space="preserve">`)
+	_ = xml.EscapeText(s.buf, []byte(value))
+	_, _ = s.buf.WriteString(`</t></is></c>`)
+}
+
+// _flush 将已压缩的数据刷新到输出，输出支持http.Flusher时同时推送到客户端
+// @receiver s *ExcelStreamWriter
+// @return error
+func (s *ExcelStreamWriter) _flush() error {
+	if err := s.buf.Flush(); err != nil {
+		return err
+	}
+	if err := s.zip.Flush(); err != nil {
+		return err
+	}
+	if flusher, ok := s.out.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return nil
+}
+
+// _writeWorkbook 写入工作簿、关系及内容类型等文件
+// @receiver s *ExcelStreamWriter
+// @return error
+func (s *ExcelStreamWriter) _writeWorkbook() error {
+	var types, sheets, rels strings.Builder
+	for i, name := range s.sheets {
+		n := strconv.Itoa(i + 1)
+		types.WriteString(`<Override PartName="/xl/worksheets/sheet` + n + `.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`)
+		sheets.WriteString(`<sheet name="`)
+		_ = xml.EscapeText(&sheets, []byte(name))
+		sheets.WriteString(`" sheetId="` + n + `" r:id="rId` + n + `"/>`)
+		rels.WriteString(`<Relationship Id="rId` + n + `" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet` + n + `.xml"/>`)
+	}
+	stylesID := strconv.Itoa(len(s.sheets) + 1)
+
+	files := []struct {
+		name    string
+		content string
+	}{
+		{"[Content_Types].xml", `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+			types.String() + `</Types>`},
+		{"_rels/.rels", `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`},
+		{"xl/workbook.xml", `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets>` + sheets.String() + `</sheets></workbook>`},
+		{"xl/_rels/workbook.xml.rels", `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			rels.String() +
+			`<Relationship Id="rId` + stylesID + `" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+			`</Relationships>`},
+		{"xl/styles.xml", `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+			`<fonts count="1"><font><sz val="11"/><name val="Calibri"/></font></fonts>` +
+			`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+			`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+			`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+			`<cellXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/></cellXfs>` +
+			`</styleSheet>`},
+	}
+	for _, f := range files {
+		entry, err := s.zip.Create(f.name)
+		if err != nil {
+			return err
+		}
+		if _, err = io.WriteString(entry, xml.Header+f.content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,399 @@
+/**
+ * Created by goland.
+ * User: adam_wang
+ * Date: 2023-10-16 14:08:51
+ */
+
+package tool
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/tealeg/xlsx/v3"
+	"net/http"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+var (
+	// _excelRangeTag 列表行标记，如{{range .Items}}
+	_excelRangeTag = regexp.MustCompile(`^\s*\{\{-?\s*range\s+(.+?)\s*-?\}\}`)
+	// _excelSingleTag 只包含一个占位符的单元格，如{{.Total}}
+	_excelSingleTag = regexp.MustCompile(`^\s*\{\{-?\s*([^{}]+?)\s*-?\}\}\s*$`)
+	// _excelCellRef 公式中的单元格引用，如A1、$B$2
+	_excelCellRef = regexp.MustCompile(`\$?[A-Za-z]{1,3}(\$?)([0-9]+)`)
+)
+
+// ExcelTemplate excel模板，单元格中使用text/template语法的占位符，如{{.Total}}；
+// 单元格以{{range .Items}}开头的行为列表行，按列表元素逐行展开（行内的占位符以列表元素为数据，如{{.Name}}），
+// 展开的行保留模板行的样式、行高及公式，其下方公式中的引用随之下移，引用模板行的区域（如SUM(C5:C5)）扩展到全部展开的行
+type ExcelTemplate struct {
+	Funcs template.FuncMap // 占位符中可使用的函数
+	data  []byte
+}
+
+// NewExcelTemplate 由模板文件内容（如embed嵌入的文件）创建模板
+// @param data []byte
+// @return *ExcelTemplate
+// @return error
+func NewExcelTemplate(data []byte) (*ExcelTemplate, error) {
+	if _, err := xlsx.OpenBinary(data); err != nil {
+		return nil, fmt.Errorf("excel: invalid template: %w", err)
+	}
+	return &ExcelTemplate{data: data}, nil
+}
+
+// OpenExcelTemplate 读取磁盘上的模板文件
+// @param path string
+// @return *ExcelTemplate
+// @return error
+func OpenExcelTemplate(path string) (*ExcelTemplate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewExcelTemplate(data)
+}
+
+// Fill 使用数据填充模板，每次填充使用模板的副本，可并发调用
+// @receiver t *ExcelTemplate
+// @param data interface{} 结构体或map
+// @return *xlsx.File
+// @return error
+func (t *ExcelTemplate) Fill(data interface{}) (*xlsx.File, error) {
+	file, err := xlsx.OpenBinary(t.data)
+	if err != nil {
+		return nil, err
+	}
+	filler := &excelFiller{funcs: t.Funcs, cache: make(map[string]*template.Template)}
+	for _, sheet := range file.Sheets {
+		if err = filler.sheet(sheet, data); err != nil {
+			return nil, err
+		}
+	}
+	return file, nil
+}
+
+// Export 填充模板并作为附件写入响应
+// @receiver t *ExcelTemplate
+// @param w http.ResponseWriter
+// @param r *http.Request
+// @param data interface{}
+// @param fileName string 不含扩展名的文件名
+// @return error
+func (t *ExcelTemplate) Export(w http.ResponseWriter, r *http.Request, data interface{}, fileName string) error {
+	file, err := t.Fill(data)
+	if err != nil {
+		return err
+	}
+	return _writeExcel(w, r, file, fileName)
+}
+
+// excelFiller 模板填充
+type excelFiller struct {
+	funcs template.FuncMap
+	cache map[string]*template.Template
+}
+
+// sheet 填充工作表
+// @receiver f *excelFiller
+// @param sheet *xlsx.Sheet
+// @param data interface{}
+// @return error
+func (f *excelFiller) sheet(sheet *xlsx.Sheet, data interface{}) error {
+	for index := 0; index < sheet.MaxRow; index++ {
+		row, err := sheet.Row(index)
+		if err != nil {
+			return err
+		}
+
+		pipeline := ""
+		err = row.ForEachCell(func(c *xlsx.Cell) error {
+			if m := _excelRangeTag.FindStringSubmatch(c.Value); m != nil && c.Formula() == "" {
+				pipeline = m[1]
+				c.Value = c.Value[len(m[0]):]
+			}
+			return nil
+		}, xlsx.SkipEmptyCells)
+		if err != nil {
+			return err
+		}
+
+		if pipeline == "" {
+			if err = f.row(sheet, row, data); err != nil {
+				return err
+			}
+			continue
+		}
+		n, err := f.expand(sheet, index, pipeline, data)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			index += n - 1
+		}
+	}
+	return nil
+}
+
+// expand 展开列表行
+// @receiver f *excelFiller
+// @param sheet *xlsx.Sheet
+// @param index int 模板行
+// @param pipeline string 列表表达式，如.Items
+// @param data interface{}
+// @return int 展开的行数
+// @return error
+func (f *excelFiller) expand(sheet *xlsx.Sheet, index int, pipeline string, data interface{}) (int, error) {
+	value, err := f.value(sheet.Name+"!range", "{{"+pipeline+"}}", data)
+	if err != nil {
+		return 0, err
+	}
+	list := reflect.ValueOf(value)
+	for list.Kind() == reflect.Ptr {
+		list = list.Elem()
+	}
+	if list.IsValid() && list.Kind() != reflect.Slice && list.Kind() != reflect.Array {
+		return 0, fmt.Errorf("excel: template %s: range %s is not a slice", sheet.Name, pipeline)
+	}
+	n := 0
+	if list.IsValid() {
+		n = list.Len()
+	}
+
+	tpl, err := sheet.Row(index)
+	if err != nil {
+		return 0, err
+	}
+	var cells []*xlsx.Cell
+	err = tpl.ForEachCell(func(c *xlsx.Cell) error {
+		cells = append(cells, c)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		//没有数据时清空模板行
+		for _, c := range cells {
+			c.SetString("")
+		}
+		return 0, nil
+	}
+
+	//插入行前调整所有公式中的引用
+	if n > 1 {
+		err = sheet.ForEachRow(func(r *xlsx.Row) error {
+			return r.ForEachCell(func(c *xlsx.Cell) error {
+				if c.Formula() != "" {
+					c.SetFormula(_shiftFormula(c.Formula(), index+1, n-1, r.GetCoordinate() != index))
+				}
+				return nil
+			}, xlsx.SkipEmptyCells)
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	type cellTemplate struct {
+		value, formula, numFmt string
+		style                  *xlsx.Style
+		hMerge, vMerge         int
+	}
+	templates := make([]cellTemplate, len(cells))
+	for i, c := range cells {
+		templates[i] = cellTemplate{c.Value, c.Formula(), c.NumFmt, c.GetStyle(), c.HMerge, c.VMerge}
+	}
+	height := tpl.GetHeight()
+
+	for i := 0; i < n; i++ {
+		row := tpl
+		if i > 0 {
+			if row, err = sheet.AddRowAtIndex(index + i); err != nil {
+				return 0, err
+			}
+			if height > 0 {
+				row.SetHeight(height)
+			}
+		}
+		item := list.Index(i).Interface()
+		for col, ct := range templates {
+			c := row.GetCell(col)
+			c.SetStyle(ct.style)
+			c.NumFmt = ct.numFmt
+			c.HMerge, c.VMerge = ct.hMerge, ct.vMerge
+			if ct.formula != "" {
+				c.SetFormula(_shiftFormula(ct.formula, 0, i, false))
+				continue
+			}
+			if err = f.cell(c, ct.value, item); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// row 填充行中的占位符
+// @receiver f *excelFiller
+// @param sheet *xlsx.Sheet
+// @param row *xlsx.Row
+// @param data interface{}
+// @return error
+func (f *excelFiller) row(sheet *xlsx.Sheet, row *xlsx.Row, data interface{}) error {
+	return row.ForEachCell(func(c *xlsx.Cell) error {
+		if c.Formula() != "" || !strings.Contains(c.Value, "{{") {
+			return nil
+		}
+		return f.cell(c, c.Value, data)
+	}, xlsx.SkipEmptyCells)
+}
+
+// cell 填充单元格；只包含一个占位符时按值的类型写入（数字、日期等），否则写入字符串
+// @receiver f *excelFiller
+// @param c *xlsx.Cell
+// @param text string
+// @param data interface{}
+// @return error
+func (f *excelFiller) cell(c *xlsx.Cell, text string, data interface{}) error {
+	x, y := c.GetCoordinates()
+	name := c.Row.Sheet.Name + "!" + xlsx.GetCellIDStringFromCoords(x, y)
+	if !strings.Contains(text, "{{") {
+		c.SetString(text)
+		return nil
+	}
+
+	numFmt := c.NumFmt
+	if strings.EqualFold(numFmt, "general") {
+		numFmt = ""
+	}
+	if _excelSingleTag.MatchString(text) {
+		value, err := f.value(name, text, data)
+		if err != nil {
+			return err
+		}
+		_setExcelCell(c, value, numFmt)
+		return nil
+	}
+
+	tpl, err := f.parse(name, text)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err = tpl.Execute(&buf, data); err != nil {
+		return fmt.Errorf("excel: template %s: %w", name, err)
+	}
+	c.SetString(buf.String())
+	c.NumFmt = numFmt
+	return nil
+}
+
+// value 计算只包含一个占位符的表达式的值（保留原始类型）
+// @receiver f *excelFiller
+// @param name string
+// @param text string
+// @param data interface{}
+// @return interface{}
+// @return error
+func (f *excelFiller) value(name, text string, data interface{}) (interface{}, error) {
+	m := _excelSingleTag.FindStringSubmatch(text)
+	tpl, err := f.parse(name, "{{_value ("+m[1]+")}}")
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	tpl = tpl.Funcs(template.FuncMap{"_value": func(v interface{}) string {
+		value = v
+		return ""
+	}})
+	if err = tpl.Execute(&bytes.Buffer{}, data); err != nil {
+		return nil, fmt.Errorf("excel: template %s: %w", name, err)
+	}
+	return value, nil
+}
+
+// parse 解析模板（按内容缓存）
+// @receiver f *excelFiller
+// @param name string
+// @param text string
+// @return *template.Template
+// @return error
+func (f *excelFiller) parse(name, text string) (*template.Template, error) {
+	if tpl, ok := f.cache[text]; ok {
+		return tpl, nil
+	}
+	tpl, err := template.New(name).Funcs(template.FuncMap{"_value": func(v interface{}) string { return "" }}).
+		Funcs(f.funcs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("excel: template %s: %w", name, err)
+	}
+	f.cache[text] = tpl
+	return tpl, nil
+}
+
+// _shiftFormula 调整公式中的行引用（不处理字符串常量及其他工作表的引用）
+// from > 0 时为在第from行（从0开始）之后插入n行：之后的行引用下移n行，extend为true时结束于第from行的区域扩展到插入的行；
+// from为0时为将公式复制到下方第n行：相对行引用下移n行
+// @param formula string
+// @param from int
+// @param n int
+// @param extend bool
+// @return string
+func _shiftFormula(formula string, from, n int, extend bool) string {
+	var out strings.Builder
+	quoted := false
+	last := 0
+	for i := 0; i < len(formula); i++ {
+		if formula[i] == '"' {
+			quoted = !quoted
+		}
+		if quoted {
+			continue
+		}
+		loc := _excelCellRef.FindStringSubmatchIndex(formula[i:])
+		if loc == nil || loc[0] != 0 {
+			continue
+		}
+		start, end := i, i+loc[1]
+		//排除函数名（如LOG10(）、名称及其他工作表的引用
+		if start > 0 && strings.ContainsRune("!_.'", rune(formula[start-1])) || start > 0 && _isWordByte(formula[start-1]) ||
+			end < len(formula) && (formula[end] == '(' || formula[end] == '!' || _isWordByte(formula[end])) {
+			for i < len(formula)-1 && _isWordByte(formula[i+1]) {
+				i++
+			}
+			continue
+		}
+
+		row, _ := strconv.Atoi(formula[i+loc[4] : i+loc[5]])
+		absolute := loc[3] > loc[2]
+		switch {
+		case from == 0:
+			if !absolute {
+				row += n
+			}
+		case row > from:
+			row += n
+		case row == from && extend && start > 0 && formula[start-1] == ':':
+			row += n
+		}
+		out.WriteString(formula[last : i+loc[4]])
+		out.WriteString(strconv.Itoa(row))
+		last = end
+		i = end - 1
+	}
+	out.WriteString(formula[last:])
+	return out.String()
+}
+
+// _isWordByte 是否为字母、数字或下划线
+// @param b byte
+// @return bool
+func _isWordByte(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9' || b == '_'
+}
@@ -0,0 +1,42 @@
+/**
+ * Created by goland.
+ * User: adam_wang
+ * Date: 2023-10-16 14:08:51
+ */
+
+package tool
+
+import "testing"
+
+func TestShiftFormula(t *testing.T) {
+	tests := []struct {
+		name    string
+		formula string
+		from    int
+		n       int
+		extend  bool
+		want    string
+	}{
+		{"row after insert", "B4*2", 3, 2, false, "B6*2"},
+		{"row before insert", "A1+A2", 3, 2, false, "A1+A2"},
+		{"template row", "B3", 3, 2, true, "B3"},
+		{"absolute row after insert", "$B$4", 3, 2, false, "$B$6"},
+		{"lowercase", "b4+c2", 3, 2, false, "b6+c2"},
+		{"range end extended", "SUM(B2:B3)", 3, 2, true, "SUM(B2:B5)"},
+		{"range end not extended", "SUM(B2:B3)", 3, 2, false, "SUM(B2:B3)"},
+		{"range after insert", "SUM(B4:B10)", 3, 2, false, "SUM(B6:B12)"},
+		{"function name", "LOG10(B4)", 3, 2, false, "LOG10(B6)"},
+		{"string constant", `"B4"&B4`, 3, 2, false, `"B4"&B6`},
+		{"other sheet", "Sheet2!B4+'My Sheet'!C5", 3, 2, false, "Sheet2!B4+'My Sheet'!C5"},
+		{"defined name", "TAX_B4*ABCD4", 3, 2, false, "TAX_B4*ABCD4"},
+		{"copy relative", "B3*C$3+$D3", 0, 2, false, "B5*C$3+$D5"},
+		{"copy range", "SUM(B$2:B3)", 0, 1, false, "SUM(B$2:B4)"},
+		{"copy zero rows", "B3", 0, 0, false, "B3"},
+		{"no reference", "TODAY()", 3, 2, true, "TODAY()"},
+	}
+	for _, tt := range tests {
+		if got := _shiftFormula(tt.formula, tt.from, tt.n, tt.extend); got != tt.want {
+			t.Errorf("%s: _shiftFormula(%q, %d, %d, %v) = %q, want %q", tt.name, tt.formula, tt.from, tt.n, tt.extend, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,529 @@
+/**
+ * Created by goland.
+ * User: adam_wang
+ * Date: 2023-09-28 20:42:19
+ */
+
+package tool
+
+import (
+	"fmt"
+	beegoContext "github.com/beego/beego/v2/server/web/context"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExportColumnsKey 选择导出列的请求参数名，多个列以逗号分隔，如columns=order_no,amount
+var ExportColumnsKey = "columns"
+
+// ExportColumn 导出列，由结构体字段的export标签解析
+// 标签示例：`export:"title=订单号;order=1;format=2006-01-02;width=20"`，可选项：
+// name 列标识（用于按请求参数选择列），默认为json标签名或下划线格式的字段名；
+// title 表头，默认为字段名；order 排序，未设置时按字段定义顺序；width Excel列宽；
+// format 时间格式（如2006-01-02）或数值格式（如%.2f）；precision 小数位数；
+// bool 布尔值显示文本，如bool=是,否；enum 枚举值显示文本，如enum=1:待支付,2:已支付；
+// 标签为-时忽略该字段；未设置export标签的结构体字段（指针只展开匿名嵌入的字段）会展开其中带有export标签的字段，
+// 结构体指针字段设置expand时展开（如`export:"expand"`），已在展开路径中的类型不再展开，避免自关联的模型无限递归，
+// expand可以与name（展开列标识的前缀，默认为json标签名）、title（展开列表头的前缀）同时使用，如`export:"expand;title=买家"`；
+// 导入时优先使用import标签（选项相同，另支持aliases 可匹配的其他表头，以|分隔）
+type ExportColumn struct {
+	Name      string
+	Title     string
+	Order     int
+	Width     float64
+	Format    string
+	Precision int
+	Bool      [2]string
+	Enum      map[string]string
+	Aliases   []string
+
+	index    []int
+	field    string
+	hasBool  bool
+	position int
+}
+
+// ParseExportColumns 解析模型的导出列（按order排序）
+// @param model interface{} 模型、模型指针或模型切片
+// @return []ExportColumn
+// @return error
+func ParseExportColumns(model interface{}) ([]ExportColumn, error) {
+	return _parseColumns(model, []string{"export"})
+}
+
+// SelectExportColumns 按列标识选择导出列，names为空时返回全部列
+// @param columns []ExportColumn
+// @param names []string
+// @return []ExportColumn
+// @return error
+func SelectExportColumns(columns []ExportColumn, names []string) ([]ExportColumn, error) {
+	if len(names) == 0 {
+		return columns, nil
+	}
+	index := make(map[string]int, len(columns))
+	for i, column := range columns {
+		index[column.Name] = i
+	}
+
+	selected := make([]ExportColumn, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		i, ok := index[name]
+		if !ok {
+			return nil, fmt.Errorf("export: unknown column %q", name)
+		}
+		selected = append(selected, columns[i])
+	}
+	return selected, nil
+}
+
+// ExportColumns 解析模型的导出列，并按请求参数（ExportColumnsKey）选择需要导出的列
+// @receiver ctx *Context
+// @param model interface{}
+// @return []ExportColumn
+// @return error
+func (ctx *Context) ExportColumns(model interface{}) ([]ExportColumn, error) {
+	columns, err := ParseExportColumns(model)
+	if err != nil {
+		return nil, err
+	}
+	value := ctx.Req.Request.URL.Query().Get(ExportColumnsKey)
+	if value == "" {
+		return columns, nil
+	}
+	return SelectExportColumns(columns, strings.Split(value, ","))
+}
+
+// ExportTitles 获取导出列的表头
+// @param columns []ExportColumn
+// @return []string
+func ExportTitles(columns []ExportColumn) []string {
+	titles := make([]string, len(columns))
+	for i, column := range columns {
+		titles[i] = column.Title
+	}
+	return titles
+}
+
+// Value 获取模型中该列用于导出的值：时间、布尔值、枚举按配置转换为字符串，数值保持原类型
+// @receiver c ExportColumn
+// @param item reflect.Value
+// @return interface{}
+func (c ExportColumn) Value(item reflect.Value) interface{} {
+	value, ok := _exportFieldValue(item, c.index)
+	if !ok {
+		return ""
+	}
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return ""
+		}
+		value = value.Elem()
+	}
+
+	if len(c.Enum) > 0 {
+		key := fmt.Sprint(value.Interface())
+		if text, ok := c.Enum[key]; ok {
+			return text
+		}
+		return key
+	}
+
+	if t, ok := value.Interface().(time.Time); ok {
+		if t.IsZero() {
+			return ""
+		}
+		layout := c.Format
+		if layout == "" {
+			layout = "2006-01-02 15:04:05"
+		}
+		return t.Format(layout)
+	}
+
+	switch value.Kind() {
+	case reflect.Bool:
+		if c.hasBool {
+			if value.Bool() {
+				return c.Bool[0]
+			}
+			return c.Bool[1]
+		}
+		return value.Bool()
+	case reflect.Float32, reflect.Float64:
+		if c.Format != "" {
+			return fmt.Sprintf(c.Format, value.Float())
+		}
+		if c.Precision >= 0 {
+			f, _ := strconv.ParseFloat(strconv.FormatFloat(value.Float(), 'f', c.Precision, 64), 64)
+			return f
+		}
+		return value.Float()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if c.Format != "" {
+			return fmt.Sprintf(c.Format, value.Int())
+		}
+		return value.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if c.Format != "" {
+			return fmt.Sprintf(c.Format, value.Uint())
+		}
+		return value.Uint()
+	case reflect.String:
+		return value.String()
+	}
+
+	//decimal等自定义类型使用其String方法
+	if s, ok := value.Interface().(fmt.Stringer); ok {
+		return s.String()
+	}
+	if value.CanAddr() {
+		if s, ok := value.Addr().Interface().(fmt.Stringer); ok {
+			return s.String()
+		}
+	}
+	return fmt.Sprint(value.Interface())
+}
+
+// String 获取模型中该列导出的字符串值
+// @receiver c ExportColumn
+// @param item reflect.Value
+// @return string
+func (c ExportColumn) String(item reflect.Value) string {
+	switch v := c.Value(item).(type) {
+	case string:
+		return v
+	case float64:
+		if c.Precision >= 0 {
+			return strconv.FormatFloat(v, 'f', c.Precision, 64)
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// ExportStructRows 将模型切片转换为行迭代器（同时实现ValueRowIterator，数值列在excel、ods中写为数字单元格）
+// @param list interface{} 模型切片，如[]*Order
+// @param columns []ExportColumn
+// @return RowIterator
+func ExportStructRows(list interface{}, columns []ExportColumn) RowIterator {
+	return &structRows{items: reflect.Indirect(reflect.ValueOf(list)), columns: columns}
+}
+
+// structRows 模型切片的行迭代器
+type structRows struct {
+	items   reflect.Value
+	columns []ExportColumn
+	i       int
+}
+
+// Next 获取下一行
+// @receiver r *structRows
+// @return []string
+// @return error
+func (r *structRows) Next() ([]string, error) {
+	item, err := r.next()
+	if err != nil {
+		return nil, err
+	}
+	row := make([]string, len(r.columns))
+	for j, column := range r.columns {
+		row[j] = column.String(item)
+	}
+	return row, nil
+}
+
+// NextValues 获取下一行，值的类型同ExportColumn.Value
+// @receiver r *structRows
+// @return []interface{}
+// @return error
+func (r *structRows) NextValues() ([]interface{}, error) {
+	item, err := r.next()
+	if err != nil {
+		return nil, err
+	}
+	row := make([]interface{}, len(r.columns))
+	for j, column := range r.columns {
+		row[j] = column.Value(item)
+	}
+	return row, nil
+}
+
+// next 下一个模型
+// @receiver r *structRows
+// @return reflect.Value
+// @return error
+func (r *structRows) next() (reflect.Value, error) {
+	if r.items.Kind() != reflect.Slice && r.items.Kind() != reflect.Array {
+		return reflect.Value{}, fmt.Errorf("export: list must be a slice, got %v", r.items.Kind())
+	}
+	if r.i >= r.items.Len() {
+		return reflect.Value{}, io.EOF
+	}
+	r.i++
+	return r.items.Index(r.i - 1), nil
+}
+
+// ExportStructCsv 按export标签将模型切片导出为CSV
+// @param ctx *beegoContext.Context
+// @param list interface{} 模型切片
+// @param columns []ExportColumn 导出列，为nil时导出全部列
+// @param fileName string
+// @param opts CsvOptions
+// @return error
+func ExportStructCsv(ctx *beegoContext.Context, list interface{}, columns []ExportColumn, fileName string, opts CsvOptions) error {
+	if columns == nil {
+		var err error
+		if columns, err = ParseExportColumns(list); err != nil {
+			return err
+		}
+	}
+	return ExportCsvStream(ctx, fileName, ExportTitles(columns), ExportStructRows(list, columns), opts)
+}
+
+// ExportStructExcel 按export标签将模型切片导出为excel，数值列保持数值类型并按width设置列宽
+// @param w http.ResponseWriter
+// @param r *http.Request
+// @param list interface{} 模型切片
+// @param columns []ExportColumn 导出列，为nil时导出全部列
+// @param fileName string
+// @return error
+func ExportStructExcel(w http.ResponseWriter, r *http.Request, list interface{}, columns []ExportColumn, fileName string) error {
+	if columns == nil {
+		var err error
+		if columns, err = ParseExportColumns(list); err != nil {
+			return err
+		}
+	}
+	items := reflect.Indirect(reflect.ValueOf(list))
+	if items.Kind() != reflect.Slice && items.Kind() != reflect.Array {
+		return fmt.Errorf("export: list must be a slice, got %v", items.Kind())
+	}
+
+	excelColumns := make([]ExcelColumn, len(columns))
+	for i, column := range columns {
+		excelColumns[i] = ExcelColumn{Title: column.Title, Width: column.Width}
+		if column.Precision >= 0 {
+			excelColumns[i].Format = "0" + strings.TrimSuffix("."+strings.Repeat("0", column.Precision), ".")
+		}
+	}
+
+	builder := NewExcelBuilder()
+	sheet := builder.Sheet("Sheet1").Columns(excelColumns...)
+	for i := 0; i < items.Len(); i++ {
+		values := make([]interface{}, len(columns))
+		for j, column := range columns {
+			values[j] = column.Value(items.Index(i))
+		}
+		sheet.AddRow(values...)
+	}
+	return builder.Export(w, r, fileName)
+}
+
+// _parseColumns 按标签解析模型的列（按order排序）
+// @param model interface{}
+// @param tags []string 依次查找的标签名
+// @return []ExportColumn
+// @return error
+func _parseColumns(model interface{}, tags []string) ([]ExportColumn, error) {
+	typ := reflect.TypeOf(model)
+	for typ != nil && (typ.Kind() == reflect.Ptr || typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array) {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("export: model must be a struct, got %v", reflect.TypeOf(model))
+	}
+
+	var columns []ExportColumn
+	if err := _collectExportColumns(typ, nil, "", tags, map[reflect.Type]bool{typ: true}, &columns); err != nil {
+		return nil, err
+	}
+	for i := range columns {
+		columns[i].position = i
+	}
+	sort.SliceStable(columns, func(i, j int) bool {
+		if columns[i].Order != columns[j].Order {
+			//未设置order的列排在设置了order的列之后
+			if columns[i].Order == 0 || columns[j].Order == 0 {
+				return columns[j].Order == 0
+			}
+			return columns[i].Order < columns[j].Order
+		}
+		return columns[i].position < columns[j].position
+	})
+	return columns, nil
+}
+
+// _collectExportColumns 递归收集结构体字段的导出列
+// @param typ reflect.Type
+// @param index []int 父级字段下标
+// @param namePrefix string 父级列标识前缀
+// @param tags []string 依次查找的标签名
+// @param visiting map[reflect.Type]bool 展开路径中的结构体类型
+// @param columns *[]ExportColumn
+// @return error
+func _collectExportColumns(typ reflect.Type, index []int, namePrefix string, tags []string, visiting map[reflect.Type]bool, columns *[]ExportColumn) error {
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		if sf.PkgPath != "" {
+			continue
+		}
+		var tag string
+		var hasTag bool
+		for _, name := range tags {
+			if tag, hasTag = sf.Tag.Lookup(name); hasTag {
+				break
+			}
+		}
+		if tag == "-" {
+			continue
+		}
+		fieldIndex := append(append([]int{}, index...), i)
+
+		fieldType := sf.Type
+		for fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		column := ExportColumn{Name: _jsonName(sf), Title: sf.Name, Precision: -1, index: fieldIndex, field: sf.Name}
+		var expand bool
+		if hasTag {
+			var err error
+			if expand, err = _parseExportTag(&column, tag); err != nil {
+				return fmt.Errorf("export: field %s.%s: %v", typ.Name(), sf.Name, err)
+			}
+		}
+
+		//未设置标签的结构体（匿名嵌入或非指针字段）及设置了expand的结构体展开其中的导出列
+		if !hasTag && (sf.Anonymous || sf.Type.Kind() != reflect.Ptr) || expand {
+			isStruct := fieldType.Kind() == reflect.Struct && fieldType != reflect.TypeOf(time.Time{})
+			if expand && !isStruct {
+				return fmt.Errorf("export: field %s.%s: expand requires a struct field", typ.Name(), sf.Name)
+			}
+			if isStruct && !visiting[fieldType] {
+				prefix := namePrefix
+				if !sf.Anonymous {
+					prefix = namePrefix + column.Name + "."
+				}
+				start := len(*columns)
+				visiting[fieldType] = true
+				err := _collectExportColumns(fieldType, fieldIndex, prefix, tags, visiting, columns)
+				delete(visiting, fieldType)
+				if err != nil {
+					return err
+				}
+				//设置了title时作为展开列表头的前缀
+				if column.Title != sf.Name {
+					for j := start; j < len(*columns); j++ {
+						(*columns)[j].Title = column.Title + (*columns)[j].Title
+					}
+				}
+			}
+			continue
+		}
+		if !hasTag {
+			continue
+		}
+
+		//结构体字段带有标签时作为整体导出（如decimal类型）
+		column.Name = namePrefix + column.Name
+		*columns = append(*columns, column)
+	}
+	return nil
+}
+
+// _parseExportTag 解析export（import）标签的选项，expand表示展开结构体字段，展开时只能同时设置name（列标识前缀）和title（表头前缀）
+// @param column *ExportColumn
+// @param tag string
+// @return bool 是否展开
+// @return error
+func _parseExportTag(column *ExportColumn, tag string) (bool, error) {
+	var expand bool
+	var others []string
+	for _, option := range strings.Split(tag, ";") {
+		option = strings.TrimSpace(option)
+		if option == "" {
+			continue
+		}
+		key, value := option, ""
+		if j := strings.Index(option, "="); j >= 0 {
+			key, value = strings.TrimSpace(option[:j]), strings.TrimSpace(option[j+1:])
+		}
+		var err error
+		switch key {
+		case "expand":
+			if value != "" {
+				err = fmt.Errorf("expand does not take a value")
+			}
+			expand = true
+		case "aliases":
+			column.Aliases = strings.Split(value, "|")
+		case "name":
+			column.Name = value
+		case "title":
+			column.Title = value
+		case "order":
+			column.Order, err = strconv.Atoi(value)
+		case "width":
+			column.Width, err = strconv.ParseFloat(value, 64)
+		case "format":
+			column.Format = value
+		case "precision":
+			column.Precision, err = strconv.Atoi(value)
+		case "bool":
+			texts := strings.SplitN(value, ",", 2)
+			if len(texts) != 2 {
+				err = fmt.Errorf("bool requires two values separated by comma")
+			} else {
+				column.Bool, column.hasBool = [2]string{texts[0], texts[1]}, true
+			}
+		case "enum":
+			column.Enum = make(map[string]string)
+			for _, item := range strings.Split(value, ",") {
+				pair := strings.SplitN(item, ":", 2)
+				if len(pair) != 2 {
+					err = fmt.Errorf("enum item %q must be value:text", item)
+					break
+				}
+				column.Enum[strings.TrimSpace(pair[0])] = strings.TrimSpace(pair[1])
+			}
+		default:
+			err = fmt.Errorf("unknown option %q", key)
+		}
+		if err != nil {
+			return false, err
+		}
+		if key != "expand" && key != "name" && key != "title" {
+			others = append(others, key)
+		}
+	}
+	if expand && len(others) > 0 {
+		return false, fmt.Errorf("option %q cannot be used with expand", others[0])
+	}
+	return expand, nil
+}
+
+// _exportFieldValue 按下标路径获取字段值，路径中的空指针返回false
+// @param item reflect.Value
+// @param index []int
+// @return reflect.Value
+// @return bool
+func _exportFieldValue(item reflect.Value, index []int) (reflect.Value, bool) {
+	for _, i := range index {
+		for item.Kind() == reflect.Ptr || item.Kind() == reflect.Interface {
+			if item.IsNil() {
+				return reflect.Value{}, false
+			}
+			item = item.Elem()
+		}
+		item = item.Field(i)
+	}
+	return item, true
+}
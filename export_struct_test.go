@@ -0,0 +1,106 @@
+/**
+ * Created by goland.
+ * User: adam_wang
+ * Date: 2023-09-28 20:42:19
+ */
+
+package tool
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type exportTestUser struct {
+	Name   string          `export:"title=姓名"`
+	Mobile string          `json:"mobile" export:"title=手机号"`
+	Parent *exportTestUser `export:"expand"`
+}
+
+func TestParseExportColumnsExpand(t *testing.T) {
+	tests := []struct {
+		name       string
+		model      interface{}
+		wantNames  []string
+		wantTitles []string
+		wantErr    string
+	}{
+		{
+			name: "expand only",
+			model: struct {
+				Buyer *exportTestUser `json:"buyer" export:"expand"`
+			}{},
+			wantNames:  []string{"buyer.name", "buyer.mobile"},
+			wantTitles: []string{"姓名", "手机号"},
+		},
+		{
+			name: "expand with title",
+			model: struct {
+				Buyer *exportTestUser `json:"buyer" export:"expand;title=买家"`
+			}{},
+			wantNames:  []string{"buyer.name", "buyer.mobile"},
+			wantTitles: []string{"买家姓名", "买家手机号"},
+		},
+		{
+			name: "expand with name",
+			model: struct {
+				Buyer *exportTestUser `export:" title=卖家 ; expand ; name=seller "`
+			}{},
+			wantNames:  []string{"seller.name", "seller.mobile"},
+			wantTitles: []string{"卖家姓名", "卖家手机号"},
+		},
+		{
+			name: "expand with other option",
+			model: struct {
+				Buyer *exportTestUser `export:"expand;order=1"`
+			}{},
+			wantErr: `option "order" cannot be used with expand`,
+		},
+		{
+			name: "expand with value",
+			model: struct {
+				Buyer *exportTestUser `export:"expand=true"`
+			}{},
+			wantErr: "expand does not take a value",
+		},
+		{
+			name: "expand non struct",
+			model: struct {
+				Name string `export:"expand"`
+			}{},
+			wantErr: "expand requires a struct field",
+		},
+		{
+			name: "unknown option",
+			model: struct {
+				Name string `export:"title=姓名;expend"`
+			}{},
+			wantErr: `unknown option "expend"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			columns, err := ParseExportColumns(tt.model)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParseExportColumns() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseExportColumns() error = %v", err)
+			}
+			names := make([]string, len(columns))
+			for i, column := range columns {
+				names[i] = column.Name
+			}
+			if !reflect.DeepEqual(names, tt.wantNames) {
+				t.Errorf("names = %q, want %q", names, tt.wantNames)
+			}
+			if titles := ExportTitles(columns); !reflect.DeepEqual(titles, tt.wantTitles) {
+				t.Errorf("titles = %q, want %q", titles, tt.wantTitles)
+			}
+		})
+	}
+}
@@ -0,0 +1,805 @@
+/**
+ * Created by goland.
+ * User: adam_wang
+ * Date: 2023-10-11 19:36:02
+ */
+
+package tool
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	beegoContext "github.com/beego/beego/v2/server/web/context"
+	"github.com/signintech/gopdf"
+	"html"
+	"io"
+	"mime"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ExportFormatKey 选择导出格式的请求参数名，如format=xlsx
+var ExportFormatKey = "format"
+
+// Exporter 导出格式
+type Exporter interface {
+	// ContentType 响应的Content-Type
+	ContentType() string
+	// Extension 文件扩展名（含.）
+	Extension() string
+	// Export 将表头及数据写入w
+	Export(w io.Writer, title []string, rows RowIterator) error
+}
+
+var (
+	exportersMu sync.RWMutex
+	exporters   = map[string]Exporter{
+		"csv":    &CsvExporter{Options: CsvOptions{Encoding: CsvUTF8BOM}},
+		"tsv":    &CsvExporter{Options: CsvOptions{Encoding: CsvUTF8BOM, Comma: '\t'}, Tab: true},
+		"xlsx":   &ExcelExporter{},
+		"ods":    &OdsExporter{},
+		"ndjson": &NDJSONExporter{},
+		"jsonl":  &NDJSONExporter{},
+		"html":   &HTMLExporter{},
+		"pdf":    &PdfExporter{},
+	}
+)
+
+// PdfFontPaths PdfExporter未指定字体时依次查找的系统字体（TrueType），找到的第一个字体用于导出
+var PdfFontPaths = []string{
+	"/usr/share/fonts/truetype/droid/DroidSansFallbackFull.ttf",
+	"/usr/share/fonts/google-droid/DroidSansFallbackFull.ttf",
+	"/System/Library/Fonts/Supplemental/Arial Unicode.ttf",
+	"/Library/Fonts/Arial Unicode.ttf",
+	`C:\Windows\Fonts\simhei.ttf`,
+	"/usr/share/fonts/truetype/dejavu/DejaVuSans.ttf",
+}
+
+// ValueRowIterator 可按原类型获取值的行迭代器，excel、ods只将数值类型（整数、浮点数）写为数字单元格，
+// 仅实现RowIterator时全部写为文本（避免手机号、订单号等纯数字字符串被转换为数字）
+type ValueRowIterator interface {
+	RowIterator
+	NextValues() ([]interface{}, error)
+}
+
+// RowsFromValues 由二维切片创建按原类型取值的行迭代器
+// @param dataList [][]interface{}
+// @return RowIterator
+func RowsFromValues(dataList [][]interface{}) RowIterator {
+	return &valueRows{rows: dataList}
+}
+
+// valueRows 二维切片的行迭代器
+type valueRows struct {
+	rows [][]interface{}
+	i    int
+}
+
+// Next 获取下一行
+// @receiver r *valueRows
+// @return []string
+// @return error
+func (r *valueRows) Next() ([]string, error) {
+	values, err := r.NextValues()
+	if err != nil {
+		return nil, err
+	}
+	row := make([]string, len(values))
+	for i, value := range values {
+		row[i] = _cellText(value)
+	}
+	return row, nil
+}
+
+// NextValues 获取下一行
+// @receiver r *valueRows
+// @return []interface{}
+// @return error
+func (r *valueRows) NextValues() ([]interface{}, error) {
+	if r.i >= len(r.rows) {
+		return nil, io.EOF
+	}
+	r.i++
+	return r.rows[r.i-1], nil
+}
+
+// RegisterExporter 注册导出格式（同名覆盖），如注册配置了中文字体的pdf格式
+// @param format string
+// @param e Exporter
+func RegisterExporter(format string, e Exporter) {
+	exportersMu.Lock()
+	defer exportersMu.Unlock()
+	exporters[strings.ToLower(format)] = e
+}
+
+// GetExporter 获取导出格式
+// @param format string
+// @return Exporter
+// @return bool
+func GetExporter(format string) (Exporter, bool) {
+	exportersMu.RLock()
+	defer exportersMu.RUnlock()
+	e, ok := exporters[strings.ToLower(format)]
+	return e, ok
+}
+
+// Exporter 按请求参数（ExportFormatKey）或Accept头选择导出格式，都未指定时使用defaultFormat；
+// Accept头中的text/html只在作为唯一类型时生效（浏览器直接打开下载地址时Accept以text/html开头）
+// @receiver ctx *Context
+// @param defaultFormat string
+// @return Exporter
+// @return error
+func (ctx *Context) Exporter(defaultFormat string) (Exporter, error) {
+	if format := ctx.Query(ExportFormatKey); format != "" {
+		if e, ok := GetExporter(format); ok {
+			return e, nil
+		}
+		return nil, fmt.Errorf("export: unsupported format %q", format)
+	}
+
+	if e := _acceptExporter(ctx.Req.Request.Header.Get("Accept")); e != nil {
+		return e, nil
+	}
+	if e, ok := GetExporter(defaultFormat); ok {
+		return e, nil
+	}
+	return nil, fmt.Errorf("export: unsupported format %q", defaultFormat)
+}
+
+// Export 按请求选择导出格式（默认为csv）并导出
+// @receiver ctx *Context
+// @param fileName string 不含扩展名的文件名
+// @param title []string
+// @param rows RowIterator
+// @return error
+func (ctx *Context) Export(fileName string, title []string, rows RowIterator) error {
+	e, err := ctx.Exporter("csv")
+	if err != nil {
+		return err
+	}
+	return ExportWith(ctx.Req, e, fileName, title, rows)
+}
+
+// ExportWith 使用指定格式导出并写入响应
+// @param ctx *beegoContext.Context
+// @param e Exporter
+// @param fileName string 不含扩展名的文件名
+// @param title []string
+// @param rows RowIterator
+// @return error
+func ExportWith(ctx *beegoContext.Context, e Exporter, fileName string, title []string, rows RowIterator) error {
+	ctx.Output.Header("Content-Type", e.ContentType())
+	ctx.Output.Header("Content-Disposition", ContentDisposition(fileName+e.Extension()))
+	return e.Export(ctx.ResponseWriter, title, rows)
+}
+
+// CsvExporter CSV/TSV格式
+type CsvExporter struct {
+	Options CsvOptions
+	Tab     bool // 是否为TSV（影响Content-Type及扩展名，分隔符由Options.Comma设置）
+}
+
+// ContentType 响应的Content-Type
+// @receiver e *CsvExporter
+// @return string
+func (e *CsvExporter) ContentType() string {
+	charset := "utf-8"
+	if enc := strings.ToLower(e.Options.Encoding); enc == CsvGBK || enc == CsvGB18030 {
+		charset = enc
+	}
+	if e.Tab {
+		return "text/tab-separated-values; charset=" + charset
+	}
+	return "text/csv; charset=" + charset
+}
+
+// Extension 文件扩展名
+// @receiver e *CsvExporter
+// @return string
+func (e *CsvExporter) Extension() string {
+	if e.Tab {
+		return ".tsv"
+	}
+	return ".csv"
+}
+
+// Export 导出
+// @receiver e *CsvExporter
+// @param w io.Writer
+// @param title []string
+// @param rows RowIterator
+// @return error
+func (e *CsvExporter) Export(w io.Writer, title []string, rows RowIterator) error {
+	writer, err := NewCsvWriter(w, e.Options)
+	if err != nil {
+		return err
+	}
+	if len(title) > 0 {
+		if err = writer.Write(title); err != nil {
+			return err
+		}
+	}
+	return writer.WriteAll(rows)
+}
+
+// ExcelExporter excel（xlsx）格式，使用流式写入，数值类型（见ValueRowIterator）写为数字单元格
+type ExcelExporter struct {
+	Options ExcelStreamOptions
+}
+
+// ContentType 响应的Content-Type
+// @receiver e *ExcelExporter
+// @return string
+func (e *ExcelExporter) ContentType() string {
+	return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+}
+
+// Extension 文件扩展名
+// @receiver e *ExcelExporter
+// @return string
+func (e *ExcelExporter) Extension() string {
+	return ".xlsx"
+}
+
+// Export 导出
+// @receiver e *ExcelExporter
+// @param w io.Writer
+// @param title []string
+// @param rows RowIterator
+// @return error
+func (e *ExcelExporter) Export(w io.Writer, title []string, rows RowIterator) error {
+	opts := e.Options
+	opts.Header = title
+	writer := NewExcelStreamWriter(w, opts)
+	for {
+		values, err := _nextValues(rows)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err = writer.WriteRow(values...); err != nil {
+			return err
+		}
+	}
+	return writer.Close()
+}
+
+// NDJSONExporter JSON Lines格式，每行一个以表头为key的JSON对象
+type NDJSONExporter struct{}
+
+// ContentType 响应的Content-Type
+// @receiver e *NDJSONExporter
+// @return string
+func (e *NDJSONExporter) ContentType() string {
+	return "application/x-ndjson; charset=utf-8"
+}
+
+// Extension 文件扩展名
+// @receiver e *NDJSONExporter
+// @return string
+func (e *NDJSONExporter) Extension() string {
+	return ".jsonl"
+}
+
+// Export 导出
+// @receiver e *NDJSONExporter
+// @param w io.Writer
+// @param title []string
+// @param rows RowIterator
+// @return error
+func (e *NDJSONExporter) Export(w io.Writer, title []string, rows RowIterator) error {
+	buf := bufio.NewWriter(w)
+	keys := make([][]byte, len(title))
+	for i, t := range title {
+		keys[i], _ = json.Marshal(t)
+	}
+	for {
+		row, err := rows.Next()
+		if err == io.EOF {
+			return buf.Flush()
+		}
+		if err != nil {
+			return err
+		}
+
+		//按表头顺序输出字段
+		buf.WriteByte('{')
+		for i, value := range row {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if i < len(keys) {
+				buf.Write(keys[i])
+			} else {
+				buf.WriteString(strconv.Quote("column" + strconv.Itoa(i+1)))
+			}
+			buf.WriteByte(':')
+			v, _ := json.Marshal(value)
+			buf.Write(v)
+		}
+		if _, err = buf.WriteString("}\n"); err != nil {
+			return err
+		}
+	}
+}
+
+// HTMLExporter 可打印的HTML表格，打印时每页重复表头
+type HTMLExporter struct {
+	Title string // 页面标题
+}
+
+// ContentType 响应的Content-Type
+// @receiver e *HTMLExporter
+// @return string
+func (e *HTMLExporter) ContentType() string {
+	return "text/html; charset=utf-8"
+}
+
+// Extension 文件扩展名
+// @receiver e *HTMLExporter
+// @return string
+func (e *HTMLExporter) Extension() string {
+	return ".html"
+}
+
+// Export 导出
+// @receiver e *HTMLExporter
+// @param w io.Writer
+// @param title []string
+// @param rows RowIterator
+// @return error
+func (e *HTMLExporter) Export(w io.Writer, title []string, rows RowIterator) error {
+	buf := bufio.NewWriter(w)
+	buf.WriteString(`<!DOCTYPE html><html><head><meta charset="utf-8"><title>` + html.EscapeString(e.Title) + `</title>` +
+		`<style>body{font-family:sans-serif;font-size:12px}table{border-collapse:collapse;width:100%}` +
+		`th,td{border:1px solid #999;padding:4px 6px;text-align:left}th{background:#eee}` +
+		`thead{display:table-header-group}tr{page-break-inside:avoid}</style></head><body>`)
+	if e.Title != "" {
+		buf.WriteString(`<h1>` + html.EscapeString(e.Title) + `</h1>`)
+	}
+	buf.WriteString(`<table><thead><tr>`)
+	for _, t := range title {
+		buf.WriteString(`<th>` + html.EscapeString(t) + `</th>`)
+	}
+	buf.WriteString(`</tr></thead><tbody>`)
+	for {
+		row, err := rows.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		buf.WriteString(`<tr>`)
+		for _, value := range row {
+			buf.WriteString(`<td>` + html.EscapeString(value) + `</td>`)
+		}
+		if _, err = buf.WriteString("</tr>\n"); err != nil {
+			return err
+		}
+	}
+	buf.WriteString(`</tbody></table></body></html>`)
+	return buf.Flush()
+}
+
+// OdsExporter OpenDocument电子表格格式，使用流式写入
+type OdsExporter struct {
+	SheetName string // 工作表名称，默认为Sheet1
+}
+
+// ContentType 响应的Content-Type
+// @receiver e *OdsExporter
+// @return string
+func (e *OdsExporter) ContentType() string {
+	return "application/vnd.oasis.opendocument.spreadsheet"
+}
+
+// Extension 文件扩展名
+// @receiver e *OdsExporter
+// @return string
+func (e *OdsExporter) Extension() string {
+	return ".ods"
+}
+
+// Export 导出
+// @receiver e *OdsExporter
+// @param w io.Writer
+// @param title []string
+// @param rows RowIterator
+// @return error
+func (e *OdsExporter) Export(w io.Writer, title []string, rows RowIterator) error {
+	sheetName := e.SheetName
+	if sheetName == "" {
+		sheetName = "Sheet1"
+	}
+
+	z := zip.NewWriter(w)
+	//mimetype必须是第一个且不压缩
+	entry, err := z.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	if _, err = io.WriteString(entry, e.ContentType()); err != nil {
+		return err
+	}
+	if entry, err = z.Create("META-INF/manifest.xml"); err != nil {
+		return err
+	}
+	_, err = io.WriteString(entry, xml.Header+`<manifest:manifest xmlns:manifest="urn:oasis:names:tc:opendocument:xmlns:manifest:1.0" manifest:version="1.2">`+
+		`<manifest:file-entry manifest:full-path="/" manifest:media-type="`+e.ContentType()+`"/>`+
+		`<manifest:file-entry manifest:full-path="content.xml" manifest:media-type="text/xml"/>`+
+		`</manifest:manifest>`)
+	if err != nil {
+		return err
+	}
+
+	if entry, err = z.Create("content.xml"); err != nil {
+		return err
+	}
+	buf := bufio.NewWriter(entry)
+	buf.WriteString(xml.Header + `<office:document-content xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" ` +
+		`xmlns:table="urn:oasis:names:tc:opendocument:xmlns:table:1.0" xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0" ` +
+		`office:version="1.2"><office:body><office:spreadsheet><table:table table:name="`)
+	_ = xml.EscapeText(buf, []byte(sheetName))
+	buf.WriteString(`">`)
+
+	writeRow := func(row []interface{}) {
+		buf.WriteString(`<table:table-row>`)
+		for _, value := range row {
+			text := _cellText(value)
+			if f, ok := _cellFloat(value); ok {
+				buf.WriteString(`<table:table-cell office:value-type="float" office:value="` + strconv.FormatFloat(f, 'f', -1, 64) + `"><text:p>`)
+			} else {
+				buf.WriteString(`<table:table-cell office:value-type="string"><text:p>`)
+			}
+			_ = xml.EscapeText(buf, []byte(text))
+			buf.WriteString(`</text:p></table:table-cell>`)
+		}
+		buf.WriteString(`</table:table-row>`)
+	}
+	if len(title) > 0 {
+		header := make([]interface{}, len(title))
+		for i, value := range title {
+			header[i] = value
+		}
+		writeRow(header)
+	}
+	for {
+		row, err := _nextValues(rows)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		writeRow(row)
+	}
+	buf.WriteString(`</table:table></office:spreadsheet></office:body></office:document-content>`)
+	if err = buf.Flush(); err != nil {
+		return err
+	}
+	return z.Close()
+}
+
+// PdfExporter 分页的PDF表格（纯Go实现），中文等字符需要指定包含对应字形的TrueType字体（如Noto Sans SC），字体子集会嵌入文件；
+// 未指定字体时使用PdfFontPaths中找到的系统字体
+type PdfExporter struct {
+	Title     string  // 标题，显示在第一页顶部
+	FontPath  string  // TrueType字体文件路径
+	FontData  []byte  // TrueType字体数据（如通过embed嵌入），优先于FontPath
+	FontSize  float64 // 字号，默认为9
+	Portrait  bool    // 是否纵向，默认为横向A4
+	RowHeight float64 // 行高，默认为字号的2倍
+}
+
+// ContentType 响应的Content-Type
+// @receiver e *PdfExporter
+// @return string
+func (e *PdfExporter) ContentType() string {
+	return "application/pdf"
+}
+
+// Extension 文件扩展名
+// @receiver e *PdfExporter
+// @return string
+func (e *PdfExporter) Extension() string {
+	return ".pdf"
+}
+
+// Export 导出，列宽平分页面宽度，超出列宽的内容截断；每页重复表头并在底部显示页码
+// @receiver e *PdfExporter
+// @param w io.Writer
+// @param title []string
+// @param rows RowIterator
+// @return error
+func (e *PdfExporter) Export(w io.Writer, title []string, rows RowIterator) error {
+	fontData := e.FontData
+	if len(fontData) == 0 {
+		fontPath := e.FontPath
+		if fontPath == "" {
+			fontPath = _pdfSystemFont()
+		}
+		if fontPath == "" {
+			return errors.New("export: pdf font is required, set FontPath or FontData")
+		}
+		var err error
+		if fontData, err = os.ReadFile(fontPath); err != nil {
+			return err
+		}
+	}
+	fontSize := e.FontSize
+	if fontSize <= 0 {
+		fontSize = 9
+	}
+	rowHeight := e.RowHeight
+	if rowHeight <= 0 {
+		rowHeight = fontSize * 2
+	}
+	pageSize := *gopdf.PageSizeA4Landscape
+	if e.Portrait {
+		pageSize = *gopdf.PageSizeA4
+	}
+	const margin = 30.0
+
+	pdf := &gopdf.GoPdf{}
+	pdf.Start(gopdf.Config{PageSize: pageSize})
+	if err := pdf.AddTTFFontData("export", fontData); err != nil {
+		return err
+	}
+	if err := pdf.SetFont("export", "", fontSize); err != nil {
+		return err
+	}
+	pdf.SetLineWidth(0.5)
+
+	columns := len(title)
+	colWidth := 0.0
+	page := 0
+	var y float64
+	drawRow := func(row []string, header bool) error {
+		if columns == 0 {
+			columns = len(row)
+		}
+		if colWidth == 0 && columns > 0 {
+			colWidth = (pageSize.W - 2*margin) / float64(columns)
+		}
+		for i := 0; i < columns; i++ {
+			value := ""
+			if i < len(row) {
+				value = _pdfFit(pdf, row[i], colWidth-4)
+			}
+			if header {
+				pdf.SetFillColor(230, 230, 230)
+				pdf.RectFromUpperLeftWithStyle(margin+float64(i)*colWidth, y, colWidth, rowHeight, "F")
+			}
+			pdf.SetXY(margin+float64(i)*colWidth, y)
+			err := pdf.CellWithOption(&gopdf.Rect{W: colWidth, H: rowHeight}, value, gopdf.CellOption{
+				Align:  gopdf.Left | gopdf.Middle,
+				Border: gopdf.AllBorders,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		y += rowHeight
+		return nil
+	}
+	newPage := func() error {
+		pdf.AddPage()
+		page++
+		y = margin
+		pdf.SetXY(margin, pageSize.H-margin/2-fontSize)
+		if err := pdf.Cell(nil, fmt.Sprintf("- %d -", page)); err != nil {
+			return err
+		}
+		if page == 1 && e.Title != "" {
+			pdf.SetXY(margin, y)
+			if err := pdf.Cell(nil, e.Title); err != nil {
+				return err
+			}
+			y += rowHeight * 1.5
+		}
+		if len(title) > 0 {
+			return drawRow(title, true)
+		}
+		return nil
+	}
+
+	if err := newPage(); err != nil {
+		return err
+	}
+	for {
+		row, err := rows.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if y+rowHeight > pageSize.H-margin {
+			if err = newPage(); err != nil {
+				return err
+			}
+		}
+		if err = drawRow(row, false); err != nil {
+			return err
+		}
+	}
+	return pdf.Write(w)
+}
+
+// _pdfFit 截断超出宽度的文本
+// @param pdf *gopdf.GoPdf
+// @param text string
+// @param width float64
+// @return string
+func _pdfFit(pdf *gopdf.GoPdf, text string, width float64) string {
+	if w, err := pdf.MeasureTextWidth(text); err != nil || w <= width {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		if w, err := pdf.MeasureTextWidth(string(runes) + "…"); err == nil && w <= width {
+			break
+		}
+	}
+	return string(runes) + "…"
+}
+
+// _acceptExporter 按Accept头选择导出格式（按q值排序），text/html只在作为唯一类型时生效
+// @param accept string
+// @return Exporter
+func _acceptExporter(accept string) Exporter {
+	if accept == "" {
+		return nil
+	}
+	type mediaRange struct {
+		typ string
+		q   float64
+	}
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		typ, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || strings.Contains(typ, "*") {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		ranges = append(ranges, mediaRange{typ: typ, q: q})
+	}
+	//浏览器直接打开下载地址时Accept以text/html开头，此时不应导出HTML
+	if len(ranges) > 1 {
+		filtered := ranges[:0]
+		for _, r := range ranges {
+			if r.typ != "text/html" {
+				filtered = append(filtered, r)
+			}
+		}
+		ranges = filtered
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].q > ranges[j].q
+	})
+
+	exportersMu.RLock()
+	defer exportersMu.RUnlock()
+	names := make([]string, 0, len(exporters))
+	for name := range exporters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, r := range ranges {
+		for _, name := range names {
+			typ, _, err := mime.ParseMediaType(exporters[name].ContentType())
+			if err == nil && typ == r.typ {
+				return exporters[name]
+			}
+		}
+	}
+	return nil
+}
+
+// _nextValues 按原类型获取下一行，rows未实现ValueRowIterator时全部为字符串
+// @param rows RowIterator
+// @return []interface{}
+// @return error
+func _nextValues(rows RowIterator) ([]interface{}, error) {
+	if r, ok := rows.(ValueRowIterator); ok {
+		return r.NextValues()
+	}
+	row, err := rows.Next()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]interface{}, len(row))
+	for i, value := range row {
+		values[i] = value
+	}
+	return values, nil
+}
+
+// _cellFloat 整数及浮点数类型的值转换为float64
+// @param value interface{}
+// @return float64
+// @return bool
+func _cellFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// _cellText 单元格的显示文本
+// @param value interface{}
+// @return string
+func _cellText(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		if v.IsZero() {
+			return ""
+		}
+		return v.Format("2006-01-02 15:04:05")
+	case *time.Time:
+		if v == nil {
+			return ""
+		}
+		return _cellText(*v)
+	}
+	return fmt.Sprint(value)
+}
+
+// _pdfSystemFont 查找PdfFontPaths中存在的字体
+// @return string
+func _pdfSystemFont() string {
+	for _, path := range PdfFontPaths {
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+	}
+	return ""
+}
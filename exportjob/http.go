@@ -0,0 +1,134 @@
+/**
+ * Created by goland.
+ * User: adam_wang
+ * Date: 2023-10-08 18:14:52
+ */
+
+package exportjob
+
+import (
+	"errors"
+	tool "github.com/adam-qiang/beego-tool"
+	beegoContext "github.com/beego/beego/v2/server/web/context"
+	"io"
+	"net/http"
+)
+
+// JobStatus 任务状态接口的响应数据
+type JobStatus struct {
+	*Job
+	DownloadURL string `json:"download_url,omitempty"`
+}
+
+// ServeStatus 输出任务状态，任务成功时附带下载地址；owner不为空时只允许查询该用户的任务
+// @receiver m *Manager
+// @param ctx *beegoContext.Context
+// @param id string
+// @param owner string
+func (m *Manager) ServeStatus(ctx *beegoContext.Context, id, owner string) {
+	c := tool.NewContext(ctx)
+	job, err := m._ownedJob(ctx, id, owner)
+	if err != nil {
+		m._serveError(c, err)
+		return
+	}
+
+	status := JobStatus{Job: job}
+	if job.Status == StatusSucceeded {
+		if status.DownloadURL, err = m.SignedURL(ctx.Request.Context(), job); err != nil {
+			m._serveError(c, err)
+			return
+		}
+	}
+	c.OtuPutJson(http.StatusOK, tool.ReturnMsg{Code: http.StatusOK, Msg: "success", Data: status})
+}
+
+// ServeCancel 取消任务；owner不为空时只允许取消该用户的任务
+// @receiver m *Manager
+// @param ctx *beegoContext.Context
+// @param id string
+// @param owner string
+func (m *Manager) ServeCancel(ctx *beegoContext.Context, id, owner string) {
+	c := tool.NewContext(ctx)
+	if _, err := m._ownedJob(ctx, id, owner); err != nil {
+		m._serveError(c, err)
+		return
+	}
+	if err := m.Cancel(ctx.Request.Context(), id); err != nil {
+		c.OtuPutJson(http.StatusConflict, tool.ReturnMsg{Code: http.StatusConflict, Msg: err.Error()})
+		return
+	}
+	c.OtuPutJson(http.StatusOK, tool.ReturnMsg{Code: http.StatusOK, Msg: "success"})
+}
+
+// ServeDownload 校验下载地址的签名（请求参数id、expires、sign）并输出文件
+// @receiver m *Manager
+// @param ctx *beegoContext.Context
+func (m *Manager) ServeDownload(ctx *beegoContext.Context) {
+	c := tool.NewContext(ctx)
+	id := c.Query("id")
+	if err := m.Verify(id, c.Query("expires"), c.Query("sign")); err != nil {
+		m._serveError(c, err)
+		return
+	}
+	job, err := m.Get(ctx.Request.Context(), id)
+	if err == nil && job.Status != StatusSucceeded {
+		err = ErrJobNotFound
+	}
+	if err != nil {
+		m._serveError(c, err)
+		return
+	}
+
+	file, err := m.Storage.Open(ctx.Request.Context(), m._fileKey(job))
+	if err != nil {
+		m._serveError(c, err)
+		return
+	}
+	defer file.Close()
+
+	ctx.Output.Header("Content-Disposition", tool.ContentDisposition(job.FileName))
+	if seeker, ok := file.(io.ReadSeeker); ok {
+		modTime := job.CreatedAt
+		if job.FinishedAt != nil {
+			modTime = *job.FinishedAt
+		}
+		http.ServeContent(ctx.ResponseWriter, ctx.Request, job.FileName, modTime, seeker)
+		return
+	}
+	ctx.Output.Header("Content-Type", "application/octet-stream")
+	_, _ = io.Copy(ctx.ResponseWriter, file)
+}
+
+// _ownedJob 获取任务并校验所属用户
+// @receiver m *Manager
+// @param ctx *beegoContext.Context
+// @param id string
+// @param owner string
+// @return *Job
+// @return error
+func (m *Manager) _ownedJob(ctx *beegoContext.Context, id, owner string) (*Job, error) {
+	job, err := m.Get(ctx.Request.Context(), id)
+	if err != nil {
+		return nil, err
+	}
+	if owner != "" && job.Owner != owner {
+		return nil, ErrJobNotFound
+	}
+	return job, nil
+}
+
+// _serveError 输出错误响应
+// @receiver m *Manager
+// @param c *tool.Context
+// @param err error
+func (m *Manager) _serveError(c *tool.Context, err error) {
+	code := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrJobNotFound):
+		code = http.StatusNotFound
+	case errors.Is(err, ErrInvalidSignature):
+		code = http.StatusForbidden
+	}
+	c.OtuPutJson(code, tool.ReturnMsg{Code: code, Msg: err.Error()})
+}
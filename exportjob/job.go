@@ -0,0 +1,392 @@
+/**
+ * Created by goland.
+ * User: adam_wang
+ * Date: 2023-10-08 15:42:10
+ */
+
+// Package exportjob 异步导出任务
+//
+// 接口中调用Enqueue创建任务后立即返回任务ID，任务状态及进度保存在redis中；
+// 后台worker调用注册的Handler生成文件并写入Storage（本地磁盘或自定义存储），
+// 客户端轮询任务状态，完成后通过带签名、有时效的地址下载；任务可以取消，过期文件定期清理
+package exportjob
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/adam-qiang/beego-tool/database"
+	"github.com/redis/go-redis/v9"
+	"io"
+	"net/url"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 任务状态
+const (
+	StatusPending   = "pending"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+)
+
+// ErrJobNotFound 任务不存在或已过期
+var ErrJobNotFound = errors.New("exportjob: job not found")
+
+// ErrInvalidSignature 下载地址签名错误或已过期
+var ErrInvalidSignature = errors.New("exportjob: invalid or expired signature")
+
+// ErrEmptySecret 未设置下载地址签名密钥
+var ErrEmptySecret = errors.New("exportjob: secret must not be empty")
+
+// Job 导出任务
+type Job struct {
+	ID         string            `json:"id"`
+	Handler    string            `json:"handler"`
+	FileName   string            `json:"file_name"`
+	Params     map[string]string `json:"params,omitempty"`
+	Owner      string            `json:"owner,omitempty"`
+	Status     string            `json:"status"`
+	Total      int64             `json:"total"`
+	Processed  int64             `json:"processed"`
+	Progress   float64           `json:"progress"` // 进度百分比，Total未知时为0
+	Size       int64             `json:"size"`     // 文件大小
+	Error      string            `json:"error,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+	StartedAt  *time.Time        `json:"started_at,omitempty"`
+	FinishedAt *time.Time        `json:"finished_at,omitempty"`
+}
+
+// Done 任务是否已结束
+// @receiver j *Job
+// @return bool
+func (j *Job) Done() bool {
+	return j.Status == StatusSucceeded || j.Status == StatusFailed || j.Status == StatusCancelled
+}
+
+// Handler 导出处理方法，将文件内容写入w，并通过p报告进度；ctx在任务取消或超时时结束
+type Handler func(ctx context.Context, job *Job, p *Progress, w io.Writer) error
+
+// Manager 导出任务管理
+type Manager struct {
+	Storage     Storage       // 文件存储
+	Secret      []byte        // 下载地址签名密钥，不能为空
+	DownloadURL string        // 下载接口地址，默认为/export/download
+	LinkTTL     time.Duration // 下载地址有效期，默认为10分钟
+	Workers     int           // 每个进程的worker数量，默认为2
+	Timeout     time.Duration // 单个任务的超时时间，默认为1小时
+	Retention   time.Duration // 任务及文件的保留时间，默认为24小时
+	GCInterval  time.Duration // 清理过期文件的间隔，默认为10分钟
+	KeyPrefix   string        // redis key前缀，默认为export_job
+
+	mu       sync.RWMutex
+	handlers map[string]Handler
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+// NewManager 创建导出任务管理，secret为空时panic（空密钥生成的签名可以被任何人伪造）
+// @param storage Storage
+// @param secret string 下载地址签名密钥
+// @return *Manager
+func NewManager(storage Storage, secret string) *Manager {
+	if secret == "" {
+		panic(ErrEmptySecret)
+	}
+	return &Manager{
+		Storage:     storage,
+		Secret:      []byte(secret),
+		DownloadURL: "/export/download",
+		LinkTTL:     10 * time.Minute,
+		Workers:     2,
+		Timeout:     time.Hour,
+		Retention:   24 * time.Hour,
+		GCInterval:  10 * time.Minute,
+		KeyPrefix:   "export_job",
+		handlers:    make(map[string]Handler),
+	}
+}
+
+// Register 注册导出处理方法
+// @receiver m *Manager
+// @param name string
+// @param handler Handler
+func (m *Manager) Register(name string, handler Handler) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.handlers == nil {
+		m.handlers = make(map[string]Handler)
+	}
+	m.handlers[name] = handler
+}
+
+// Enqueue 创建导出任务并加入队列
+// @receiver m *Manager
+// @param ctx context.Context
+// @param handler string 已注册的处理方法名称
+// @param fileName string 下载时的文件名，如订单.xlsx
+// @param owner string 任务所属用户，用于查询及下载时校验权限
+// @param params map[string]string 导出参数（如筛选条件）
+// @return *Job
+// @return error
+func (m *Manager) Enqueue(ctx context.Context, handler, fileName, owner string, params map[string]string) (*Job, error) {
+	m.mu.RLock()
+	_, ok := m.handlers[handler]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("exportjob: handler %q is not registered", handler)
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	job := &Job{
+		ID:        hex.EncodeToString(b),
+		Handler:   handler,
+		FileName:  fileName,
+		Params:    params,
+		Owner:     owner,
+		Status:    StatusPending,
+		CreatedAt: time.Now(),
+	}
+
+	rdb := database.RedisClient()
+	pipe := rdb.TxPipeline()
+	pipe.HSet(ctx, m._key("job", job.ID), m._jobFields(job))
+	pipe.Expire(ctx, m._key("job", job.ID), m.Retention+m.Timeout)
+	pipe.LPush(ctx, m._key("queue"), job.ID)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// Get 获取任务
+// @receiver m *Manager
+// @param ctx context.Context
+// @param id string
+// @return *Job
+// @return error
+func (m *Manager) Get(ctx context.Context, id string) (*Job, error) {
+	values, err := database.RedisClient().HGetAll(ctx, m._key("job", id)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return nil, ErrJobNotFound
+	}
+	return _jobFromFields(id, values), nil
+}
+
+// Cancel 取消未结束的任务，执行中的任务的ctx在1秒内结束
+// @receiver m *Manager
+// @param ctx context.Context
+// @param id string
+// @return error
+func (m *Manager) Cancel(ctx context.Context, id string) error {
+	job, err := m.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	for _, from := range []string{StatusPending, StatusRunning} {
+		ok, err := m._transition(ctx, id, from, StatusCancelled, map[string]interface{}{
+			"finished_at": time.Now().Unix(),
+		})
+		if err != nil {
+			return err
+		}
+		if ok {
+			return m._finished(ctx, id)
+		}
+	}
+	return fmt.Errorf("exportjob: job %s is already %s", id, job.Status)
+}
+
+// SignedURL 生成任务文件的下载地址（带签名及过期时间）；存储实现SignedURLStorage时使用存储的直接下载地址
+// @receiver m *Manager
+// @param ctx context.Context
+// @param job *Job
+// @return string
+// @return error
+func (m *Manager) SignedURL(ctx context.Context, job *Job) (string, error) {
+	if job.Status != StatusSucceeded {
+		return "", fmt.Errorf("exportjob: job %s is %s", job.ID, job.Status)
+	}
+	if s, ok := m.Storage.(SignedURLStorage); ok {
+		return s.SignedURL(ctx, m._fileKey(job), job.FileName, m.LinkTTL)
+	}
+
+	if len(m.Secret) == 0 {
+		return "", ErrEmptySecret
+	}
+	expires := strconv.FormatInt(time.Now().Add(m.LinkTTL).Unix(), 10)
+	query := url.Values{}
+	query.Set("id", job.ID)
+	query.Set("expires", expires)
+	query.Set("sign", m._sign(job.ID, expires))
+	return m.DownloadURL + "?" + query.Encode(), nil
+}
+
+// Verify 校验下载地址的签名及有效期，未设置密钥时返回ErrEmptySecret
+// @receiver m *Manager
+// @param id string
+// @param expires string
+// @param sign string
+// @return error
+func (m *Manager) Verify(id, expires, sign string) error {
+	if len(m.Secret) == 0 {
+		return ErrEmptySecret
+	}
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > unix {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(m._sign(id, expires)), []byte(sign)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// _sign 计算签名
+// @receiver m *Manager
+// @param id string
+// @param expires string
+// @return string
+func (m *Manager) _sign(id, expires string) string {
+	mac := hmac.New(sha256.New, m.Secret)
+	mac.Write([]byte(id + ":" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// _key 生成redis key（含database包配置的key前缀）
+// @receiver m *Manager
+// @param parts ...string
+// @return string
+func (m *Manager) _key(parts ...string) string {
+	key := m.KeyPrefix
+	for _, part := range parts {
+		key += ":" + part
+	}
+	if prefix := database.RedisKeyPrefix(); prefix != "" {
+		key = prefix + ":" + key
+	}
+	return key
+}
+
+// _fileKey 任务文件在存储中的名称
+// @receiver m *Manager
+// @param job *Job
+// @return string
+func (m *Manager) _fileKey(job *Job) string {
+	return job.ID + path.Ext(job.FileName)
+}
+
+// _transitionScript 状态为指定值时修改状态及其他字段
+var _transitionScript = redis.NewScript(`
+if redis.call("HGET", KEYS[1], "status") ~= ARGV[1] then
+	return 0
+end
+redis.call("HSET", KEYS[1], unpack(ARGV, 2))
+return 1
+`)
+
+// _transition 将任务从from状态修改为to状态，同时更新其他字段
+// @receiver m *Manager
+// @param ctx context.Context
+// @param id string
+// @param from string
+// @param to string
+// @param fields map[string]interface{}
+// @return bool 任务当前不是from状态时返回false
+// @return error
+func (m *Manager) _transition(ctx context.Context, id, from, to string, fields map[string]interface{}) (bool, error) {
+	args := []interface{}{from, "status", to}
+	for k, v := range fields {
+		args = append(args, k, v)
+	}
+	n, err := _transitionScript.Run(ctx, database.RedisClient(), []string{m._key("job", id)}, args...).Int()
+	return n == 1, err
+}
+
+// _finished 记录结束的任务，用于过期清理；任务保留到清理之后，使清理时可以获取文件名
+// @receiver m *Manager
+// @param ctx context.Context
+// @param id string
+// @return error
+func (m *Manager) _finished(ctx context.Context, id string) error {
+	pipe := database.RedisClient().TxPipeline()
+	pipe.ZAdd(ctx, m._key("finished"), redis.Z{Score: float64(time.Now().Unix()), Member: id})
+	pipe.Expire(ctx, m._key("job", id), m.Retention+2*m.GCInterval)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// _jobFields 任务保存到redis的字段
+// @receiver m *Manager
+// @param job *Job
+// @return map[string]interface{}
+func (m *Manager) _jobFields(job *Job) map[string]interface{} {
+	params, _ := json.Marshal(job.Params)
+	return map[string]interface{}{
+		"handler":    job.Handler,
+		"file_name":  job.FileName,
+		"params":     string(params),
+		"owner":      job.Owner,
+		"status":     job.Status,
+		"total":      job.Total,
+		"processed":  job.Processed,
+		"size":       job.Size,
+		"error":      job.Error,
+		"created_at": job.CreatedAt.Unix(),
+	}
+}
+
+// _jobFromFields 由redis字段还原任务
+// @param id string
+// @param values map[string]string
+// @return *Job
+func _jobFromFields(id string, values map[string]string) *Job {
+	job := &Job{
+		ID:       id,
+		Handler:  values["handler"],
+		FileName: values["file_name"],
+		Owner:    values["owner"],
+		Status:   values["status"],
+		Error:    values["error"],
+	}
+	_ = json.Unmarshal([]byte(values["params"]), &job.Params)
+	job.Total, _ = strconv.ParseInt(values["total"], 10, 64)
+	job.Processed, _ = strconv.ParseInt(values["processed"], 10, 64)
+	job.Size, _ = strconv.ParseInt(values["size"], 10, 64)
+	if job.Total > 0 {
+		job.Progress = float64(job.Processed*10000/job.Total) / 100
+	}
+	if job.Status == StatusSucceeded {
+		job.Progress = 100
+	}
+
+	unix := func(key string) *time.Time {
+		n, _ := strconv.ParseInt(values[key], 10, 64)
+		if n == 0 {
+			return nil
+		}
+		t := time.Unix(n, 0)
+		return &t
+	}
+	if t := unix("created_at"); t != nil {
+		job.CreatedAt = *t
+	}
+	job.StartedAt = unix("started_at")
+	job.FinishedAt = unix("finished_at")
+	return job
+}
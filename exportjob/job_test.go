@@ -0,0 +1,73 @@
+/**
+ * Created by goland.
+ * User: adam_wang
+ * Date: 2023-10-08 15:42:10
+ */
+
+package exportjob
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestNewManagerRejectsEmptySecret(t *testing.T) {
+	defer func() {
+		if r := recover(); r != ErrEmptySecret {
+			t.Errorf("NewManager with empty secret recovered %v, want ErrEmptySecret", r)
+		}
+	}()
+	NewManager(nil, "")
+}
+
+func TestSignedURLVerify(t *testing.T) {
+	storage, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewManager(storage, "sign-secret")
+	link, err := m.SignedURL(context.Background(), &Job{ID: "job1", Status: StatusSucceeded})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := url.Parse(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := parsed.Query()
+	if parsed.Path != "/export/download" || query.Get("id") != "job1" {
+		t.Fatalf("SignedURL() = %s", link)
+	}
+
+	expired := strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10)
+	other := &Manager{Secret: []byte("other-secret")}
+	tests := []struct {
+		name    string
+		m       *Manager
+		id      string
+		expires string
+		sign    string
+		want    error
+	}{
+		{"valid", m, "job1", query.Get("expires"), query.Get("sign"), nil},
+		{"other job", m, "job2", query.Get("expires"), query.Get("sign"), ErrInvalidSignature},
+		{"tampered sign", m, "job1", query.Get("expires"), query.Get("sign")[1:], ErrInvalidSignature},
+		{"expired", m, "job1", expired, m._sign("job1", expired), ErrInvalidSignature},
+		{"invalid expires", m, "job1", "soon", m._sign("job1", "soon"), ErrInvalidSignature},
+		{"other secret", other, "job1", query.Get("expires"), query.Get("sign"), ErrInvalidSignature},
+		{"empty secret", &Manager{}, "job1", query.Get("expires"), (&Manager{})._sign("job1", query.Get("expires")), ErrEmptySecret},
+	}
+	for _, tt := range tests {
+		if err := tt.m.Verify(tt.id, tt.expires, tt.sign); !errors.Is(err, tt.want) {
+			t.Errorf("%s: Verify() = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	if _, err = (&Manager{Storage: storage}).SignedURL(context.Background(), &Job{ID: "job1", Status: StatusSucceeded}); !errors.Is(err, ErrEmptySecret) {
+		t.Errorf("SignedURL() without secret error = %v, want ErrEmptySecret", err)
+	}
+}
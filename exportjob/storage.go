@@ -0,0 +1,110 @@
+/**
+ * Created by goland.
+ * User: adam_wang
+ * Date: 2023-10-08 16:20:43
+ */
+
+package exportjob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Storage 导出文件存储
+type Storage interface {
+	// Create 创建文件，写入完成后调用Close
+	Create(ctx context.Context, name string) (io.WriteCloser, error)
+	// Open 打开文件，返回的Reader实现io.ReadSeeker时支持断点续传
+	Open(ctx context.Context, name string) (io.ReadCloser, error)
+	// Delete 删除文件，文件不存在时不返回错误
+	Delete(ctx context.Context, name string) error
+}
+
+// SignedURLStorage 支持生成直接下载地址的存储（如对象存储的预签名地址），下载时不经过应用服务器
+type SignedURLStorage interface {
+	Storage
+	// SignedURL 生成有效期为ttl的下载地址
+	SignedURL(ctx context.Context, name, fileName string, ttl time.Duration) (string, error)
+}
+
+// LocalStorage 本地磁盘存储
+type LocalStorage struct {
+	Dir string
+}
+
+// NewLocalStorage 创建本地磁盘存储，目录不存在时自动创建
+// @param dir string
+// @return *LocalStorage
+// @return error
+func NewLocalStorage(dir string) (*LocalStorage, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &LocalStorage{Dir: dir}, nil
+}
+
+// Create 创建文件
+// @receiver s *LocalStorage
+// @param ctx context.Context
+// @param name string
+// @return io.WriteCloser
+// @return error
+func (s *LocalStorage) Create(ctx context.Context, name string) (io.WriteCloser, error) {
+	path, err := s._path(name)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	return os.Create(path)
+}
+
+// Open 打开文件
+// @receiver s *LocalStorage
+// @param ctx context.Context
+// @param name string
+// @return io.ReadCloser
+// @return error
+func (s *LocalStorage) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	path, err := s._path(name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+// Delete 删除文件
+// @receiver s *LocalStorage
+// @param ctx context.Context
+// @param name string
+// @return error
+func (s *LocalStorage) Delete(ctx context.Context, name string) error {
+	path, err := s._path(name)
+	if err != nil {
+		return err
+	}
+	if err = os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// _path 获取文件路径，禁止访问存储目录之外的文件
+// @receiver s *LocalStorage
+// @param name string
+// @return string
+// @return error
+func (s *LocalStorage) _path(name string) (string, error) {
+	clean := filepath.Clean("/" + name)
+	if clean == "/" || strings.Contains(name, "..") {
+		return "", errors.New("exportjob: invalid file name")
+	}
+	return filepath.Join(s.Dir, clean), nil
+}
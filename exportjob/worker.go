@@ -0,0 +1,320 @@
+/**
+ * Created by goland.
+ * User: adam_wang
+ * Date: 2023-10-08 17:05:29
+ */
+
+package exportjob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/adam-qiang/beego-tool/database"
+	"github.com/beego/beego/v2/core/logs"
+	"github.com/redis/go-redis/v9"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Progress 任务进度，定期写入redis并检查任务是否已被取消
+type Progress struct {
+	manager   *Manager
+	job       *Job
+	cancel    context.CancelFunc
+	mu        sync.Mutex
+	total     int64
+	processed int64
+	flushedAt time.Time
+}
+
+// SetTotal 设置总数
+// @receiver p *Progress
+// @param total int64
+func (p *Progress) SetTotal(total int64) {
+	p.mu.Lock()
+	p.total = total
+	p.mu.Unlock()
+	p._flush(false)
+}
+
+// Add 增加已处理的数量
+// @receiver p *Progress
+// @param n int64
+func (p *Progress) Add(n int64) {
+	p.mu.Lock()
+	p.processed += n
+	p.mu.Unlock()
+	p._flush(false)
+}
+
+// _flush 写入进度（force为false时每秒最多写入一次），任务已被取消时结束任务的ctx
+// @receiver p *Progress
+// @param force bool
+func (p *Progress) _flush(force bool) {
+	p.mu.Lock()
+	if !force && time.Since(p.flushedAt) < time.Second {
+		p.mu.Unlock()
+		return
+	}
+	p.flushedAt = time.Now()
+	total, processed := p.total, p.processed
+	p.mu.Unlock()
+
+	ctx := context.Background()
+	ok, err := p.manager._transition(ctx, p.job.ID, StatusRunning, StatusRunning, map[string]interface{}{
+		"total":     total,
+		"processed": processed,
+	})
+	if err == nil && !ok {
+		//状态已不是running（被取消）
+		p.cancel()
+	}
+}
+
+// _watch 每秒检查任务是否已被取消（处理方法未报告进度时也能及时取消），ctx结束时返回
+// @receiver p *Progress
+// @param ctx context.Context
+func (p *Progress) _watch(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p._flush(false)
+		}
+	}
+}
+
+// Start 启动worker及过期文件清理，Stop后停止
+// @receiver m *Manager
+func (m *Manager) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+
+	for i := 0; i < m.Workers; i++ {
+		m.wg.Add(1)
+		go func() {
+			defer m.wg.Done()
+			m._work(ctx)
+		}()
+	}
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		ticker := time.NewTicker(m.GCInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := m.GC(ctx); err != nil && ctx.Err() == nil {
+					logs.Error("exportjob: gc failed: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// Stop 停止worker并等待执行中的任务结束（执行中的任务会被取消）
+// @receiver m *Manager
+func (m *Manager) Stop() {
+	if m.cancel != nil {
+		m.cancel()
+	}
+	m.wg.Wait()
+}
+
+// GC 删除超过保留时间的任务及文件，并将超时未更新的执行中任务标记为失败
+// @receiver m *Manager
+// @param ctx context.Context
+// @return error
+func (m *Manager) GC(ctx context.Context) error {
+	rdb := database.RedisClient()
+	max := strconv.FormatInt(time.Now().Add(-m.Retention).Unix(), 10)
+	ids, err := rdb.ZRangeByScore(ctx, m._key("finished"), &redis.ZRangeBy{Min: "-inf", Max: max}).Result()
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		job, err := m.Get(ctx, id)
+		if err != nil && err != ErrJobNotFound {
+			return err
+		}
+		if job != nil {
+			if err = m.Storage.Delete(ctx, m._fileKey(job)); err != nil {
+				return err
+			}
+		}
+		if err = rdb.Del(ctx, m._key("job", id)).Err(); err != nil {
+			return err
+		}
+		if err = rdb.ZRem(ctx, m._key("finished"), id).Err(); err != nil {
+			return err
+		}
+	}
+
+	//worker进程异常退出时，执行中的任务超过超时时间后标记为失败
+	running, err := rdb.ZRangeByScore(ctx, m._key("running"), &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(time.Now().Add(-m.Timeout-time.Minute).Unix(), 10),
+	}).Result()
+	if err != nil {
+		return err
+	}
+	for _, id := range running {
+		ok, err := m._transition(ctx, id, StatusRunning, StatusFailed, map[string]interface{}{
+			"error":       "worker exited unexpectedly",
+			"finished_at": time.Now().Unix(),
+		})
+		if err != nil {
+			return err
+		}
+		if ok {
+			_ = m._finished(ctx, id)
+		}
+		rdb.ZRem(ctx, m._key("running"), id)
+	}
+	return nil
+}
+
+// _work worker循环：从队列中获取任务并执行
+// @receiver m *Manager
+// @param ctx context.Context
+func (m *Manager) _work(ctx context.Context) {
+	rdb := database.RedisClient()
+	for ctx.Err() == nil {
+		result, err := rdb.BRPop(ctx, 5*time.Second, m._key("queue")).Result()
+		if err != nil {
+			if err != redis.Nil && ctx.Err() == nil {
+				logs.Error("exportjob: failed to pop job: %v", err)
+				time.Sleep(time.Second)
+			}
+			continue
+		}
+		m._run(ctx, result[1])
+	}
+}
+
+// _run 执行任务
+// @receiver m *Manager
+// @param parent context.Context
+// @param id string
+func (m *Manager) _run(parent context.Context, id string) {
+	ok, err := m._transition(parent, id, StatusPending, StatusRunning, map[string]interface{}{
+		"started_at": time.Now().Unix(),
+	})
+	if err != nil {
+		logs.Error("exportjob: failed to start job %s: %v", id, err)
+		return
+	}
+	if !ok {
+		//任务已被取消或已过期
+		return
+	}
+	rdb := database.RedisClient()
+	rdb.ZAdd(parent, m._key("running"), redis.Z{Score: float64(time.Now().Unix()), Member: id})
+	defer rdb.ZRem(context.Background(), m._key("running"), id)
+	//排队时间不计入有效期，避免执行中任务过期
+	rdb.Expire(parent, m._key("job", id), m.Retention+m.Timeout)
+
+	job, err := m.Get(parent, id)
+	if err != nil {
+		logs.Error("exportjob: failed to load job %s: %v", id, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(parent, m.Timeout)
+	defer cancel()
+	progress := &Progress{manager: m, job: job, cancel: cancel}
+	watched := make(chan struct{})
+	go func() {
+		defer close(watched)
+		progress._watch(ctx)
+	}()
+
+	size, err := m._generate(ctx, job, progress)
+	cancel()
+	<-watched
+	progress._flush(true)
+
+	finished := map[string]interface{}{"finished_at": time.Now().Unix()}
+	status := StatusSucceeded
+	if err != nil {
+		_ = m.Storage.Delete(context.Background(), m._fileKey(job))
+		status = StatusFailed
+		if errors.Is(err, context.DeadlineExceeded) {
+			err = fmt.Errorf("timeout after %s", m.Timeout)
+		}
+		finished["error"] = err.Error()
+	} else {
+		finished["size"] = size
+	}
+	if ok, _ = m._transition(context.Background(), id, StatusRunning, status, finished); !ok {
+		//已被取消
+		_ = m.Storage.Delete(context.Background(), m._fileKey(job))
+		return
+	}
+	if err = m._finished(context.Background(), id); err != nil {
+		logs.Error("exportjob: failed to record job %s: %v", id, err)
+	}
+}
+
+// _generate 调用处理方法生成文件
+// @receiver m *Manager
+// @param ctx context.Context
+// @param job *Job
+// @param progress *Progress
+// @return int64 文件大小
+// @return error
+func (m *Manager) _generate(ctx context.Context, job *Job, progress *Progress) (size int64, err error) {
+	m.mu.RLock()
+	handler, ok := m.handlers[job.Handler]
+	m.mu.RUnlock()
+	if !ok {
+		return 0, fmt.Errorf("handler %q is not registered", job.Handler)
+	}
+
+	w, err := m.Storage.Create(ctx, m._fileKey(job))
+	if err != nil {
+		return 0, err
+	}
+	counter := &countWriter{w: w}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+		if closeErr := w.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = ctx.Err()
+		}
+		size = counter.n
+	}()
+
+	return 0, handler(ctx, job, progress, counter)
+}
+
+// countWriter 统计写入的字节数
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+// Write 写入
+// @receiver c *countWriter
+// @param p []byte
+// @return int
+// @return error
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,305 @@
+/**
+ * Created by goland.
+ * User: adam_wang
+ * Date: 2023-09-12 20:31:44
+ */
+
+package tool
+
+import (
+	"fmt"
+	"github.com/adam-qiang/beego-tool/internal/naming"
+	"github.com/beego/beego/v2/client/orm"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// filterOperators 支持的过滤操作符与beego ORM操作符的对应关系
+var filterOperators = map[string]string{
+	"eq":         "exact",
+	"ne":         "ne",
+	"gt":         "gt",
+	"gte":        "gte",
+	"lt":         "lt",
+	"lte":        "lte",
+	"in":         "in",
+	"like":       "icontains",
+	"startswith": "istartswith",
+	"endswith":   "iendswith",
+	"between":    "between",
+	"isnull":     "isnull",
+}
+
+// FilterSortKey 排序参数名，多个字段以逗号分隔，字段前加-表示倒序，如sort=-id,created_at
+var FilterSortKey = "sort"
+
+// filterField 允许过滤或排序的模型字段
+type filterField struct {
+	name      string
+	param     string
+	typ       reflect.Type
+	operators map[string]bool
+	sortable  bool
+}
+
+// QueryFilter 由请求参数解析出的过滤及排序条件
+type QueryFilter struct {
+	Cond  *orm.Condition
+	Sorts []string
+}
+
+// FilterError 过滤参数错误
+type FilterError struct {
+	Param  string
+	Reason string
+}
+
+// Error 错误信息
+// @receiver e *FilterError
+// @return string
+func (e *FilterError) Error() string {
+	return fmt.Sprintf("invalid filter param %s: %s", e.Param, e.Reason)
+}
+
+// Filter 按模型的filter标签解析请求中的过滤及排序参数
+// 标签示例：`filter:"eq,in,sort"`，可选操作符为eq、ne、gt、gte、lt、lte、in、like、startswith、endswith、between、isnull，sort表示允许排序；
+// 参数名为字段的json标签名，未设置时为下划线格式的字段名；请求示例：?status=1&created_at[gte]=2023-01-01&sort=-id
+// @receiver ctx *Context
+// @param model interface{}
+// @return *QueryFilter
+// @return error
+func (ctx *Context) Filter(model interface{}) (*QueryFilter, error) {
+	fields := _filterFields(model)
+	filter := &QueryFilter{Cond: orm.NewCondition()}
+
+	query := ctx.Req.Request.URL.Query()
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := query.Get(key)
+		if key == FilterSortKey {
+			sorts, err := _parseSorts(fields, value)
+			if err != nil {
+				return nil, err
+			}
+			filter.Sorts = sorts
+			continue
+		}
+
+		param, operator := key, "eq"
+		if i := strings.Index(key, "["); i > 0 && strings.HasSuffix(key, "]") {
+			param, operator = key[:i], key[i+1:len(key)-1]
+		}
+		field, ok := fields[param]
+		if !ok {
+			if param != key {
+				return nil, &FilterError{Param: key, Reason: "field is not filterable"}
+			}
+			//非过滤字段的普通参数（如page、size）忽略
+			continue
+		}
+		if _, ok = filterOperators[operator]; !ok || !field.operators[operator] {
+			return nil, &FilterError{Param: key, Reason: "operator " + operator + " is not allowed"}
+		}
+
+		args, err := _filterArgs(field, operator, value)
+		if err != nil {
+			return nil, &FilterError{Param: key, Reason: err.Error()}
+		}
+		expr := field.name + "__" + filterOperators[operator]
+		filter.Cond = filter.Cond.And(expr, args...)
+	}
+
+	return filter, nil
+}
+
+// Apply 将过滤及排序条件应用到QuerySeter
+// @receiver f *QueryFilter
+// @param qs orm.QuerySeter
+// @return orm.QuerySeter
+func (f *QueryFilter) Apply(qs orm.QuerySeter) orm.QuerySeter {
+	if f.Cond != nil && !f.Cond.IsEmpty() {
+		qs = qs.SetCond(f.Cond)
+	}
+	if len(f.Sorts) > 0 {
+		qs = qs.OrderBy(f.Sorts...)
+	}
+	return qs
+}
+
+// _filterFields 解析模型中允许过滤或排序的字段（含匿名嵌入结构体的字段）
+// @param model interface{}
+// @return map[string]*filterField
+func _filterFields(model interface{}) map[string]*filterField {
+	fields := make(map[string]*filterField)
+	typ := reflect.TypeOf(model)
+	for typ != nil && (typ.Kind() == reflect.Ptr || typ.Kind() == reflect.Slice) {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return fields
+	}
+
+	var collect func(t reflect.Type)
+	collect = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+				//未导出类型的嵌入结构体的导出字段同样会被提升
+				collect(sf.Type)
+				continue
+			}
+			if sf.PkgPath != "" {
+				continue
+			}
+			tag := sf.Tag.Get("filter")
+			if tag == "" || tag == "-" {
+				continue
+			}
+
+			field := &filterField{name: sf.Name, param: _jsonName(sf), typ: sf.Type, operators: make(map[string]bool)}
+			for _, operator := range strings.Split(tag, ",") {
+				operator = strings.TrimSpace(operator)
+				if operator == "sort" {
+					field.sortable = true
+				} else if operator != "" {
+					field.operators[operator] = true
+				}
+			}
+			fields[field.param] = field
+		}
+	}
+	collect(typ)
+
+	return fields
+}
+
+// _parseSorts 解析排序参数，只允许标记为sort的字段
+// @param fields map[string]*filterField
+// @param value string
+// @return []string
+// @return error
+func _parseSorts(fields map[string]*filterField, value string) ([]string, error) {
+	var sorts []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		desc := strings.HasPrefix(item, "-")
+		param := strings.TrimPrefix(strings.TrimPrefix(item, "-"), "+")
+		field, ok := fields[param]
+		if !ok || !field.sortable {
+			return nil, &FilterError{Param: FilterSortKey, Reason: "field " + param + " is not sortable"}
+		}
+		if desc {
+			sorts = append(sorts, "-"+field.name)
+		} else {
+			sorts = append(sorts, field.name)
+		}
+	}
+	return sorts, nil
+}
+
+// _filterArgs 按操作符拆分参数值并转换为字段类型
+// @param field *filterField
+// @param operator string
+// @param value string
+// @return []interface{}
+// @return error
+func _filterArgs(field *filterField, operator, value string) ([]interface{}, error) {
+	switch operator {
+	case "isnull":
+		isNull, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("value must be true or false")
+		}
+		return []interface{}{isNull}, nil
+	case "like", "startswith", "endswith":
+		return []interface{}{value}, nil
+	case "in", "between":
+		var args []interface{}
+		for _, item := range strings.Split(value, ",") {
+			arg, err := _convertFilterValue(field.typ, strings.TrimSpace(item))
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+		}
+		if operator == "between" && len(args) != 2 {
+			return nil, fmt.Errorf("between requires two values separated by comma")
+		}
+		return args, nil
+	default:
+		arg, err := _convertFilterValue(field.typ, value)
+		if err != nil {
+			return nil, err
+		}
+		return []interface{}{arg}, nil
+	}
+}
+
+// _convertFilterValue 将字符串参数转换为字段类型
+// @param typ reflect.Type
+// @param value string
+// @return interface{}
+// @return error
+func _convertFilterValue(typ reflect.Type, value string) (interface{}, error) {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == reflect.TypeOf(time.Time{}) {
+		for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02", time.RFC3339} {
+			if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+				return t, nil
+			}
+		}
+		return nil, fmt.Errorf("invalid time %q", value)
+	}
+
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer %q", value)
+		}
+		return n, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid unsigned integer %q", value)
+		}
+		return n, nil
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", value)
+		}
+		return f, nil
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid boolean %q", value)
+		}
+		return b, nil
+	default:
+		return value, nil
+	}
+}
+
+// _jsonName 获取字段的json标签名，未设置时为下划线格式的字段名
+// @param sf reflect.StructField
+// @return string
+func _jsonName(sf reflect.StructField) string {
+	if name := strings.Split(sf.Tag.Get("json"), ",")[0]; name != "" && name != "-" {
+		return name
+	}
+	return naming.SnakeString(sf.Name)
+}
@@ -0,0 +1,122 @@
+/**
+ * Created by goland.
+ * User: adam_wang
+ * Date: 2023-09-12 20:31:44
+ */
+
+package tool
+
+import (
+	"errors"
+	beegoContext "github.com/beego/beego/v2/server/web/context"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type filterTestBase struct {
+	Id int64 `filter:"eq,in,sort"`
+}
+
+type filterTestModel struct {
+	filterTestBase
+	Status    int8       `json:"status" filter:"eq,ne,in"`
+	UserName  string     `filter:"eq,like,sort"`
+	Score     float64    `json:"score" filter:"gte,lte,between"`
+	Enabled   bool       `json:"enabled" filter:"eq"`
+	DeletedAt *time.Time `json:"deleted_at" filter:"isnull"`
+	CreatedAt time.Time  `json:"created_at" filter:"gte,lt,sort"`
+	Remark    string     `json:"remark"`
+}
+
+func TestFilter(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		wantSorts []string
+		wantCond  bool
+		wantErr   string
+	}{
+		{"empty", "", nil, false, ""},
+		{"plain params are ignored", "page=2&size=10&remark=x", nil, false, ""},
+		{"default operator", "status=1", nil, true, ""},
+		{"json name", "score[between]=1,2", nil, true, ""},
+		{"snake case name", "user_name[like]=adam", nil, true, ""},
+		{"embedded field", "id[in]=1,2,3", nil, true, ""},
+		{"sorts", "sort=-id,created_at,+user_name", []string{"-Id", "CreatedAt", "UserName"}, false, ""},
+		{"sort with filter", "status[ne]=0&sort=-created_at", []string{"-CreatedAt"}, true, ""},
+		{"unknown field with operator", "remark[eq]=x", nil, false, "remark[eq]"},
+		{"operator not allowed", "status[gt]=1", nil, false, "status[gt]"},
+		{"unknown operator", "status[regex]=1", nil, false, "status[regex]"},
+		{"invalid integer", "status=abc", nil, false, "status"},
+		{"between needs two values", "score[between]=1", nil, false, "score[between]"},
+		{"invalid isnull", "deleted_at[isnull]=maybe", nil, false, "deleted_at[isnull]"},
+		{"field not sortable", "sort=status", nil, false, "sort"},
+		{"unknown sort field", "sort=-nope", nil, false, "sort"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := beegoContext.NewContext()
+			req.Request = httptest.NewRequest("GET", "/users?"+tt.query, nil)
+			ctx := &Context{Req: req}
+
+			filter, err := ctx.Filter(&filterTestModel{})
+			if tt.wantErr != "" {
+				var filterErr *FilterError
+				if !errors.As(err, &filterErr) || filterErr.Param != tt.wantErr {
+					t.Fatalf("Filter(%q) error = %v, want FilterError for %s", tt.query, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Filter(%q) error = %v", tt.query, err)
+			}
+			if !reflect.DeepEqual(filter.Sorts, tt.wantSorts) {
+				t.Errorf("Filter(%q).Sorts = %q, want %q", tt.query, filter.Sorts, tt.wantSorts)
+			}
+			if got := !filter.Cond.IsEmpty(); got != tt.wantCond {
+				t.Errorf("Filter(%q) has condition = %v, want %v", tt.query, got, tt.wantCond)
+			}
+		})
+	}
+}
+
+func TestFilterArgs(t *testing.T) {
+	fields := _filterFields(filterTestModel{})
+	day := time.Date(2023, 1, 2, 0, 0, 0, 0, time.Local)
+	tests := []struct {
+		param    string
+		operator string
+		value    string
+		want     []interface{}
+		wantErr  bool
+	}{
+		{"status", "eq", "1", []interface{}{int64(1)}, false},
+		{"status", "in", "1, 2,3", []interface{}{int64(1), int64(2), int64(3)}, false},
+		{"status", "in", "1,x", nil, true},
+		{"score", "between", "1.5,2", []interface{}{1.5, 2.0}, false},
+		{"score", "between", "1,2,3", nil, true},
+		{"user_name", "like", "a,b", []interface{}{"a,b"}, false},
+		{"enabled", "eq", "true", []interface{}{true}, false},
+		{"enabled", "eq", "yes", nil, true},
+		{"deleted_at", "isnull", "1", []interface{}{true}, false},
+		{"created_at", "gte", "2023-01-02", []interface{}{day}, false},
+		{"created_at", "gte", "2023-01-02 03:04:05", []interface{}{day.Add(3*time.Hour + 4*time.Minute + 5*time.Second)}, false},
+		{"created_at", "gte", "yesterday", nil, true},
+	}
+	for _, tt := range tests {
+		field, ok := fields[tt.param]
+		if !ok {
+			t.Fatalf("field %s is not filterable", tt.param)
+		}
+		got, err := _filterArgs(field, tt.operator, tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("_filterArgs(%s, %s, %q) error = %v, wantErr %v", tt.param, tt.operator, tt.value, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("_filterArgs(%s, %s, %q) = %v, want %v", tt.param, tt.operator, tt.value, got, tt.want)
+		}
+	}
+}
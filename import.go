@@ -0,0 +1,614 @@
+/**
+ * Created by goland.
+ * User: adam_wang
+ * Date: 2023-10-05 14:52:08
+ */
+
+package tool
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"github.com/tealeg/xlsx/v3"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"io"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ImportOptions 导入配置
+type ImportOptions struct {
+	Field   string // 上传文件的表单字段名，默认为file
+	Comma   rune   // CSV分隔符，默认为逗号
+	Sheet   string // excel工作表名称，默认为第一个工作表
+	MaxRows int    // 最大数据行数，为0时不限制
+	Locale  string // 错误提示的语言，默认为DefaultLocale，ctx.Import未设置时使用当前请求的语言
+}
+
+// ImportError 导入错误（行号、列号从1开始，行号含表头行）
+type ImportError struct {
+	Row     int    // 行号
+	Column  int    // 列号，为0时表示整行的错误
+	Title   string // 表头
+	Field   string // 结构体字段名
+	Value   string // 单元格的值
+	Message string // 错误信息
+}
+
+// Error 错误信息
+// @receiver e ImportError
+// @return string
+func (e ImportError) Error() string {
+	if e.Column == 0 {
+		return fmt.Sprintf("row %d: %s", e.Row, e.Message)
+	}
+	return fmt.Sprintf("row %d column %d: %s", e.Row, e.Column, e.Message)
+}
+
+// ImportResult 导入结果，list中只包含校验通过的行
+type ImportResult struct {
+	Header []string      // 文件中的表头
+	Total  int           // 数据行数（不含空行）
+	Valid  int           // 校验通过的行数
+	Rows   []int         // list中每条数据对应的行号
+	Errors []ImportError // 错误
+
+	records [][]string
+}
+
+// HasErrors 是否存在错误
+// @receiver r *ImportResult
+// @return bool
+func (r *ImportResult) HasErrors() bool {
+	return len(r.Errors) > 0
+}
+
+// Import 解析请求中上传的CSV或excel文件（按扩展名识别）到模型切片，并逐行校验
+// 表头与字段按import标签（未设置时使用export标签）的title、aliases、name或字段名匹配；
+// 校验使用beego validation（valid标签），错误信息中的字段名替换为表头
+// @receiver ctx *Context
+// @param list interface{} 模型切片指针，如*[]*Order
+// @param opts ImportOptions
+// @return *ImportResult
+// @return error 文件无法读取或格式错误时返回，逐行的错误记录在ImportResult.Errors中
+func (ctx *Context) Import(list interface{}, opts ImportOptions) (*ImportResult, error) {
+	if opts.Field == "" {
+		opts.Field = "file"
+	}
+	if opts.Locale == "" {
+		opts.Locale = ctx.Locale()
+	}
+	file, header, err := ctx.Req.Request.FormFile(opts.Field)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ImportFile(file, header.Filename, list, opts)
+}
+
+// ImportFile 按文件扩展名解析CSV或excel文件
+// @param r io.Reader
+// @param fileName string
+// @param list interface{} 模型切片指针
+// @param opts ImportOptions
+// @return *ImportResult
+// @return error
+func ImportFile(r io.Reader, fileName string, list interface{}, opts ImportOptions) (*ImportResult, error) {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".csv", ".txt":
+		return ImportCsv(r, list, opts)
+	case ".xlsx":
+		return ImportExcel(r, list, opts)
+	default:
+		return nil, fmt.Errorf("import: unsupported file type %q", filepath.Ext(fileName))
+	}
+}
+
+// ImportCsv 解析CSV文件，自动识别UTF-8（含BOM）及GBK编码
+// @param r io.Reader
+// @param list interface{} 模型切片指针
+// @param opts ImportOptions
+// @return *ImportResult
+// @return error
+func ImportCsv(r io.Reader, list interface{}, opts ImportOptions) (*ImportResult, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimPrefix(data, []byte("\xEF\xBB\xBF"))
+	if !utf8.Valid(data) {
+		//GB18030兼容GBK
+		if data, err = simplifiedchinese.GB18030.NewDecoder().Bytes(data); err != nil {
+			return nil, fmt.Errorf("import: unknown csv encoding: %w", err)
+		}
+	}
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	if opts.Comma != 0 {
+		reader.Comma = opts.Comma
+	}
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("import: %w", err)
+	}
+	return ImportRecords(records, list, opts)
+}
+
+// ImportExcel 解析excel（xlsx）文件
+// @param r io.Reader
+// @param list interface{} 模型切片指针
+// @param opts ImportOptions
+// @return *ImportResult
+// @return error
+func ImportExcel(r io.Reader, list interface{}, opts ImportOptions) (*ImportResult, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	file, err := xlsx.OpenBinary(data)
+	if err != nil {
+		return nil, fmt.Errorf("import: %w", err)
+	}
+	if len(file.Sheets) == 0 {
+		return nil, errors.New("import: workbook has no sheet")
+	}
+	sheet := file.Sheets[0]
+	if opts.Sheet != "" {
+		var ok bool
+		if sheet, ok = file.Sheet[opts.Sheet]; !ok {
+			return nil, fmt.Errorf("import: sheet %q not found", opts.Sheet)
+		}
+	}
+
+	var records [][]string
+	err = sheet.ForEachRow(func(row *xlsx.Row) error {
+		record := make([]string, 0, sheet.MaxCol)
+		err := row.ForEachCell(func(cell *xlsx.Cell) error {
+			record = append(record, strings.TrimSpace(_excelCellValue(cell, file.Date1904)))
+			return nil
+		})
+		//去除末尾的空单元格
+		for len(record) > 0 && record[len(record)-1] == "" {
+			record = record[:len(record)-1]
+		}
+		records = append(records, record)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("import: %w", err)
+	}
+	//去除末尾的空行
+	for len(records) > 0 && len(records[len(records)-1]) == 0 {
+		records = records[:len(records)-1]
+	}
+	return ImportRecords(records, list, opts)
+}
+
+// _excelCellValue 获取单元格的文本，日期时间单元格按2006-01-02（无时间部分时）或2006-01-02 15:04:05格式化，
+// 不使用单元格的显示格式（如格式14显示为01-02-06），保证能被导入的时间字段解析
+// @param cell *xlsx.Cell
+// @param date1904 bool 工作簿是否使用1904日期系统
+// @return string
+func _excelCellValue(cell *xlsx.Cell, date1904 bool) string {
+	if cell.IsTime() {
+		if t, err := cell.GetTime(date1904); err == nil {
+			//Excel的日期时间没有时区，TimeFromExcelTime返回的UTC时间即单元格显示的时间
+			if t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 && t.Nanosecond() == 0 {
+				return t.Format("2006-01-02")
+			}
+			return t.Round(time.Second).Format("2006-01-02 15:04:05")
+		}
+	}
+	value, err := cell.FormattedValue()
+	if err != nil {
+		value = cell.Value
+	}
+	return value
+}
+
+// ImportRecords 将表格数据（第一行为表头）解析到模型切片并逐行校验，全部单元格为空的行跳过（不计入Total）
+// @param records [][]string
+// @param list interface{} 模型切片指针
+// @param opts ImportOptions
+// @return *ImportResult
+// @return error
+func ImportRecords(records [][]string, list interface{}, opts ImportOptions) (*ImportResult, error) {
+	listValue := reflect.ValueOf(list)
+	if listValue.Kind() != reflect.Ptr || listValue.Elem().Kind() != reflect.Slice {
+		return nil, errors.New("import: list must be a pointer to slice")
+	}
+	listValue = listValue.Elem()
+	elemType := listValue.Type().Elem()
+	structType := elemType
+	if structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+
+	columns, err := _parseColumns(reflect.New(structType).Interface(), []string{"import", "export"})
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, errors.New("import: missing header row")
+	}
+	result := &ImportResult{Header: records[0], records: records}
+	for _, record := range records[1:] {
+		if !_blankRecord(record) {
+			result.Total++
+		}
+	}
+	if opts.MaxRows > 0 && result.Total > opts.MaxRows {
+		return nil, fmt.Errorf("import: too many rows, max %d", opts.MaxRows)
+	}
+
+	//表头与列的对应关系
+	mapping := make([]*ExportColumn, len(result.Header))
+	matched := 0
+	for i, title := range result.Header {
+		title = strings.TrimSpace(title)
+		for j := range columns {
+			if _matchImportColumn(&columns[j], title) {
+				mapping[i] = &columns[j]
+				matched++
+				break
+			}
+		}
+	}
+	if matched == 0 {
+		return nil, errors.New("import: no column in header matches the model")
+	}
+
+	if opts.Locale == "" {
+		opts.Locale = DefaultLocale
+	}
+	catalog := _validCatalog(opts.Locale)
+	for i, record := range records[1:] {
+		rowNum := i + 2
+		if _blankRecord(record) {
+			continue
+		}
+		item := reflect.New(structType)
+		var rowErrors []ImportError
+		for j, value := range record {
+			if j >= len(mapping) || mapping[j] == nil {
+				continue
+			}
+			if err = _setImportValue(item.Elem(), mapping[j], value); err != nil {
+				message := err.Error()
+				if valueErr, ok := err.(*importValueError); ok {
+					message, _ = catalog.message(valueErr.rule, valueErr.params)
+				}
+				rowErrors = append(rowErrors, ImportError{
+					Row:     rowNum,
+					Column:  j + 1,
+					Title:   result.Header[j],
+					Field:   mapping[j].field,
+					Value:   value,
+					Message: result.Header[j] + " " + message,
+				})
+			}
+		}
+
+		if len(rowErrors) == 0 {
+			rowErrors = _validImportRow(item.Interface(), rowNum, record, result.Header, mapping, opts.Locale)
+		}
+		if len(rowErrors) > 0 {
+			result.Errors = append(result.Errors, rowErrors...)
+			continue
+		}
+
+		if elemType.Kind() == reflect.Ptr {
+			listValue.Set(reflect.Append(listValue, item))
+		} else {
+			listValue.Set(reflect.Append(listValue, item.Elem()))
+		}
+		result.Rows = append(result.Rows, rowNum)
+		result.Valid++
+	}
+	return result, nil
+}
+
+// _blankRecord 是否为全部单元格都为空的行
+// @param record []string
+// @return bool
+func _blankRecord(record []string) bool {
+	for _, value := range record {
+		if strings.TrimSpace(value) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// ErrorReport 生成错误报告，包含所有出错的行及错误信息，出错的单元格标红
+// @receiver r *ImportResult
+// @return *ExcelBuilder
+func (r *ImportResult) ErrorReport() *ExcelBuilder {
+	builder := NewExcelBuilder()
+	columns := []ExcelColumn{{Title: "行号"}}
+	for _, title := range r.Header {
+		columns = append(columns, ExcelColumn{Title: title})
+	}
+	columns = append(columns, ExcelColumn{Title: "错误信息"})
+	sheet := builder.Sheet("导入错误").Columns(columns...)
+
+	errorsByRow := make(map[int][]ImportError)
+	var rows []int
+	for _, e := range r.Errors {
+		if _, ok := errorsByRow[e.Row]; !ok {
+			rows = append(rows, e.Row)
+		}
+		errorsByRow[e.Row] = append(errorsByRow[e.Row], e)
+	}
+
+	for _, rowNum := range rows {
+		values := make([]interface{}, len(columns))
+		values[0] = rowNum
+		if rowNum-1 < len(r.records) {
+			for i, value := range r.records[rowNum-1] {
+				if i < len(r.Header) {
+					values[i+1] = value
+				}
+			}
+		}
+		messages := make([]string, 0, len(errorsByRow[rowNum]))
+		for _, e := range errorsByRow[rowNum] {
+			messages = append(messages, e.Message)
+		}
+		values[len(values)-1] = strings.Join(messages, "；")
+		sheet.AddRow(values...)
+
+		for _, e := range errorsByRow[rowNum] {
+			if e.Column > 0 {
+				if cell := sheet._cell(sheet.Rows()-1, e.Column); cell != nil {
+					cell.SetStyle(sheet._fillStyle(xlsx.RGB_Light_Red))
+				}
+			}
+		}
+	}
+	return builder
+}
+
+// _takePreviewScript 读取预览数据并删除，保证每个token只能被读取一次
+var _takePreviewScript = redis.NewScript(`local v = redis.call("GET", KEYS[1]) if v then redis.call("DEL", KEYS[1]) end return v`)
+
+// SaveImportPreview 保存预览的导入数据，返回用于确认导入的token
+// @param rdb redis.Cmdable 如database.RedisClient()
+// @param list interface{}
+// @param ttl time.Duration
+// @return string
+// @return error
+func SaveImportPreview(rdb redis.Cmdable, list interface{}, ttl time.Duration) (string, error) {
+	data, err := json.Marshal(list)
+	if err != nil {
+		return "", err
+	}
+	b := make([]byte, 16)
+	if _, err = rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+	if err = rdb.Set(context.Background(), "import_preview:"+token, data, ttl).Err(); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// LoadImportPreview 读取预览的导入数据，读取与删除在同一个脚本中原子执行，并发确认同一个token时只有一个能读取到
+// @param rdb redis.Cmdable
+// @param token string
+// @param list interface{} 模型切片指针
+// @return error
+func LoadImportPreview(rdb redis.Cmdable, token string, list interface{}) error {
+	data, err := _takePreviewScript.Run(context.Background(), rdb, []string{"import_preview:" + token}).Text()
+	if err == redis.Nil {
+		return errors.New("import: preview not found, expired or already confirmed")
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(data), list)
+}
+
+// _matchImportColumn 表头是否与列匹配
+// @param column *ExportColumn
+// @param title string
+// @return bool
+func _matchImportColumn(column *ExportColumn, title string) bool {
+	if title == column.Title || title == column.Name || title == column.field {
+		return true
+	}
+	for _, alias := range column.Aliases {
+		if title == strings.TrimSpace(alias) {
+			return true
+		}
+	}
+	return false
+}
+
+// importMessages 单元格的值无法转换为字段类型时的提示，注册到各语言包
+var importMessages = map[string]map[string]string{
+	"zh-CN": {
+		"ImportTime":   "必须是有效的时间格式",
+		"ImportFormat": "格式不正确",
+		"ImportBool":   "必须是有效的布尔值",
+		"ImportInt":    "必须是有效的整数",
+		"ImportUint":   "必须是有效的非负整数",
+		"ImportFloat":  "必须是有效的数字",
+		"ImportType":   "不支持的字段类型%s",
+	},
+	"en-US": {
+		"ImportTime":   "must be a valid time",
+		"ImportFormat": "has an invalid format",
+		"ImportBool":   "must be a valid boolean",
+		"ImportInt":    "must be a valid integer",
+		"ImportUint":   "must be a valid non-negative integer",
+		"ImportFloat":  "must be a valid number",
+		"ImportType":   "has an unsupported field type %s",
+	},
+}
+
+func init() {
+	for locale, messages := range importMessages {
+		RegisterValidCatalog(locale, ValidCatalog{Messages: messages})
+	}
+}
+
+// importValueError 单元格的值无法转换为字段类型，rule为语言包中提示的key
+type importValueError struct {
+	rule   string
+	params []interface{}
+}
+
+// Error 错误信息
+// @receiver e *importValueError
+// @return string
+func (e *importValueError) Error() string {
+	return e.rule
+}
+
+// _setImportValue 将单元格的值转换为字段类型并赋值
+// @param item reflect.Value
+// @param column *ExportColumn
+// @param value string
+// @return error
+func _setImportValue(item reflect.Value, column *ExportColumn, value string) error {
+	value = strings.TrimSpace(value)
+	field := item
+	for _, i := range column.index {
+		if field.Kind() == reflect.Ptr {
+			if field.IsNil() {
+				field.Set(reflect.New(field.Type().Elem()))
+			}
+			field = field.Elem()
+		}
+		field = field.Field(i)
+	}
+	if value == "" {
+		return nil
+	}
+	if field.Kind() == reflect.Ptr {
+		field.Set(reflect.New(field.Type().Elem()))
+		field = field.Elem()
+	}
+
+	//枚举及布尔值按显示文本反向转换
+	for key, text := range column.Enum {
+		if value == text {
+			value = key
+			break
+		}
+	}
+	if column.hasBool && field.Kind() == reflect.Bool {
+		switch value {
+		case column.Bool[0]:
+			value = "true"
+		case column.Bool[1]:
+			value = "false"
+		}
+	}
+
+	if field.Type() == reflect.TypeOf(time.Time{}) {
+		layouts := []string{"2006-01-02 15:04:05", "2006-01-02", "2006/01/02 15:04:05", "2006/01/02", time.RFC3339}
+		if column.Format != "" {
+			layouts = append([]string{column.Format}, layouts...)
+		}
+		for _, layout := range layouts {
+			if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+				field.Set(reflect.ValueOf(t))
+				return nil
+			}
+		}
+		return &importValueError{rule: "ImportTime"}
+	}
+
+	if field.CanAddr() {
+		if u, ok := field.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			if err := u.UnmarshalText([]byte(value)); err != nil {
+				return &importValueError{rule: "ImportFormat"}
+			}
+			return nil
+		}
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return &importValueError{rule: "ImportBool"}
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(strings.ReplaceAll(value, ",", ""), 10, field.Type().Bits())
+		if err != nil {
+			return &importValueError{rule: "ImportInt"}
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(strings.ReplaceAll(value, ",", ""), 10, field.Type().Bits())
+		if err != nil {
+			return &importValueError{rule: "ImportUint"}
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(strings.ReplaceAll(value, ",", ""), field.Type().Bits())
+		if err != nil {
+			return &importValueError{rule: "ImportFloat"}
+		}
+		field.SetFloat(f)
+	default:
+		return &importValueError{rule: "ImportType", params: []interface{}{field.Type().String()}}
+	}
+	return nil
+}
+
+// _validImportRow 校验一行数据（同ValidateAllLocale，支持rule标签、嵌套结构体及语言包）
+// @param item interface{}
+// @param rowNum int
+// @param record []string
+// @param header []string
+// @param mapping []*ExportColumn
+// @param locale string
+// @return []ImportError
+func _validImportRow(item interface{}, rowNum int, record, header []string, mapping []*ExportColumn, locale string) []ImportError {
+	errs, err := ValidateAllLocale(item, locale)
+	if err != nil {
+		return []ImportError{{Row: rowNum, Message: err.Error()}}
+	}
+
+	var rowErrors []ImportError
+	for _, e := range errs {
+		importErr := ImportError{Row: rowNum, Field: e.Field, Message: e.Message}
+		//顶层字段的错误对应到列，提示中的别名替换为表头
+		for j, column := range mapping {
+			if column != nil && len(column.index) == 1 && column.field == e.Field && e.Path == e.Name {
+				importErr.Column = j + 1
+				importErr.Title = header[j]
+				if j < len(record) {
+					importErr.Value = record[j]
+				}
+				importErr.Message = strings.Replace(e.Message, e.Alias, header[j], 1)
+				break
+			}
+		}
+		rowErrors = append(rowErrors, importErr)
+	}
+	return rowErrors
+}
@@ -0,0 +1,152 @@
+/**
+ * Created by goland.
+ * User: adam_wang
+ * Date: 2023-10-05 14:52:08
+ */
+
+package tool
+
+import (
+	"bytes"
+	"github.com/tealeg/xlsx/v3"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type importTestUser struct {
+	Name     string    `export:"title=姓名"`
+	Birthday time.Time `export:"title=生日;format=2006年01月02日"`
+	LoginAt  time.Time `export:"title=登录时间"`
+	Remark   string    `export:"title=备注"`
+}
+
+func TestImportRecordsDateColumn(t *testing.T) {
+	day := time.Date(2023, 10, 5, 0, 0, 0, 0, time.Local)
+	loginAt := time.Date(2023, 10, 5, 14, 52, 8, 0, time.Local)
+	tests := []struct {
+		name       string
+		records    [][]string
+		want       []importTestUser
+		wantRows   []int
+		wantTotal  int
+		wantErrors []ImportError
+	}{
+		{
+			name: "date layouts",
+			records: [][]string{
+				{"姓名", "生日", "登录时间"},
+				{"a", "2023-10-05", "2023-10-05 14:52:08"},
+				{"b", "2023/10/05", "2023/10/05 14:52:08"},
+				{"c", "2023年10月05日", ""},
+			},
+			want: []importTestUser{
+				{Name: "a", Birthday: day, LoginAt: loginAt},
+				{Name: "b", Birthday: day, LoginAt: loginAt},
+				{Name: "c", Birthday: day},
+			},
+			wantRows:  []int{2, 3, 4},
+			wantTotal: 3,
+		},
+		{
+			name: "invalid date",
+			records: [][]string{
+				{"姓名", "生日"},
+				{"a", "10-05-23"},
+				{"b", "2023-10-05"},
+			},
+			want:       []importTestUser{{Name: "b", Birthday: day}},
+			wantRows:   []int{3},
+			wantTotal:  2,
+			wantErrors: []ImportError{{Row: 2, Column: 2, Title: "生日", Field: "Birthday", Value: "10-05-23"}},
+		},
+		{
+			name: "blank rows skipped",
+			records: [][]string{
+				{"姓名", "生日"},
+				{"", " "},
+				{"a", "2023-10-05"},
+				{},
+			},
+			want:      []importTestUser{{Name: "a", Birthday: day}},
+			wantRows:  []int{3},
+			wantTotal: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var list []importTestUser
+			result, err := ImportRecords(tt.records, &list, ImportOptions{Locale: "en-US"})
+			if err != nil {
+				t.Fatalf("ImportRecords() error = %v", err)
+			}
+			if !reflect.DeepEqual(list, tt.want) {
+				t.Errorf("list = %+v, want %+v", list, tt.want)
+			}
+			if !reflect.DeepEqual(result.Rows, tt.wantRows) || result.Total != tt.wantTotal || result.Valid != len(tt.want) {
+				t.Errorf("rows = %v, total = %d, valid = %d, want %v, %d, %d",
+					result.Rows, result.Total, result.Valid, tt.wantRows, tt.wantTotal, len(tt.want))
+			}
+			if len(result.Errors) != len(tt.wantErrors) {
+				t.Fatalf("errors = %v, want %d errors", result.Errors, len(tt.wantErrors))
+			}
+			for i, want := range tt.wantErrors {
+				got := result.Errors[i]
+				got.Message = ""
+				if got != want {
+					t.Errorf("error %d = %+v, want %+v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestImportExcelDateCells(t *testing.T) {
+	file := xlsx.NewFile()
+	sheet, err := file.AddSheet("Sheet1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := sheet.AddRow()
+	for _, title := range []string{"姓名", "生日", "登录时间", "备注"} {
+		header.AddCell().SetString(title)
+	}
+	row := sheet.AddRow()
+	row.AddCell().SetString("a")
+	//格式14（mm-dd-yy）按显示格式读取会得到10-05-23
+	row.AddCell().SetDateTimeWithFormat(xlsx.TimeToExcelTime(time.Date(2023, 10, 5, 0, 0, 0, 0, time.UTC), false), "mm-dd-yy")
+	row.AddCell().SetDateTimeWithFormat(xlsx.TimeToExcelTime(time.Date(2023, 10, 5, 14, 52, 8, 0, time.UTC), false), "m/d/yy h:mm")
+	row.AddCell().SetDateTimeWithFormat(xlsx.TimeToExcelTime(time.Date(2023, 10, 5, 0, 0, 0, 0, time.UTC), false), "mm-dd-yy")
+	sheet.AddRow().AddCell().SetString("")
+	last := sheet.AddRow()
+	last.AddCell().SetString("b")
+	last.AddCell().SetString("2023-10-06")
+
+	var buf bytes.Buffer
+	if err = file.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var list []importTestUser
+	result, err := ImportExcel(&buf, &list, ImportOptions{})
+	if err != nil {
+		t.Fatalf("ImportExcel() error = %v", err)
+	}
+	if result.HasErrors() {
+		t.Fatalf("ImportExcel() errors = %v", result.Errors)
+	}
+	want := []importTestUser{
+		{
+			Name:     "a",
+			Birthday: time.Date(2023, 10, 5, 0, 0, 0, 0, time.Local),
+			LoginAt:  time.Date(2023, 10, 5, 14, 52, 8, 0, time.Local),
+			Remark:   "2023-10-05",
+		},
+		{Name: "b", Birthday: time.Date(2023, 10, 6, 0, 0, 0, 0, time.Local)},
+	}
+	if !reflect.DeepEqual(list, want) {
+		t.Errorf("list = %+v, want %+v", list, want)
+	}
+	if !reflect.DeepEqual(result.Rows, []int{2, 4}) || result.Total != 2 {
+		t.Errorf("rows = %v, total = %d, want [2 4], 2", result.Rows, result.Total)
+	}
+}
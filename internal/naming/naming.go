@@ -0,0 +1,29 @@
+/**
+ * Created by goland.
+ * User: adam_wang
+ * Date: 2023-09-02 16:25:18
+ */
+
+// Package naming 提供tool及database包共用的命名转换规则
+package naming
+
+import "strings"
+
+// SnakeString 驼峰转下划线，XxYy转为xx_yy（与beego ORM默认规则一致）
+// @param s string
+// @return string
+func SnakeString(s string) string {
+	data := make([]byte, 0, len(s)*2)
+	j := false
+	for i := 0; i < len(s); i++ {
+		d := s[i]
+		if i > 0 && d >= 'A' && d <= 'Z' && j {
+			data = append(data, '_')
+		}
+		if d != '_' {
+			j = true
+		}
+		data = append(data, d)
+	}
+	return strings.ToLower(string(data))
+}
@@ -0,0 +1,329 @@
+/**
+ * Created by goland.
+ * User: adam_wang
+ * Date: 2023-09-05 22:14:30
+ */
+
+package tool
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/beego/beego/v2/client/orm"
+	"math"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PageOptions 分页参数配置
+type PageOptions struct {
+	PageKey     string // 页码参数名，默认为page
+	SizeKey     string // 每页条数参数名，默认为size
+	CursorKey   string // 游标参数名，默认为cursor
+	DefaultSize int    // 默认每页条数，默认为20
+	MaxSize     int    // 每页最大条数，默认为100
+	MaxPage     int    // 最大页码，超出时按最大页码处理，默认为偏移量不超过MaxInt32的最大页码
+}
+
+// DefaultPageOptions 默认分页参数配置
+var DefaultPageOptions = PageOptions{
+	PageKey:     "page",
+	SizeKey:     "size",
+	CursorKey:   "cursor",
+	DefaultSize: 20,
+	MaxSize:     100,
+}
+
+// Pagination 分页参数
+type Pagination struct {
+	Page int
+	Size int
+}
+
+// PageResult 分页响应结构体
+type PageResult struct {
+	List  interface{} `json:"list"`
+	Total int64       `json:"total"`
+	Page  int         `json:"page"`
+	Size  int         `json:"size"`
+	Pages int64       `json:"pages"`
+}
+
+// CursorPagination 游标（keyset）分页参数，适用于数据量大的表
+type CursorPagination struct {
+	Field  string // 排序字段（需唯一且有索引，如Id）
+	Desc   bool   // 是否倒序
+	Size   int
+	Cursor string // 上一页返回的游标，为空时表示第一页
+}
+
+// CursorPageResult 游标分页响应结构体
+type CursorPageResult struct {
+	List       interface{} `json:"list"`
+	Size       int         `json:"size"`
+	NextCursor string      `json:"next_cursor"`
+	HasMore    bool        `json:"has_more"`
+}
+
+// Pagination 从请求参数中解析分页参数
+// @receiver ctx *Context
+// @return *Pagination
+func (ctx *Context) Pagination() *Pagination {
+	return ctx.PaginationWithOptions(DefaultPageOptions)
+}
+
+// PaginationWithOptions 按指定配置从请求参数中解析分页参数
+// @receiver ctx *Context
+// @param opts PageOptions
+// @return *Pagination
+func (ctx *Context) PaginationWithOptions(opts PageOptions) *Pagination {
+	opts = opts.withDefaults()
+
+	page, _ := strconv.Atoi(ctx.Query(opts.PageKey))
+	if page < 1 {
+		page = 1
+	}
+	if page > opts.MaxPage {
+		page = opts.MaxPage
+	}
+	return &Pagination{Page: page, Size: opts.size(ctx.Query(opts.SizeKey))}
+}
+
+// CursorPagination 从请求参数中解析游标分页参数
+// @receiver ctx *Context
+// @param field string
+// @param desc bool
+// @return *CursorPagination
+func (ctx *Context) CursorPagination(field string, desc bool) *CursorPagination {
+	opts := DefaultPageOptions.withDefaults()
+	return &CursorPagination{
+		Field:  field,
+		Desc:   desc,
+		Size:   opts.size(ctx.Query(opts.SizeKey)),
+		Cursor: ctx.Query(opts.CursorKey),
+	}
+}
+
+// OtuPutPage 以ReturnMsg结构输出分页数据
+// @receiver ctx *Context
+// @param data interface{} *PageResult或*CursorPageResult
+func (ctx *Context) OtuPutPage(data interface{}) {
+	ctx.OtuPutJson(http.StatusOK, ReturnMsg{Code: http.StatusOK, Msg: "success", Data: data})
+}
+
+// Offset 获取偏移量，页码过大时偏移量不超过MaxInt32
+// @receiver p *Pagination
+// @return int
+func (p *Pagination) Offset() int {
+	if p.Page < 1 || p.Size < 1 {
+		return 0
+	}
+	if p.Page-1 > math.MaxInt32/p.Size {
+		return math.MaxInt32
+	}
+	return (p.Page - 1) * p.Size
+}
+
+// QuerySeter 对QuerySeter执行分页查询并统计总数
+// @receiver p *Pagination
+// @param qs orm.QuerySeter
+// @param container interface{} 结果切片的指针
+// @return *PageResult
+// @return error
+func (p *Pagination) QuerySeter(qs orm.QuerySeter, container interface{}) (*PageResult, error) {
+	total, err := qs.Count()
+	if err != nil {
+		return nil, err
+	}
+	if total > int64(p.Offset()) {
+		if _, err = qs.Limit(p.Size, p.Offset()).All(container); err != nil {
+			return nil, err
+		}
+	}
+	return p.result(container, total), nil
+}
+
+// Raw 对原生SQL执行分页查询并统计总数
+// @receiver p *Pagination
+// @param o orm.QueryExecutor
+// @param query string 不含LIMIT的查询语句
+// @param args []interface{}
+// @param container interface{} 结果切片的指针
+// @return *PageResult
+// @return error
+func (p *Pagination) Raw(o orm.QueryExecutor, query string, args []interface{}, container interface{}) (*PageResult, error) {
+	query = strings.TrimRight(strings.TrimSpace(query), ";")
+
+	var total int64
+	err := o.Raw("SELECT COUNT(*) FROM ("+query+") AS _page_count", args...).QueryRow(&total)
+	if err != nil {
+		return nil, err
+	}
+	if total > int64(p.Offset()) {
+		pageArgs := append(append([]interface{}{}, args...), p.Size, p.Offset())
+		if _, err = o.Raw(query+" LIMIT ? OFFSET ?", pageArgs...).QueryRows(container); err != nil {
+			return nil, err
+		}
+	}
+	return p.result(container, total), nil
+}
+
+// result 生成分页响应
+// @receiver p *Pagination
+// @param container interface{}
+// @param total int64
+// @return *PageResult
+func (p *Pagination) result(container interface{}, total int64) *PageResult {
+	pages := total / int64(p.Size)
+	if total%int64(p.Size) != 0 {
+		pages++
+	}
+	return &PageResult{List: _emptySlice(container), Total: total, Page: p.Page, Size: p.Size, Pages: pages}
+}
+
+// QuerySeter 对QuerySeter执行游标分页查询
+// @receiver c *CursorPagination
+// @param qs orm.QuerySeter
+// @param container interface{} 结果切片的指针
+// @return *CursorPageResult
+// @return error
+func (c *CursorPagination) QuerySeter(qs orm.QuerySeter, container interface{}) (*CursorPageResult, error) {
+	if c.Field == "" {
+		return nil, errors.New("cursor pagination: field is required")
+	}
+	slice := reflect.ValueOf(container)
+	if slice.Kind() != reflect.Ptr || slice.Elem().Kind() != reflect.Slice {
+		return nil, errors.New("cursor pagination: container must be a pointer to slice")
+	}
+
+	operator, order := "__gt", c.Field
+	if c.Desc {
+		operator, order = "__lt", "-"+c.Field
+	}
+	if c.Cursor != "" {
+		value, err := DecodeCursor(c.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		qs = qs.Filter(c.Field+operator, value)
+	}
+	if _, err := qs.OrderBy(order).Limit(c.Size + 1).All(container); err != nil {
+		return nil, err
+	}
+
+	result := &CursorPageResult{Size: c.Size}
+	list := slice.Elem()
+	if list.Len() > c.Size {
+		list.Set(list.Slice(0, c.Size))
+		result.HasMore = true
+	}
+	if result.HasMore {
+		last := reflect.Indirect(list.Index(list.Len() - 1))
+		field := last.FieldByNameFunc(func(name string) bool {
+			return strings.EqualFold(name, strings.ReplaceAll(c.Field, "_", ""))
+		})
+		if !field.IsValid() {
+			return nil, fmt.Errorf("cursor pagination: field %q not found in result", c.Field)
+		}
+		result.NextCursor = EncodeCursor(field.Interface())
+	}
+	result.List = _emptySlice(container)
+
+	return result, nil
+}
+
+// EncodeCursor 将排序字段的值编码为不透明的游标
+// @param value interface{}
+// @return string
+func EncodeCursor(value interface{}) string {
+	var raw string
+	switch v := value.(type) {
+	case time.Time:
+		raw = v.Format("2006-01-02 15:04:05.999999")
+	case *time.Time:
+		raw = v.Format("2006-01-02 15:04:05.999999")
+	default:
+		raw = fmt.Sprint(value)
+	}
+	data, _ := json.Marshal(map[string]string{"v": raw})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor 解码游标
+// @param cursor string
+// @return string
+// @return error
+func DecodeCursor(cursor string) (string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", errors.New("cursor pagination: invalid cursor")
+	}
+	var payload map[string]string
+	if err = json.Unmarshal(data, &payload); err != nil {
+		return "", errors.New("cursor pagination: invalid cursor")
+	}
+	value, ok := payload["v"]
+	if !ok {
+		return "", errors.New("cursor pagination: invalid cursor")
+	}
+	return value, nil
+}
+
+// withDefaults 补全未设置的配置项
+// @receiver o PageOptions
+// @return PageOptions
+func (o PageOptions) withDefaults() PageOptions {
+	if o.PageKey == "" {
+		o.PageKey = "page"
+	}
+	if o.SizeKey == "" {
+		o.SizeKey = "size"
+	}
+	if o.CursorKey == "" {
+		o.CursorKey = "cursor"
+	}
+	if o.DefaultSize <= 0 {
+		o.DefaultSize = 20
+	}
+	if o.MaxSize <= 0 {
+		o.MaxSize = 100
+	}
+	if o.MaxPage <= 0 {
+		o.MaxPage = math.MaxInt32/o.MaxSize + 1
+	}
+	return o
+}
+
+// size 解析每页条数并限制在最大值以内
+// @receiver o PageOptions
+// @param value string
+// @return int
+func (o PageOptions) size(value string) int {
+	size, _ := strconv.Atoi(value)
+	if size < 1 {
+		size = o.DefaultSize
+	}
+	if size > o.MaxSize {
+		size = o.MaxSize
+	}
+	return size
+}
+
+// _emptySlice 将结果切片指针转为切片，nil切片转为空切片以保证输出[]而不是null
+// @param container interface{}
+// @return interface{}
+func _emptySlice(container interface{}) interface{} {
+	value := reflect.ValueOf(container)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Slice {
+		return container
+	}
+	if value.Elem().IsNil() {
+		return reflect.MakeSlice(value.Elem().Type(), 0, 0).Interface()
+	}
+	return value.Elem().Interface()
+}
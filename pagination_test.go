@@ -0,0 +1,84 @@
+/**
+ * Created by goland.
+ * User: adam_wang
+ * Date: 2023-09-05 22:14:30
+ */
+
+package tool
+
+import (
+	"encoding/base64"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	at := time.Date(2023, 9, 5, 22, 14, 30, 123456000, time.Local)
+	tests := []struct {
+		name  string
+		value interface{}
+		want  string
+	}{
+		{"int", 42, "42"},
+		{"int64", int64(-7), "-7"},
+		{"uint", uint(18446744073709551615), "18446744073709551615"},
+		{"string", "a\"b,c", "a\"b,c"},
+		{"unicode", "中文", "中文"},
+		{"empty", "", ""},
+		{"time", at, "2023-09-05 22:14:30.123456"},
+		{"time pointer", &at, "2023-09-05 22:14:30.123456"},
+		{"time without fraction", time.Date(2023, 9, 5, 0, 0, 0, 0, time.UTC), "2023-09-05 00:00:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cursor := EncodeCursor(tt.value)
+			got, err := DecodeCursor(cursor)
+			if err != nil {
+				t.Fatalf("DecodeCursor(%q) error = %v", cursor, err)
+			}
+			if got != tt.want {
+				t.Errorf("DecodeCursor(EncodeCursor(%v)) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{"not base64", "%%%"},
+		{"padded base64", base64.URLEncoding.EncodeToString([]byte(`{"v":"12"}`))},
+		{"not json", base64.RawURLEncoding.EncodeToString([]byte("42"))},
+		{"missing value", base64.RawURLEncoding.EncodeToString([]byte(`{"x":"1"}`))},
+		{"wrong type", base64.RawURLEncoding.EncodeToString([]byte(`{"v":1}`))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if value, err := DecodeCursor(tt.cursor); err == nil {
+				t.Errorf("DecodeCursor(%q) = %q, want error", tt.cursor, value)
+			}
+		})
+	}
+}
+
+func TestPaginationOffset(t *testing.T) {
+	tests := []struct {
+		page, size int
+		want       int
+	}{
+		{1, 20, 0},
+		{3, 20, 40},
+		{0, 20, 0},
+		{3, 0, 0},
+		{math.MaxInt32, 100, math.MaxInt32},
+	}
+	for _, tt := range tests {
+		p := &Pagination{Page: tt.page, Size: tt.size}
+		if got := p.Offset(); got != tt.want {
+			t.Errorf("Pagination{Page: %d, Size: %d}.Offset() = %d, want %d", tt.page, tt.size, got, tt.want)
+		}
+	}
+}
@@ -9,12 +9,13 @@ package tool
 import (
 	"fmt"
 	"github.com/beego/beego/v2/core/validation"
+	"net/http"
 	"reflect"
+	"sort"
 	"strings"
-	"time"
 )
 
-// MessageTemples 错误提示模板
+// MessageTemples 错误提示模板，同时作为zh-CN语言包的提示（InitValidate时同步到语言包）
 var MessageTemples = map[string]string{
 	"Required":     "不能为空",
 	"Min":          "最小为%d",
@@ -44,97 +45,376 @@ func InitValidate() {
 	_setDefaultMessage()
 }
 
-// Valid 公共的表单校验方法
+// Valid 公共的表单校验方法，返回第一个错误的提示
+// 保持原有行为：使用beego validation校验，提示来自validation.MessageTmpls（InitValidate后为MessageTemples，可自行修改），
+// 提示中的字段名替换为validate中同名字段的alias标签
 // @param obj interface{}
+// @param validate interface{} 读取alias标签的结构体（通常与obj相同），为nil时使用obj
 // @return error string
+//
+// Deprecated: 使用Validate或ValidateAll，提示按语言包生成并支持嵌套结构体
 func Valid(obj interface{}, validate interface{}) (error string) {
 	valid := validation.Validation{}
-	b, _ := valid.Valid(obj)
-	if !b {
-		//通过反射获取结构体
-		st := reflect.TypeOf(validate)
-
-		for _, err := range valid.Errors {
-			//获取验证的字段名和提示信息的别名
-			filed, _ := st.FieldByName(err.Field)
-			var alias = filed.Tag.Get("alias")
-			//返回验证的错误信息
-			return strings.Replace(err.Message, err.Field, alias, 1)
+	ok, err := valid.Valid(obj)
+	if err != nil {
+		return err.Error()
+	}
+	if ok || len(valid.Errors) == 0 {
+		return ""
+	}
+
+	validErr := valid.Errors[0]
+	if validate == nil {
+		validate = obj
+	}
+	//通过反射获取字段的别名
+	st := reflect.TypeOf(validate)
+	for st != nil && st.Kind() == reflect.Ptr {
+		st = st.Elem()
+	}
+	if st != nil && st.Kind() == reflect.Struct {
+		if field, found := st.FieldByName(validErr.Field); found {
+			if alias := field.Tag.Get("alias"); alias != "" {
+				return strings.Replace(validErr.Message, validErr.Field, alias, 1)
+			}
 		}
 	}
+	return validErr.Message
+}
 
-	return ""
+// ValidError 字段校验错误
+type ValidError struct {
+	Field   string        `json:"field"`            // 结构体字段名
+	Name    string        `json:"name"`             // json字段名
+	Path    string        `json:"path"`             // 以json字段名表示的完整路径，如items[2].price，用于前端定位输入项
+	Alias   string        `json:"alias"`            // 字段别名，依次取alias、label、json标签，都未设置时为字段名
+	Rule    string        `json:"rule"`             // 校验规则，如Required、Range
+	Message string        `json:"message"`          // 错误提示
+	Params  []interface{} `json:"params,omitempty"` // 规则参数，如Range的最小值及最大值
 }
 
-// SetDefaultMessage
-//
-//	默认设置通用的错误验证和提示项
-func _setDefaultMessage() {
-	if len(MessageTemples) == 0 {
-		return
+// ValidErrors 校验错误列表
+type ValidErrors []*ValidError
+
+// Error 第一个错误的提示
+// @receiver e ValidErrors
+// @return string
+func (e ValidErrors) Error() string {
+	if len(e) == 0 {
+		return ""
 	}
-	//将默认的提示信息转为自定义
-	for k, _ := range MessageTemples {
-		validation.MessageTmpls[k] = MessageTemples[k]
+	return e[0].Message
+}
+
+// Fields 按字段路径分组的错误提示（每个字段只保留第一个错误）
+// @receiver e ValidErrors
+// @return map[string]string
+func (e ValidErrors) Fields() map[string]string {
+	fields := make(map[string]string, len(e))
+	for _, err := range e {
+		if _, ok := fields[err.Path]; !ok {
+			fields[err.Path] = err.Message
+		}
 	}
+	return fields
+}
 
-	//增加默认的自定义验证方法
-	_ = validation.AddCustomFunc("DateFormat", _dateFormat)
-	_ = validation.AddCustomFunc("DateTimeFormat", _dateTimeFormat)
-	_ = validation.AddCustomFunc("Duration", _duration)
+// Validate 校验结构体（指针会自动解引用），有字段校验失败时返回ValidErrors，提示使用默认语言（DefaultLocale）
+// @param obj interface{} 结构体或结构体指针
+// @return error
+func Validate(obj interface{}) error {
+	return ValidateLocale(obj, DefaultLocale)
 }
 
-// _dateFormat 校验日期格式是否是Y-m-d
-var _dateFormat validation.CustomFunc = func(v *validation.Validation, obj interface{}, key string) {
-	//断言传入的是字符串
-	dateObj, err := obj.(string)
-	if !err {
-		v.AddError(key, "必须是有效的日期格式（Y-m-d）")
-		return
+// ValidateLocale 校验结构体，提示使用指定语言
+// @param obj interface{} 结构体或结构体指针
+// @param locale string 如zh-CN、en-US
+// @return error
+func ValidateLocale(obj interface{}, locale string) error {
+	errs, err := ValidateAllLocale(obj, locale)
+	if err != nil {
+		return err
 	}
-	dateTmp := "2006-01-02"
-	loc, _ := time.LoadLocation("Local")
-	_, ok := time.ParseInLocation(dateTmp, dateObj, loc)
-	if ok != nil {
-		v.AddError(key, "必须是有效的日期格式（Y-m-d）")
-		return
+	if errs != nil {
+		return errs
 	}
-	return
+	return nil
 }
 
-// _dateTimeFormat 校验时间格式是否是Y-m-d H:i:s
-var _dateTimeFormat validation.CustomFunc = func(v *validation.Validation, obj interface{}, key string) {
-	//断言传入的是字符串
-	dateObj, err := obj.(string)
-	if !err {
-		v.AddError(key, "必须是有效的时间格式（Y-m-d H:i:s）")
-		return
+// ValidateAll 校验结构体并返回所有字段的错误，嵌套的结构体、切片、数组及map中的结构体也会校验；
+// 校验通过时返回nil，obj不是结构体或校验标签有误时返回error，提示使用默认语言（DefaultLocale）
+// @param obj interface{} 结构体或结构体指针
+// @return ValidErrors
+// @return error
+func ValidateAll(obj interface{}) (ValidErrors, error) {
+	return ValidateAllLocale(obj, DefaultLocale)
+}
+
+// ValidateAllLocale 校验结构体并返回所有字段的错误，提示及别名使用指定语言
+// @param obj interface{} 结构体或结构体指针
+// @param locale string 如zh-CN、en-US
+// @return ValidErrors
+// @return error
+func ValidateAllLocale(obj interface{}, locale string) (ValidErrors, error) {
+	t := reflect.TypeOf(obj)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct || reflect.ValueOf(obj).Kind() == reflect.Ptr && reflect.ValueOf(obj).IsNil() {
+		return nil, fmt.Errorf("%v must be a struct or a struct pointer", obj)
 	}
 
-	dateTmp := "2006-01-02 15:04:05"
-	localTime, ok := time.ParseInLocation(dateTmp, dateObj, time.Local)
-	fmt.Println(localTime)
-	if ok != nil {
-		v.AddError(key, "必须是有效的时间格式（Y-m-d H:i:s）")
-		return
+	walker := &validWalker{catalog: _validCatalog(locale), visited: make(map[uintptr]bool)}
+	if err := walker.value(reflect.ValueOf(obj), ""); err != nil {
+		return nil, err
 	}
-	return
+	return walker.errs, nil
+}
+
+// validWalker 递归校验
+type validWalker struct {
+	catalog *ValidCatalog
+	errs    ValidErrors
+	visited map[uintptr]bool // 已校验的指针，避免循环引用
 }
 
-// _duration 工作时长校验
-var _duration validation.CustomFunc = func(v *validation.Validation, obj interface{}, key string) {
-	switch obj.(type) {
-	case float32:
-		objFloat := obj.(float32)
-		if objFloat < 0.1 {
-			v.AddError(key, "最小为0.1")
-			return
+// value 递归校验结构体、切片、数组及map
+// @receiver w *validWalker
+// @param v reflect.Value
+// @param path string
+// @return error
+func (w *validWalker) value(v reflect.Value, path string) error {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		if v.Kind() == reflect.Ptr {
+			if w.visited[v.Pointer()] {
+				return nil
+			}
+			w.visited[v.Pointer()] = true
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		return w.structValue(v, path)
+	case reflect.Slice, reflect.Array:
+		if !_validNested(v.Type().Elem()) {
+			return nil
+		}
+		for i := 0; i < v.Len(); i++ {
+			if err := w.value(v.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if !_validNested(v.Type().Elem()) {
+			return nil
 		}
-		if objFloat > 24.0 {
-			v.AddError(key, "最大为24.0")
-			return
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		for _, key := range keys {
+			if err := w.value(v.MapIndex(key), _validPath(path, fmt.Sprint(key.Interface()))); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// structValue 使用beego validation校验结构体，并继续校验其字段
+// @receiver w *validWalker
+// @param v reflect.Value
+// @param path string
+// @return error
+func (w *validWalker) structValue(v reflect.Value, path string) error {
+	//可寻址时传入指针，使指针接收者的Valid(*validation.Validation)方法生效
+	target := v.Interface()
+	if v.CanAddr() {
+		target = v.Addr().Interface()
+	}
+	//先检查rule标签及valid标签中的自定义规则，标签有误时返回明确的错误
+	st := v.Type()
+	if _, err := _ruleFields(st); err != nil {
+		return err
+	}
+	valid := validation.Validation{}
+	if _, err := valid.Valid(target); err != nil {
+		return err
+	}
+	for _, e := range valid.Errors {
+		w.errs = append(w.errs, w.error(st, path, e))
+	}
+	if err := w.rules(v, path); err != nil {
+		return err
+	}
+
+	for i := 0; i < st.NumField(); i++ {
+		field := st.Field(i)
+		if field.PkgPath != "" || !_validNested(field.Type) {
+			continue
 		}
-	default:
-		v.AddError(key, "数据类型必须是float32")
+		fieldPath := path
+		if !field.Anonymous {
+			fieldPath = _validPath(path, _validName(field))
+		}
+		if err := w.value(v.Field(i), fieldPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// error 将beego的校验错误转换为ValidError，提示及别名按语言包翻译
+// @receiver w *validWalker
+// @param st reflect.Type
+// @param path string
+// @param e *validation.Error
+// @return *ValidError
+func (w *validWalker) error(st reflect.Type, path string, e *validation.Error) *ValidError {
+	params := _validParams(e.LimitValue)
+	//valid标签中使用的自定义规则没有参数，按默认参数填充提示
+	if rule := _getValidRule(e.Name); rule != nil && params == nil {
+		params = rule.args(rule.Defaults)
+	}
+	validErr := w.fieldError(st, e.Field, path, e.Name, params)
+	if validErr.Message == "" {
+		//提示信息以字段名（或valid的label）开头，替换为别名
+		label := e.Field
+		if parts := strings.Split(e.Key, "."); len(parts) == 3 && parts[2] != "" {
+			label = parts[2]
+		}
+		validErr.Message = strings.Replace(e.Message, label, validErr.Alias, 1)
+	}
+	return validErr
+}
+
+// fieldError 生成字段的校验错误，语言包中没有该规则时Message为空
+// @receiver w *validWalker
+// @param st reflect.Type
+// @param fieldName string
+// @param path string
+// @param rule string
+// @param params []interface{}
+// @return *ValidError
+func (w *validWalker) fieldError(st reflect.Type, fieldName, path, rule string, params []interface{}) *ValidError {
+	validErr := &ValidError{Field: fieldName, Name: fieldName, Alias: fieldName, Rule: rule, Params: params}
+	if field, ok := st.FieldByName(fieldName); ok {
+		validErr.Name = _validName(field)
+		validErr.Alias = _fieldAlias(field)
+	}
+	validErr.Path = _validPath(path, validErr.Name)
+	validErr.Alias = w.catalog.alias(validErr.Alias)
+	if message, ok := w.catalog.message(rule, params); ok {
+		validErr.Message = validErr.Alias + " " + message
+	}
+	return validErr
+}
+
+// _validNested 类型是否可能包含需要校验的结构体
+// @param t reflect.Type
+// @return bool
+func _validNested(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		//time.Time等没有导出字段的结构体无需校验
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).PkgPath == "" {
+				return true
+			}
+		}
+		return false
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return _validNested(t.Elem())
+	case reflect.Interface:
+		return true
+	}
+	return false
+}
+
+// _validPath 拼接字段路径
+// @param path string
+// @param name string
+// @return string
+func _validPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// _validName 字段的json名称（与encoding/json一致，未设置时为字段名）
+// @param field reflect.StructField
+// @return string
+func _validName(field reflect.StructField) string {
+	if name := strings.Split(field.Tag.Get("json"), ",")[0]; name != "" && name != "-" {
+		return name
+	}
+	return field.Name
+}
+
+// _fieldAlias 字段别名，依次取alias、label、json标签，都未设置时为字段名
+// @param field reflect.StructField
+// @return string
+func _fieldAlias(field reflect.StructField) string {
+	for _, tag := range []string{"alias", validation.LabelTag} {
+		if alias := field.Tag.Get(tag); alias != "" {
+			return alias
+		}
+	}
+	return _validName(field)
+}
+
+// OtuPutValidErrors 以422状态码及ReturnMsg结构输出校验错误，data中errors为错误列表，fields为按字段路径分组的错误提示
+// @receiver ctx *Context
+// @param errs ValidErrors
+func (ctx *Context) OtuPutValidErrors(errs ValidErrors) {
+	ctx.OtuPutJson(http.StatusUnprocessableEntity, ReturnMsg{
+		Code: http.StatusUnprocessableEntity,
+		Msg:  errs.Error(),
+		Data: map[string]interface{}{
+			"errors": errs,
+			"fields": errs.Fields(),
+		},
+	})
+}
+
+// _validParams 将规则参数转换为列表
+// @param limit interface{}
+// @return []interface{}
+func _validParams(limit interface{}) []interface{} {
+	if limit == nil {
+		return nil
+	}
+	v := reflect.ValueOf(limit)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return []interface{}{limit}
+	}
+	params := make([]interface{}, v.Len())
+	for i := range params {
+		params[i] = v.Index(i).Interface()
+	}
+	return params
+}
+
+// SetDefaultMessage
+//
+//	默认设置通用的错误验证和提示项
+func _setDefaultMessage() {
+	if len(MessageTemples) == 0 {
+		return
+	}
+	//将默认的提示信息转为自定义
+	for k, _ := range MessageTemples {
+		validation.MessageTmpls[k] = MessageTemples[k]
 	}
+	//初始化前对MessageTemples的修改同步到zh-CN语言包
+	RegisterValidCatalog("zh-CN", ValidCatalog{Messages: MessageTemples})
 }
@@ -0,0 +1,279 @@
+/**
+ * Created by goland.
+ * User: adam_wang
+ * Date: 2023-10-19 16:27:45
+ */
+
+package tool
+
+import (
+	"encoding/json"
+	"fmt"
+	"golang.org/x/text/language"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultLocale 默认语言，请求未指定或不支持时使用
+var DefaultLocale = "zh-CN"
+
+// LocaleKey 指定语言的请求参数名，如lang=en-US，优先于Accept-Language
+var LocaleKey = "lang"
+
+// ValidCatalog 校验提示的语言包
+type ValidCatalog struct {
+	Messages map[string]string `json:"messages"` // 校验规则的提示模板，如"Range": "must be between %d and %d"
+	Aliases  map[string]string `json:"aliases"`  // 字段别名的翻译，key为alias（或label、json）标签的值
+}
+
+// localeMatcher 语言匹配器，names与匹配器的语言一一对应，names[0]为默认语言
+type localeMatcher struct {
+	matcher language.Matcher
+	names   []string
+}
+
+var (
+	validMatcher    *localeMatcher // 已注册语言包的匹配器，由validCatalogsMu保护
+	validCatalogsMu sync.RWMutex
+	validCatalogs   = map[string]*ValidCatalog{
+		//使用MessageTemples的副本，避免注册规则时写入导出的MessageTemples（InitValidate会将其复制到beego的全局模板）
+		"zh-CN": {Messages: func() map[string]string {
+			messages := make(map[string]string, len(MessageTemples))
+			for k, v := range MessageTemples {
+				messages[k] = v
+			}
+			return messages
+		}(), Aliases: map[string]string{}},
+		"en-US": {Messages: map[string]string{
+			"Required":     "is required",
+			"Min":          "must be at least %d",
+			"Max":          "must be at most %d",
+			"Range":        "must be between %d and %d",
+			"MinSize":      "minimum size is %d",
+			"MaxSize":      "maximum size is %d",
+			"Length":       "length must be %d",
+			"Alpha":        "must contain only letters",
+			"Numeric":      "must contain only digits",
+			"AlphaNumeric": "must contain only letters or digits",
+			"Match":        "must match %s",
+			"NoMatch":      "must not match %s",
+			"AlphaDash":    "must contain only letters, digits, dashes or underscores",
+			"Email":        "must be a valid email address",
+			"IP":           "must be a valid IP address",
+			"Base64":       "must be valid base64",
+			"Mobile":       "must be a valid mobile number",
+			"Tel":          "must be a valid telephone number",
+			"Phone":        "must be a valid telephone or mobile number",
+			"ZipCode":      "must be a valid zip code",
+		}, Aliases: map[string]string{}},
+	}
+)
+
+// RegisterValidCatalog 注册语言包，语言已存在时合并（同名覆盖）
+// @param locale string 如zh-CN、en-US、ja
+// @param catalog ValidCatalog
+func RegisterValidCatalog(locale string, catalog ValidCatalog) {
+	locale = _canonicalLocale(locale)
+	validCatalogsMu.Lock()
+	defer validCatalogsMu.Unlock()
+	current, ok := validCatalogs[locale]
+	if !ok {
+		current = &ValidCatalog{Messages: map[string]string{}, Aliases: map[string]string{}}
+		validCatalogs[locale] = current
+		validMatcher = _newLocaleMatcher(_canonicalLocale(DefaultLocale))
+	}
+	for k, v := range catalog.Messages {
+		current.Messages[k] = v
+	}
+	for k, v := range catalog.Aliases {
+		current.Aliases[k] = v
+	}
+}
+
+// LoadValidCatalog 从JSON文件加载语言包，格式为{"messages": {...}, "aliases": {...}}
+// @param locale string
+// @param path string
+// @return error
+func LoadValidCatalog(locale, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var catalog ValidCatalog
+	if err = json.Unmarshal(data, &catalog); err != nil {
+		return fmt.Errorf("validate: invalid catalog %s: %w", path, err)
+	}
+	RegisterValidCatalog(locale, catalog)
+	return nil
+}
+
+// Locale 当前请求的语言：请求参数（LocaleKey）优先，其次为Accept-Language，均不支持时为DefaultLocale
+// @receiver ctx *Context
+// @return string
+func (ctx *Context) Locale() string {
+	m := _localeMatcher()
+
+	var desired []language.Tag
+	if locale := ctx.Query(LocaleKey); locale != "" {
+		if tag, err := language.Parse(locale); err == nil {
+			desired = append(desired, tag)
+		}
+	}
+	if len(desired) == 0 {
+		desired, _, _ = language.ParseAcceptLanguage(ctx.Req.Request.Header.Get("Accept-Language"))
+	}
+	if len(desired) == 0 {
+		return m.names[0]
+	}
+	_, index, confidence := m.matcher.Match(desired...)
+	if confidence == language.No {
+		return m.names[0]
+	}
+	return m.names[index]
+}
+
+// _localeMatcher 获取已注册语言包的匹配器，注册新语言时重新构建，DefaultLocale被修改后在下次使用时重新构建
+// @return *localeMatcher
+func _localeMatcher() *localeMatcher {
+	defaultLocale := _canonicalLocale(DefaultLocale)
+	validCatalogsMu.RLock()
+	m := validMatcher
+	validCatalogsMu.RUnlock()
+	if m != nil && m.names[0] == defaultLocale {
+		return m
+	}
+
+	validCatalogsMu.Lock()
+	defer validCatalogsMu.Unlock()
+	if validMatcher == nil || validMatcher.names[0] != defaultLocale {
+		validMatcher = _newLocaleMatcher(defaultLocale)
+	}
+	return validMatcher
+}
+
+// _newLocaleMatcher 按已注册的语言包构建匹配器，调用方需持有validCatalogsMu
+// @param defaultLocale string 规范化后的默认语言
+// @return *localeMatcher
+func _newLocaleMatcher(defaultLocale string) *localeMatcher {
+	names := make([]string, 0, len(validCatalogs)+1)
+	for name := range validCatalogs {
+		if name != defaultLocale {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	//默认语言放在首位，无法匹配时使用
+	names = append([]string{defaultLocale}, names...)
+
+	supported := make([]language.Tag, len(names))
+	for i, name := range names {
+		supported[i] = language.Make(name)
+	}
+	return &localeMatcher{matcher: language.NewMatcher(supported), names: names}
+}
+
+// Validate 校验结构体，提示使用当前请求的语言
+// @receiver ctx *Context
+// @param obj interface{}
+// @return error
+func (ctx *Context) Validate(obj interface{}) error {
+	return ValidateLocale(obj, ctx.Locale())
+}
+
+// ValidateAll 校验结构体并返回所有字段的错误，提示及别名使用当前请求的语言
+// @receiver ctx *Context
+// @param obj interface{}
+// @return ValidErrors
+// @return error
+func (ctx *Context) ValidateAll(obj interface{}) (ValidErrors, error) {
+	return ValidateAllLocale(obj, ctx.Locale())
+}
+
+// alias 翻译字段别名，未配置时返回原别名
+// @receiver c *ValidCatalog
+// @param alias string
+// @return string
+func (c *ValidCatalog) alias(alias string) string {
+	if c == nil {
+		return alias
+	}
+	validCatalogsMu.RLock()
+	defer validCatalogsMu.RUnlock()
+	if translated, ok := c.Aliases[alias]; ok {
+		return translated
+	}
+	return alias
+}
+
+// message 按校验规则生成提示，params依次填入模板
+// @receiver c *ValidCatalog
+// @param rule string
+// @param params []interface{}
+// @return string
+// @return bool 语言包中没有该规则或缺少参数时返回false
+func (c *ValidCatalog) message(rule string, params []interface{}) (string, bool) {
+	if c == nil || rule == "" {
+		return "", false
+	}
+	validCatalogsMu.RLock()
+	tmpl, ok := c.Messages[rule]
+	validCatalogsMu.RUnlock()
+	if !ok {
+		return "", false
+	}
+	verbs := strings.Count(tmpl, "%") - 2*strings.Count(tmpl, "%%")
+	if verbs == 0 {
+		return tmpl, true
+	}
+	if len(params) == 0 {
+		//缺少参数时不使用模板，避免提示中出现%s等占位符
+		return "", false
+	}
+	//模板只有一个占位符时，多个参数以逗号连接，如In(a,b,c)
+	if verbs == 1 && len(params) > 1 {
+		parts := make([]string, len(params))
+		for i, param := range params {
+			parts[i] = fmt.Sprint(param)
+		}
+		params = []interface{}{strings.Join(parts, ",")}
+	}
+	return fmt.Sprintf(tmpl, params...), true
+}
+
+// _validCatalog 获取语言包，不存在时依次使用同一语种的语言包及默认语言包
+// @param locale string
+// @return *ValidCatalog
+func _validCatalog(locale string) *ValidCatalog {
+	validCatalogsMu.RLock()
+	defer validCatalogsMu.RUnlock()
+	locale = _canonicalLocale(locale)
+	if catalog, ok := validCatalogs[locale]; ok {
+		return catalog
+	}
+	//同一语种有多个语言包时按名称顺序选择，保证结果稳定
+	base := strings.Split(locale, "-")[0]
+	names := make([]string, 0, len(validCatalogs))
+	for name := range validCatalogs {
+		if strings.Split(name, "-")[0] == base {
+			names = append(names, name)
+		}
+	}
+	if len(names) > 0 {
+		sort.Strings(names)
+		return validCatalogs[names[0]]
+	}
+	return validCatalogs[_canonicalLocale(DefaultLocale)]
+}
+
+// _canonicalLocale 规范化语言名称，如zh_cn转为zh-CN
+// @param locale string
+// @return string
+func _canonicalLocale(locale string) string {
+	tag, err := language.Parse(strings.ReplaceAll(locale, "_", "-"))
+	if err != nil {
+		return locale
+	}
+	return tag.String()
+}
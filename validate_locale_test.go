@@ -0,0 +1,79 @@
+/**
+ * Created by goland.
+ * User: adam_wang
+ * Date: 2023-10-19 16:27:45
+ */
+
+package tool
+
+import (
+	beegoContext "github.com/beego/beego/v2/server/web/context"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLocale(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		acceptLanguage string
+		want           string
+	}{
+		{"default", "", "", "zh-CN"},
+		{"accept language", "", "en-US,en;q=0.9", "en-US"},
+		{"accept language base", "", "en", "en-US"},
+		{"accept language weight", "", "fr;q=0.9,en;q=0.8", "en-US"},
+		{"unsupported", "", "fr-FR", "zh-CN"},
+		{"query first", "lang=zh_cn", "en-US", "zh-CN"},
+		{"invalid query", "lang=%21%21", "en-US", "en-US"},
+	}
+	for _, tt := range tests {
+		req := beegoContext.NewContext()
+		req.Request = httptest.NewRequest("GET", "/?"+tt.query, nil)
+		if tt.acceptLanguage != "" {
+			req.Request.Header.Set("Accept-Language", tt.acceptLanguage)
+		}
+		ctx := &Context{Req: req}
+		if got := ctx.Locale(); got != tt.want {
+			t.Errorf("%s: Locale() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLocaleMatcherRebuild(t *testing.T) {
+	first := _localeMatcher()
+	if again := _localeMatcher(); again != first {
+		t.Error("_localeMatcher() rebuilt the matcher without catalog changes")
+	}
+
+	RegisterValidCatalog("zh-CN", ValidCatalog{Messages: map[string]string{"Required": "必填"}})
+	if again := _localeMatcher(); again != first {
+		t.Error("merging into an existing catalog rebuilt the matcher")
+	}
+
+	RegisterValidCatalog("ja", ValidCatalog{Messages: map[string]string{"Required": "必須です"}})
+	defer func() {
+		validCatalogsMu.Lock()
+		delete(validCatalogs, "ja")
+		validMatcher = nil
+		validCatalogsMu.Unlock()
+		RegisterValidCatalog("zh-CN", ValidCatalog{Messages: map[string]string{"Required": MessageTemples["Required"]}})
+	}()
+	registered := _localeMatcher()
+	if registered == first {
+		t.Fatal("registering a new locale did not rebuild the matcher")
+	}
+	req := beegoContext.NewContext()
+	req.Request = httptest.NewRequest("GET", "/", nil)
+	req.Request.Header.Set("Accept-Language", "ja-JP")
+	if got := (&Context{Req: req}).Locale(); got != "ja" {
+		t.Errorf("Locale() = %q, want ja", got)
+	}
+
+	defaultLocale := DefaultLocale
+	DefaultLocale = "en_us"
+	defer func() { DefaultLocale = defaultLocale }()
+	if m := _localeMatcher(); m == registered || m.names[0] != "en-US" {
+		t.Errorf("after changing DefaultLocale names = %v, want en-US first", m.names)
+	}
+}
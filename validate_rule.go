@@ -0,0 +1,497 @@
+/**
+ * Created by goland.
+ * User: adam_wang
+ * Date: 2023-10-20 10:52:16
+ */
+
+package tool
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/beego/beego/v2/core/validation"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// RuleTag 自定义校验规则的标签名，多个规则以;分隔，如rule:"Duration(0.5,12);In(1,2,3)"
+var RuleTag = "rule"
+
+// RuleFunc 校验规则：value为字段值（指针已解引用），params为规则参数；校验不通过时返回false，参数有误时返回error
+type RuleFunc func(value interface{}, params []string) (bool, error)
+
+// ValidRule 自定义校验规则
+type ValidRule struct {
+	Func           RuleFunc
+	Defaults       []string                            // 标签中未指定参数时使用的默认参数
+	ParamsRequired bool                                // 没有默认参数且必须指定参数（如In），只能在rule标签中使用
+	Messages       map[string]string                   // 各语言的提示模板，如{"zh-CN": "范围在%s至%s"}；模板只有一个占位符时多个参数以逗号连接
+	Args           func(params []string) []interface{} // 提示模板的参数，默认为规则参数
+}
+
+var (
+	validRulesMu sync.RWMutex
+	validRules   = make(map[string]*ValidRule)
+	validEnums   = make(map[string][]string)
+	// validRuleFields 结构体字段的规则（按类型缓存）
+	validRuleFields sync.Map
+	// _beegoRules beego内置的校验方法，自定义规则不能与其同名
+	_beegoRules = func() map[string]bool {
+		names := make(map[string]bool, len(validation.MessageTmpls))
+		for name := range validation.MessageTmpls {
+			names[name] = true
+		}
+		return names
+	}()
+	_uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+)
+
+// ruleField 需要校验自定义规则的字段
+type ruleField struct {
+	index int
+	rules []ruleCall
+}
+
+// ruleCall 字段上的一个规则及参数
+type ruleCall struct {
+	name   string
+	params []string
+}
+
+func init() {
+	builtins := map[string]ValidRule{
+		"DateFormat": {Func: _ruleDate, Defaults: []string{"2006-01-02"}, Messages: map[string]string{
+			"zh-CN": "必须是有效的日期格式（%s）",
+			"en-US": "must be a valid date (%s)",
+		}},
+		"DateTimeFormat": {Func: _ruleDate, Defaults: []string{"2006-01-02 15:04:05"}, Messages: map[string]string{
+			"zh-CN": "必须是有效的时间格式（%s）",
+			"en-US": "must be a valid date time (%s)",
+		}},
+		"Duration": {Func: _ruleDuration, Defaults: []string{"0.1", "24"}, Messages: map[string]string{
+			"zh-CN": "范围在%s至%s",
+			"en-US": "must be between %s and %s",
+		}},
+		"In": {Func: _ruleIn, ParamsRequired: true, Messages: map[string]string{
+			"zh-CN": "必须是%s中的一个",
+			"en-US": "must be one of %s",
+		}},
+		"Enum": {Func: _ruleEnum, ParamsRequired: true, Args: _enumArgs, Messages: map[string]string{
+			"zh-CN": "必须是%s中的一个",
+			"en-US": "must be one of %s",
+		}},
+		"IDCard": {Func: _ruleIDCard, Messages: map[string]string{
+			"zh-CN": "必须是有效的身份证号码",
+			"en-US": "must be a valid resident ID number",
+		}},
+		"URL": {Func: _ruleURL, Defaults: []string{"http", "https"}, Args: func([]string) []interface{} { return nil }, Messages: map[string]string{
+			"zh-CN": "必须是有效的URL",
+			"en-US": "must be a valid URL",
+		}},
+		"UUID": {Func: _ruleUUID, Messages: map[string]string{
+			"zh-CN": "必须是有效的UUID",
+			"en-US": "must be a valid UUID",
+		}},
+		"JSON": {Func: _ruleJSON, Messages: map[string]string{
+			"zh-CN": "必须是有效的JSON",
+			"en-US": "must be valid JSON",
+		}},
+		"Password": {Func: _rulePassword, Defaults: []string{"8", "3"}, Messages: map[string]string{
+			"zh-CN": "长度至少为%s位，且包含大写字母、小写字母、数字、特殊字符中的至少%s种",
+			"en-US": "must be at least %s characters long and contain at least %s of uppercase letters, lowercase letters, digits and symbols",
+		}},
+	}
+	for name, rule := range builtins {
+		if err := RegisterValidRule(name, rule); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// RegisterValidRule 注册自定义校验规则（同名覆盖），在rule标签中使用（如rule:"Duration(0.5,12)"）；
+// ParamsRequired为false时也可以在valid标签中使用（如valid:"Required;IDCard"，使用默认参数）
+// @param name string
+// @param rule ValidRule
+// @return error
+func RegisterValidRule(name string, rule ValidRule) error {
+	if rule.Func == nil {
+		return fmt.Errorf("validate: rule %s has no func", name)
+	}
+	if _beegoRules[name] {
+		return fmt.Errorf("validate: rule %s conflicts with beego validator", name)
+	}
+	if rule.ParamsRequired && len(rule.Defaults) > 0 {
+		return fmt.Errorf("validate: rule %s has defaults, ParamsRequired must be false", name)
+	}
+
+	r := &rule
+	if !rule.ParamsRequired {
+		err := validation.AddCustomFunc(name, func(v *validation.Validation, obj interface{}, key string) {
+			ok, err := r.check(obj, r.Defaults)
+			if err == nil && ok {
+				return
+			}
+			message, found := _validCatalog(DefaultLocale).message(name, r.args(r.Defaults))
+			if !found {
+				message = name
+				if err != nil {
+					message = err.Error()
+				}
+			}
+			v.AddError(key, message)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	validRulesMu.Lock()
+	validRules[name] = r
+	validRulesMu.Unlock()
+	for locale, message := range rule.Messages {
+		RegisterValidCatalog(locale, ValidCatalog{Messages: map[string]string{name: message}})
+	}
+	return nil
+}
+
+// RegisterValidEnum 注册枚举，在Enum规则中使用，如rule:"Enum(order_status)"
+// @param name string
+// @param values ...interface{}
+func RegisterValidEnum(name string, values ...interface{}) {
+	items := make([]string, len(values))
+	for i, value := range values {
+		items[i] = fmt.Sprint(value)
+	}
+	validRulesMu.Lock()
+	defer validRulesMu.Unlock()
+	validEnums[name] = items
+}
+
+// check 校验值，空值（nil、空字符串）不校验，需要时配合valid:"Required"使用
+// @receiver r *ValidRule
+// @param value interface{}
+// @param params []string
+// @return bool
+// @return error
+func (r *ValidRule) check(value interface{}, params []string) (bool, error) {
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return true, nil
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() || v.Kind() == reflect.String && v.Len() == 0 {
+		return true, nil
+	}
+	return r.Func(v.Interface(), params)
+}
+
+// args 提示模板的参数
+// @receiver r *ValidRule
+// @param params []string
+// @return []interface{}
+func (r *ValidRule) args(params []string) []interface{} {
+	if r.Args != nil {
+		return r.Args(params)
+	}
+	args := make([]interface{}, len(params))
+	for i, param := range params {
+		args[i] = param
+	}
+	return args
+}
+
+// rules 校验结构体字段上的自定义规则
+// @receiver w *validWalker
+// @param v reflect.Value
+// @param path string
+// @return error
+func (w *validWalker) rules(v reflect.Value, path string) error {
+	st := v.Type()
+	fields, err := _ruleFields(st)
+	if err != nil {
+		return err
+	}
+	for _, field := range fields {
+		name := st.Field(field.index).Name
+		for _, call := range field.rules {
+			rule := _getValidRule(call.name)
+			if rule == nil {
+				return fmt.Errorf("validate: %s.%s: unknown rule %s", st.Name(), name, call.name)
+			}
+			params := call.params
+			if len(params) == 0 {
+				params = rule.Defaults
+			}
+			ok, err := rule.check(v.Field(field.index).Interface(), params)
+			if err != nil {
+				return fmt.Errorf("validate: %s.%s: %s: %w", st.Name(), name, call.name, err)
+			}
+			if !ok {
+				validErr := w.fieldError(st, name, path, call.name, rule.args(params))
+				if validErr.Message == "" {
+					validErr.Message = validErr.Alias + " " + call.name
+				}
+				w.errs = append(w.errs, validErr)
+			}
+		}
+	}
+	return nil
+}
+
+// _getValidRule 获取自定义规则
+// @param name string
+// @return *ValidRule
+func _getValidRule(name string) *ValidRule {
+	validRulesMu.RLock()
+	defer validRulesMu.RUnlock()
+	return validRules[name]
+}
+
+// _ruleFields 解析结构体字段的rule标签
+// @param st reflect.Type
+// @return []ruleField
+// @return error
+func _ruleFields(st reflect.Type) ([]ruleField, error) {
+	if cached, ok := validRuleFields.Load(st); ok {
+		return cached.([]ruleField), nil
+	}
+	var fields []ruleField
+	for i := 0; i < st.NumField(); i++ {
+		field := st.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		//valid标签中使用了必须指定参数的规则时视为标签错误，否则beego只能以默认参数校验
+		for _, part := range strings.Split(field.Tag.Get(validation.ValidTag), ";") {
+			name := strings.TrimSpace(strings.SplitN(part, "(", 2)[0])
+			if rule := _getValidRule(name); rule != nil && rule.ParamsRequired {
+				return nil, fmt.Errorf("validate: %s.%s: rule %s requires parameters, use it in the %s tag", st.Name(), field.Name, name, RuleTag)
+			}
+		}
+		tag := field.Tag.Get(RuleTag)
+		if tag == "" {
+			continue
+		}
+		item := ruleField{index: i}
+		for _, part := range strings.Split(tag, ";") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			call := ruleCall{name: part}
+			if start := strings.Index(part, "("); start != -1 {
+				if !strings.HasSuffix(part, ")") {
+					return nil, fmt.Errorf("validate: %s.%s: invalid rule %s", st.Name(), field.Name, part)
+				}
+				call.name = strings.TrimSpace(part[:start])
+				if inner := strings.TrimSpace(part[start+1 : len(part)-1]); inner != "" {
+					for _, param := range strings.Split(inner, ",") {
+						call.params = append(call.params, strings.TrimSpace(param))
+					}
+				}
+			}
+			if _getValidRule(call.name) == nil {
+				return nil, fmt.Errorf("validate: %s.%s: unknown rule %s", st.Name(), field.Name, call.name)
+			}
+			item.rules = append(item.rules, call)
+		}
+		if len(item.rules) > 0 {
+			fields = append(fields, item)
+		}
+	}
+	validRuleFields.Store(st, fields)
+	return fields, nil
+}
+
+// _ruleFloat 将任意数值类型转换为float64
+// @param value interface{}
+// @return float64
+// @return bool
+func _ruleFloat(value interface{}) (float64, bool) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+// _ruleDate 日期格式，参数为Go的时间格式，如DateFormat(2006/01/02)
+var _ruleDate RuleFunc = func(value interface{}, params []string) (bool, error) {
+	if len(params) != 1 {
+		return false, errors.New("require 1 parameter")
+	}
+	s, ok := value.(string)
+	if !ok {
+		return false, nil
+	}
+	_, err := time.ParseInLocation(params[0], s, time.Local)
+	return err == nil, nil
+}
+
+// _ruleDuration 数值范围（包含边界），支持任意数值类型，如Duration(0.5,12)
+var _ruleDuration RuleFunc = func(value interface{}, params []string) (bool, error) {
+	if len(params) != 2 {
+		return false, errors.New("require 2 parameters")
+	}
+	min, err := strconv.ParseFloat(params[0], 64)
+	if err != nil {
+		return false, err
+	}
+	max, err := strconv.ParseFloat(params[1], 64)
+	if err != nil {
+		return false, err
+	}
+	f, ok := _ruleFloat(value)
+	return ok && f >= min && f <= max, nil
+}
+
+// _ruleIn 值必须是参数之一，如In(a,b,c)
+var _ruleIn RuleFunc = func(value interface{}, params []string) (bool, error) {
+	if len(params) == 0 {
+		return false, errors.New("require at least 1 parameter")
+	}
+	s := fmt.Sprint(value)
+	for _, param := range params {
+		if s == param {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// _ruleEnum 值必须是已注册枚举的值之一，如Enum(order_status)
+var _ruleEnum RuleFunc = func(value interface{}, params []string) (bool, error) {
+	if len(params) != 1 {
+		return false, errors.New("require 1 parameter")
+	}
+	validRulesMu.RLock()
+	values, ok := validEnums[params[0]]
+	validRulesMu.RUnlock()
+	if !ok {
+		return false, fmt.Errorf("enum %s is not registered", params[0])
+	}
+	return _ruleIn(value, values)
+}
+
+// _enumArgs Enum的提示参数为枚举值
+// @param params []string
+// @return []interface{}
+func _enumArgs(params []string) []interface{} {
+	if len(params) != 1 {
+		return nil
+	}
+	validRulesMu.RLock()
+	defer validRulesMu.RUnlock()
+	return []interface{}{strings.Join(validEnums[params[0]], ",")}
+}
+
+// _ruleIDCard 18位居民身份证号码，校验出生日期及校验码
+var _ruleIDCard RuleFunc = func(value interface{}, params []string) (bool, error) {
+	s, ok := value.(string)
+	if !ok || len(s) != 18 {
+		return false, nil
+	}
+	weights := []int{7, 9, 10, 5, 8, 4, 2, 1, 6, 3, 7, 9, 10, 5, 8, 4, 2}
+	sum := 0
+	for i, weight := range weights {
+		if s[i] < '0' || s[i] > '9' {
+			return false, nil
+		}
+		sum += int(s[i]-'0') * weight
+	}
+	birthday, err := time.ParseInLocation("20060102", s[6:14], time.Local)
+	if err != nil || birthday.After(time.Now()) || birthday.Year() < 1900 {
+		return false, nil
+	}
+	return "10X98765432"[sum%11] == strings.ToUpper(s[17:])[0], nil
+}
+
+// _ruleURL 网址，参数为允许的协议，默认为http及https
+var _ruleURL RuleFunc = func(value interface{}, params []string) (bool, error) {
+	s, ok := value.(string)
+	if !ok {
+		return false, nil
+	}
+	u, err := url.ParseRequestURI(s)
+	if err != nil || u.Host == "" {
+		return false, nil
+	}
+	for _, scheme := range params {
+		if strings.EqualFold(u.Scheme, scheme) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// _ruleUUID UUID，可指定版本，如UUID(4)
+var _ruleUUID RuleFunc = func(value interface{}, params []string) (bool, error) {
+	s, ok := value.(string)
+	if !ok || !_uuidPattern.MatchString(s) {
+		return false, nil
+	}
+	if len(params) > 0 && params[0] != "" {
+		return s[14:15] == params[0], nil
+	}
+	return true, nil
+}
+
+// _ruleJSON JSON字符串
+var _ruleJSON RuleFunc = func(value interface{}, params []string) (bool, error) {
+	switch v := value.(type) {
+	case string:
+		return json.Valid([]byte(v)), nil
+	case []byte:
+		return json.Valid(v), nil
+	case json.RawMessage:
+		return json.Valid(v), nil
+	}
+	return false, nil
+}
+
+// _rulePassword 密码强度，参数为最小长度及至少包含的字符种类数（大写字母、小写字母、数字、特殊字符），如Password(8,3)
+var _rulePassword RuleFunc = func(value interface{}, params []string) (bool, error) {
+	if len(params) != 2 {
+		return false, errors.New("require 2 parameters")
+	}
+	minLength, err := strconv.Atoi(params[0])
+	if err != nil {
+		return false, err
+	}
+	minKinds, err := strconv.Atoi(params[1])
+	if err != nil {
+		return false, err
+	}
+	s, ok := value.(string)
+	if !ok || len([]rune(s)) < minLength {
+		return false, nil
+	}
+	var upper, lower, digit, symbol int
+	for _, r := range s {
+		switch {
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			symbol = 1
+		}
+	}
+	return upper+lower+digit+symbol >= minKinds, nil
+}
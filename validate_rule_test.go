@@ -0,0 +1,110 @@
+/**
+ * Created by goland.
+ * User: adam_wang
+ * Date: 2023-10-20 10:52:16
+ */
+
+package tool
+
+import (
+	"testing"
+)
+
+func TestRuleIDCard(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  bool
+	}{
+		{"check digit X", "11010519491231002X", true},
+		{"lowercase x", "44030419900307123x", true},
+		{"numeric check digit", "320102198001010008", true},
+		{"leap day", "110101200002290018", true},
+		{"wrong check digit", "110105194912310021", false},
+		{"invalid date", "110101199002300014", false},
+		{"future birthday", "110101300001010019", false},
+		{"before 1900", "11010118991231001X", false},
+		{"letter in body", "1101051949123100AX", false},
+		{"17 digits", "11010519491231002", false},
+		{"15 digits", "110105491231002", false},
+		{"empty", "", false},
+		{"not string", 110105194912310020, false},
+	}
+	for _, tt := range tests {
+		got, err := _ruleIDCard(tt.value, nil)
+		if err != nil || got != tt.want {
+			t.Errorf("%s: _ruleIDCard(%v) = %v, %v, want %v", tt.name, tt.value, got, err, tt.want)
+		}
+	}
+}
+
+func TestRuleDuration(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   interface{}
+		params  []string
+		want    bool
+		wantErr bool
+	}{
+		{"int in range", 5, []string{"0.5", "12"}, true, false},
+		{"lower bound", 0.5, []string{"0.5", "12"}, true, false},
+		{"upper bound", uint8(12), []string{"0.5", "12"}, true, false},
+		{"below", float32(0.4), []string{"0.5", "12"}, false, false},
+		{"above", int64(13), []string{"0.5", "12"}, false, false},
+		{"negative range", -3, []string{"-5", "-1"}, true, false},
+		{"default params", 24, []string{"0.1", "24"}, true, false},
+		{"string value", "5", []string{"0.5", "12"}, false, false},
+		{"nil value", nil, []string{"0.5", "12"}, false, false},
+		{"one param", 5, []string{"12"}, false, true},
+		{"invalid min", 5, []string{"a", "12"}, false, true},
+		{"invalid max", 5, []string{"0.5", "b"}, false, true},
+	}
+	for _, tt := range tests {
+		got, err := _ruleDuration(tt.value, tt.params)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("%s: _ruleDuration(%v, %v) = %v, %v, want %v, wantErr %v", tt.name, tt.value, tt.params, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+type ruleTestForm struct {
+	IDCard string  `json:"id_card" alias:"身份证号" valid:"IDCard"`
+	Hours  float64 `json:"hours" alias:"时长" rule:"Duration(0.5,12)"`
+	Days   int     `json:"days" alias:"天数" rule:"Duration"`
+}
+
+func TestValidateRules(t *testing.T) {
+	tests := []struct {
+		name   string
+		form   ruleTestForm
+		locale string
+		want   map[string]string
+	}{
+		{"valid", ruleTestForm{IDCard: "11010519491231002X", Hours: 8, Days: 1}, "zh-CN", map[string]string{}},
+		{"zh-CN", ruleTestForm{IDCard: "110105194912310021", Hours: 13, Days: 30}, "zh-CN", map[string]string{
+			"id_card": "身份证号 必须是有效的身份证号码",
+			"hours":   "时长 范围在0.5至12",
+			"days":    "天数 范围在0.1至24",
+		}},
+		{"en-US", ruleTestForm{IDCard: "11010519491231002X", Hours: 0.2, Days: 1}, "en-US", map[string]string{
+			"hours": "时长 must be between 0.5 and 12",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs, err := ValidateAllLocale(&tt.form, tt.locale)
+			if err != nil {
+				t.Fatalf("ValidateAllLocale() error = %v", err)
+			}
+			got := errs.Fields()
+			if len(got) != len(tt.want) {
+				t.Fatalf("errors = %v, want %v", got, tt.want)
+			}
+			for path, message := range tt.want {
+				if got[path] != message {
+					t.Errorf("%s: message = %q, want %q", path, got[path], message)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+/**
+ * Created by goland.
+ * User: adam_wang
+ * Date: 2023-07-07 00:44:14
+ */
+
+package tool
+
+import (
+	"github.com/beego/beego/v2/core/validation"
+	"testing"
+)
+
+type validTestForm struct {
+	Name  string `valid:"Required" alias:"姓名"`
+	Email string `valid:"Email"`
+}
+
+type validTestAliases struct {
+	Email string `alias:"邮箱"`
+}
+
+func TestValidDeprecated(t *testing.T) {
+	required, email := validation.MessageTmpls["Required"], validation.MessageTmpls["Email"]
+	validation.MessageTmpls["Required"] = "自定义：不能为空"
+	validation.MessageTmpls["Email"] = "自定义：邮箱格式错误"
+	defer func() {
+		validation.MessageTmpls["Required"], validation.MessageTmpls["Email"] = required, email
+	}()
+
+	tests := []struct {
+		name     string
+		obj      interface{}
+		validate interface{}
+		want     string
+	}{
+		{"valid", &validTestForm{Name: "adam", Email: "a@b.cn"}, validTestForm{}, ""},
+		{"alias from validate", &validTestForm{Email: "a@b.cn"}, validTestForm{}, "姓名 自定义：不能为空"},
+		{"nil validate uses obj", validTestForm{Email: "a@b.cn"}, nil, "姓名 自定义：不能为空"},
+		{"other alias struct", &validTestForm{Name: "adam", Email: "x"}, &validTestAliases{}, "邮箱 自定义：邮箱格式错误"},
+		{"field without alias", &validTestForm{Name: "adam", Email: "x"}, validTestForm{}, "Email 自定义：邮箱格式错误"},
+	}
+	for _, tt := range tests {
+		if got := Valid(tt.obj, tt.validate); got != tt.want {
+			t.Errorf("%s: Valid() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
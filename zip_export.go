@@ -0,0 +1,389 @@
+/**
+ * Created by goland.
+ * User: adam_wang
+ * Date: 2023-10-13 10:24:37
+ */
+
+package tool
+
+import (
+	"archive/zip"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	beegoContext "github.com/beego/beego/v2/server/web/context"
+	"golang.org/x/crypto/pbkdf2"
+	"hash"
+	"io"
+	"path"
+	"time"
+	"unicode/utf8"
+)
+
+// ZipOptions 压缩包配置
+type ZipOptions struct {
+	Password string // 所有文件的默认密码（WinZip AES-256加密），为空时不加密
+	Manifest string // 清单文件名，默认为manifest.json，为-时不生成清单
+}
+
+// ZipEntry 压缩包中的导出文件
+type ZipEntry struct {
+	Name     string // 文件名，不含扩展名时使用导出格式的扩展名
+	Exporter Exporter
+	Title    []string
+	Rows     RowIterator
+	Password string // 文件密码，为空时使用ZipOptions.Password
+}
+
+// ZipManifestEntry 清单中的文件信息
+type ZipManifestEntry struct {
+	Name      string `json:"name"`
+	Rows      int64  `json:"rows"`   // 数据行数（不含表头）
+	Size      int64  `json:"size"`   // 文件大小（未压缩、未加密）
+	SHA256    string `json:"sha256"` // 文件内容（未压缩、未加密）的SHA-256
+	Encrypted bool   `json:"encrypted"`
+}
+
+// ZipWriter 流式写入压缩包，每个文件边导出边压缩写入，不在内存中缓存
+type ZipWriter struct {
+	zip      *zip.Writer
+	opts     ZipOptions
+	manifest []ZipManifestEntry
+}
+
+// NewZipWriter 创建压缩包
+// @param w io.Writer
+// @param opts ZipOptions
+// @return *ZipWriter
+func NewZipWriter(w io.Writer, opts ZipOptions) *ZipWriter {
+	if opts.Manifest == "" {
+		opts.Manifest = "manifest.json"
+	}
+	return &ZipWriter{zip: zip.NewWriter(w), opts: opts}
+}
+
+// Add 导出文件并写入压缩包（配置了默认密码时加密）
+// @receiver z *ZipWriter
+// @param name string
+// @param e Exporter
+// @param title []string
+// @param rows RowIterator
+// @return error
+func (z *ZipWriter) Add(name string, e Exporter, title []string, rows RowIterator) error {
+	return z.AddEncrypted(name, z.opts.Password, e, title, rows)
+}
+
+// AddEncrypted 导出文件并使用指定密码加密写入压缩包，password为空时不加密
+// @receiver z *ZipWriter
+// @param name string
+// @param password string
+// @param e Exporter
+// @param title []string
+// @param rows RowIterator
+// @return error
+func (z *ZipWriter) AddEncrypted(name, password string, e Exporter, title []string, rows RowIterator) error {
+	if path.Ext(name) == "" {
+		name += e.Extension()
+	}
+	counter := &countRows{rows: rows}
+	entry := ZipManifestEntry{Name: name, Encrypted: password != ""}
+
+	var (
+		w   io.Writer
+		enc *zipAESWriter
+		err error
+	)
+	if password != "" {
+		enc, err = _zipCreateAES(z.zip, name, password)
+		w = enc
+	} else {
+		w, err = z.zip.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+	}
+	if err != nil {
+		return err
+	}
+
+	sum := &hashWriter{hash: sha256.New()}
+	if err = e.Export(io.MultiWriter(w, sum), title, counter); err != nil {
+		return fmt.Errorf("zip: %s: %w", name, err)
+	}
+	if enc != nil {
+		if err = enc.Close(); err != nil {
+			return err
+		}
+	}
+
+	entry.Rows = counter.n
+	entry.Size = sum.n
+	entry.SHA256 = hex.EncodeToString(sum.hash.Sum(nil))
+	z.manifest = append(z.manifest, entry)
+	return nil
+}
+
+// Manifest 已写入的文件清单
+// @receiver z *ZipWriter
+// @return []ZipManifestEntry
+func (z *ZipWriter) Manifest() []ZipManifestEntry {
+	return z.manifest
+}
+
+// Close 写入清单并结束压缩包
+// @receiver z *ZipWriter
+// @return error
+func (z *ZipWriter) Close() error {
+	if z.opts.Manifest != "-" {
+		data, err := json.MarshalIndent(map[string]interface{}{
+			"created_at": time.Now().Format(time.RFC3339),
+			"files":      z.manifest,
+		}, "", "  ")
+		if err != nil {
+			return err
+		}
+		w, err := z.zip.CreateHeader(&zip.FileHeader{Name: z.opts.Manifest, Method: zip.Deflate, Modified: time.Now()})
+		if err != nil {
+			return err
+		}
+		if _, err = w.Write(data); err != nil {
+			return err
+		}
+	}
+	return z.zip.Close()
+}
+
+// ExportZip 将多个导出文件打包为zip并写入响应
+// @param ctx *beegoContext.Context
+// @param fileName string 不含扩展名的文件名
+// @param opts ZipOptions
+// @param entries ...ZipEntry
+// @return error
+func ExportZip(ctx *beegoContext.Context, fileName string, opts ZipOptions, entries ...ZipEntry) error {
+	ctx.Output.Header("Content-Type", "application/zip")
+	ctx.Output.Header("Content-Disposition", ContentDisposition(fileName+".zip"))
+
+	z := NewZipWriter(ctx.ResponseWriter, opts)
+	for _, entry := range entries {
+		password := entry.Password
+		if password == "" {
+			password = opts.Password
+		}
+		if err := z.AddEncrypted(entry.Name, password, entry.Exporter, entry.Title, entry.Rows); err != nil {
+			return err
+		}
+	}
+	return z.Close()
+}
+
+// countRows 统计迭代的行数
+type countRows struct {
+	rows RowIterator
+	n    int64
+}
+
+// Next 下一行
+// @receiver c *countRows
+// @return []string
+// @return error
+func (c *countRows) Next() ([]string, error) {
+	row, err := c.rows.Next()
+	if err == nil {
+		c.n++
+	}
+	return row, err
+}
+
+// NextValues 按原类型获取下一行，使excel、ods保留数值类型
+// @receiver c *countRows
+// @return []interface{}
+// @return error
+func (c *countRows) NextValues() ([]interface{}, error) {
+	row, err := _nextValues(c.rows)
+	if err == nil {
+		c.n++
+	}
+	return row, err
+}
+
+// hashWriter 计算写入内容的摘要及大小
+type hashWriter struct {
+	hash hash.Hash
+	n    int64
+}
+
+// Write 写入
+// @receiver h *hashWriter
+// @param p []byte
+// @return int
+// @return error
+func (h *hashWriter) Write(p []byte) (int, error) {
+	h.n += int64(len(p))
+	return h.hash.Write(p)
+}
+
+// zipAESWriter WinZip AES（AE-2，AES-256）加密的压缩包文件：deflate压缩后使用AES-CTR加密，末尾附加HMAC-SHA1校验码
+type zipAESWriter struct {
+	header *zip.FileHeader
+	raw    io.Writer
+	flate  *flate.Writer
+	block  cipher.Block
+	mac    hash.Hash
+	nonce  [aes.BlockSize]byte
+	stream [aes.BlockSize]byte
+	used   int
+	size   uint64
+	n      uint64
+}
+
+// _zipCreateAES 在压缩包中创建AES加密的文件
+// @param z *zip.Writer
+// @param name string
+// @param password string
+// @return *zipAESWriter
+// @return error
+func _zipCreateAES(z *zip.Writer, name, password string) (*zipAESWriter, error) {
+	const keyLen = 32
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	key := pbkdf2.Key([]byte(password), salt, 1000, 2*keyLen+2, sha1.New)
+	block, err := aes.NewCipher(key[:keyLen])
+	if err != nil {
+		return nil, err
+	}
+
+	header := &zip.FileHeader{
+		Name:   name,
+		Method: 99,
+		//加密、使用数据描述符
+		Flags: 0x1 | 0x8,
+		//AES扩展字段：版本AE-2、厂商AE、强度AES-256、实际压缩方式deflate
+		Extra:          []byte{0x01, 0x99, 0x07, 0x00, 0x02, 0x00, 'A', 'E', 0x03, 0x08, 0x00},
+		CreatorVersion: 20,
+		ReaderVersion:  51,
+	}
+	if !_isASCII(name) && utf8.ValidString(name) {
+		header.Flags |= 0x800
+	}
+	header.ModifiedTime, header.ModifiedDate = _dosTime(time.Now())
+	raw, err := z.CreateRaw(header)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = raw.Write(salt); err != nil {
+		return nil, err
+	}
+	if _, err = raw.Write(key[2*keyLen:]); err != nil {
+		return nil, err
+	}
+
+	w := &zipAESWriter{
+		header: header,
+		raw:    raw,
+		block:  block,
+		mac:    hmac.New(sha1.New, key[keyLen:2*keyLen]),
+		used:   aes.BlockSize,
+		n:      uint64(len(salt)) + 2,
+	}
+	if w.flate, err = flate.NewWriter(&zipAESCipher{w}, flate.DefaultCompression); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Write 写入未压缩的内容
+// @receiver w *zipAESWriter
+// @param p []byte
+// @return int
+// @return error
+func (w *zipAESWriter) Write(p []byte) (int, error) {
+	w.size += uint64(len(p))
+	return w.flate.Write(p)
+}
+
+// Close 结束压缩并写入校验码及文件大小
+// @receiver w *zipAESWriter
+// @return error
+func (w *zipAESWriter) Close() error {
+	if err := w.flate.Close(); err != nil {
+		return err
+	}
+	if _, err := w.raw.Write(w.mac.Sum(nil)[:10]); err != nil {
+		return err
+	}
+	w.n += 10
+
+	//AE-2不保存CRC32，由HMAC校验；数据描述符在写入下一个文件或关闭压缩包时写入
+	w.header.CompressedSize64 = w.n
+	w.header.UncompressedSize64 = w.size
+	if w.n >= 1<<32-1 || w.size >= 1<<32-1 {
+		w.header.CompressedSize, w.header.UncompressedSize = 1<<32-1, 1<<32-1
+	} else {
+		w.header.CompressedSize, w.header.UncompressedSize = uint32(w.n), uint32(w.size)
+	}
+	return nil
+}
+
+// zipAESCipher 加密压缩后的内容
+type zipAESCipher struct {
+	w *zipAESWriter
+}
+
+// Write 加密并写入
+// @receiver c *zipAESCipher
+// @param p []byte
+// @return int
+// @return error
+func (c *zipAESCipher) Write(p []byte) (int, error) {
+	w := c.w
+	buf := make([]byte, len(p))
+	for i, b := range p {
+		if w.used == aes.BlockSize {
+			//计数器为小端序，从1开始
+			for j := range w.nonce {
+				w.nonce[j]++
+				if w.nonce[j] != 0 {
+					break
+				}
+			}
+			w.block.Encrypt(w.stream[:], w.nonce[:])
+			w.used = 0
+		}
+		buf[i] = b ^ w.stream[w.used]
+		w.used++
+	}
+	w.mac.Write(buf)
+	n, err := w.raw.Write(buf)
+	w.n += uint64(n)
+	return n, err
+}
+
+// _isASCII 是否只包含ASCII字符
+// @param s string
+// @return bool
+func _isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// _dosTime 转换为MS-DOS格式的时间及日期
+// @param t time.Time
+// @return uint16 时间
+// @return uint16 日期
+func _dosTime(t time.Time) (uint16, uint16) {
+	if t.Year() < 1980 {
+		return 0, 1<<5 | 1
+	}
+	return uint16(t.Hour()<<11 | t.Minute()<<5 | t.Second()>>1),
+		uint16((t.Year()-1980)<<9 | int(t.Month())<<5 | t.Day())
+}
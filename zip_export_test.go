@@ -0,0 +1,206 @@
+/**
+ * Created by goland.
+ * User: adam_wang
+ * Date: 2023-10-13 10:24:37
+ */
+
+package tool
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"golang.org/x/crypto/pbkdf2"
+	"io"
+	"strings"
+	"testing"
+)
+
+// _zipTestDecrypt 按WinZip AES（AE-2，AES-256）规范解密文件内容：salt(16) + 密码校验值(2) + 密文 + HMAC-SHA1(10)
+// @param raw []byte
+// @param password string
+// @return []byte
+// @return error
+func _zipTestDecrypt(raw []byte, password string) ([]byte, error) {
+	const keyLen = 32
+	if len(raw) < 16+2+10 {
+		return nil, errors.New("encrypted data too short")
+	}
+	salt, verifier, data, mac := raw[:16], raw[16:18], raw[18:len(raw)-10], raw[len(raw)-10:]
+	key := pbkdf2.Key([]byte(password), salt, 1000, 2*keyLen+2, sha1.New)
+	if !bytes.Equal(key[2*keyLen:], verifier) {
+		return nil, errors.New("wrong password")
+	}
+	h := hmac.New(sha1.New, key[keyLen:2*keyLen])
+	h.Write(data)
+	if !hmac.Equal(h.Sum(nil)[:10], mac) {
+		return nil, errors.New("hmac mismatch")
+	}
+
+	block, err := aes.NewCipher(key[:keyLen])
+	if err != nil {
+		return nil, err
+	}
+	var nonce, stream [aes.BlockSize]byte
+	plain := make([]byte, len(data))
+	for i := range data {
+		if i%aes.BlockSize == 0 {
+			for j := range nonce {
+				nonce[j]++
+				if nonce[j] != 0 {
+					break
+				}
+			}
+			block.Encrypt(stream[:], nonce[:])
+		}
+		plain[i] = data[i] ^ stream[i%aes.BlockSize]
+	}
+	return io.ReadAll(flate.NewReader(bytes.NewReader(plain)))
+}
+
+func TestZipWriterAESRoundTrip(t *testing.T) {
+	title := []string{"id", "name"}
+	//超过一个AES块及deflate缓冲，覆盖计数器进位
+	var rows [][]string
+	for i := 0; i < 2000; i++ {
+		rows = append(rows, []string{strings.Repeat("9", i%7+1), "用户" + strings.Repeat("x", i%13)})
+	}
+	var want bytes.Buffer
+	if err := (&NDJSONExporter{}).Export(&want, title, RowsFromSlice(rows)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		file      string
+		password  string
+		encrypted bool
+	}{
+		{"default password", "users", "", true},
+		{"entry password", "订单", "entry-secret", true},
+		{"empty rows", "empty", "", true},
+	}
+
+	var buf bytes.Buffer
+	z := NewZipWriter(&buf, ZipOptions{Password: "zip-secret"})
+	for _, tt := range tests {
+		data := rows
+		if tt.name == "empty rows" {
+			data = nil
+		}
+		var err error
+		if tt.password != "" {
+			err = z.AddEncrypted(tt.file, tt.password, &NDJSONExporter{}, title, RowsFromSlice(data))
+		} else {
+			err = z.Add(tt.file, &NDJSONExporter{}, title, RowsFromSlice(data))
+		}
+		if err != nil {
+			t.Fatalf("%s: add error = %v", tt.name, err)
+		}
+	}
+	if err := z.AddEncrypted("plain", "", &NDJSONExporter{}, title, RowsFromSlice(rows)); err != nil {
+		t.Fatal(err)
+	}
+	if err := z.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("zip.NewReader() error = %v", err)
+	}
+	files := make(map[string]*zip.File)
+	for _, f := range reader.File {
+		files[f.Name] = f
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, ok := files[tt.file+".jsonl"]
+			if !ok {
+				t.Fatalf("%s.jsonl not found in zip", tt.file)
+			}
+			if f.Method != 99 || f.Flags&0x1 == 0 {
+				t.Errorf("method = %d, flags = %#x, want AES encrypted", f.Method, f.Flags)
+			}
+			if f.Name != tt.file+".jsonl" || (f.Flags&0x800 != 0) == _isASCII(f.Name) {
+				t.Errorf("utf-8 flag = %v for %q", f.Flags&0x800 != 0, f.Name)
+			}
+			raw, err := f.OpenRaw()
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := io.ReadAll(raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if uint64(len(data)) != f.CompressedSize64 {
+				t.Errorf("compressed size = %d, header says %d", len(data), f.CompressedSize64)
+			}
+
+			password := tt.password
+			if password == "" {
+				password = "zip-secret"
+			}
+			if _, err = _zipTestDecrypt(data, password+"x"); err == nil {
+				t.Error("decrypt with a wrong password succeeded")
+			}
+			plain, err := _zipTestDecrypt(data, password)
+			if err != nil {
+				t.Fatalf("decrypt error = %v", err)
+			}
+			expected := want.Bytes()
+			if tt.name == "empty rows" {
+				var empty bytes.Buffer
+				_ = (&NDJSONExporter{}).Export(&empty, title, RowsFromSlice(nil))
+				expected = empty.Bytes()
+			}
+			if !bytes.Equal(plain, expected) {
+				t.Errorf("decrypted %d bytes, want %d bytes", len(plain), len(expected))
+			}
+			if f.UncompressedSize64 != uint64(len(plain)) {
+				t.Errorf("uncompressed size = %d, want %d", f.UncompressedSize64, len(plain))
+			}
+
+			entry := z.Manifest()[i]
+			sum := sha256.Sum256(plain)
+			if !entry.Encrypted || entry.Size != int64(len(plain)) || entry.SHA256 != hex.EncodeToString(sum[:]) {
+				t.Errorf("manifest = %+v, want encrypted entry with size %d", entry, len(plain))
+			}
+		})
+	}
+
+	t.Run("plain entry and manifest", func(t *testing.T) {
+		rc, err := files["plain.jsonl"].Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		plain, err := io.ReadAll(rc)
+		_ = rc.Close()
+		if err != nil || !bytes.Equal(plain, want.Bytes()) {
+			t.Fatalf("plain entry error = %v, equal = %v", err, bytes.Equal(plain, want.Bytes()))
+		}
+
+		rc, err = files["manifest.json"].Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rc.Close()
+		var manifest struct {
+			Files []ZipManifestEntry `json:"files"`
+		}
+		if err = json.NewDecoder(rc).Decode(&manifest); err != nil {
+			t.Fatal(err)
+		}
+		if len(manifest.Files) != 4 || manifest.Files[0].Rows != int64(len(rows)) || manifest.Files[3].Encrypted {
+			t.Errorf("manifest = %+v", manifest.Files)
+		}
+	})
+}